@@ -2,16 +2,20 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"subscription-service/internal/config"
 	"subscription-service/internal/db"
 	"subscription-service/internal/handler"
 	"subscription-service/internal/logger"
+	"subscription-service/internal/metrics"
 	"subscription-service/internal/service"
+	"subscription-service/internal/tracing"
 )
 
 func main() {
@@ -40,10 +44,66 @@ func main() {
 		}
 	}()
 
+	migrator, err := db.NewMigrator(dbConn)
+	if err != nil {
+		log.Fatal().Err(err).Msg("load migrations failed")
+	}
+
+	// server migrate ... — управление схемой БД вместо запуска сервера
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(context.Background(), migrator, log, os.Args[2:]); err != nil {
+			log.Error().Err(err).Msg("migrate failed")
+			dbConn.Close()
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.MigrateOnStart {
+		if err := migrateUp(context.Background(), migrator, log); err != nil {
+			log.Fatal().Err(err).Msg("migrate on start failed")
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:     cfg.TraceExporter,
+		OTLPEndpoint: cfg.OTLPEndpoint,
+		File:         cfg.TraceFile,
+		SampleRatio:  cfg.TraceSampleRatio,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("tracing setup failed")
+	}
+
 	// инициализация зависимостей
-	repo := db.NewStore(dbConn)
-	svc := service.New(repo, log)
-	router := handler.NewRouter(handler.NewHandler(svc, log), log)
+	m := metrics.New(log)
+	m.RegisterDB(dbConn)
+	repo := db.Instrument(db.Instrument(db.NewStore(dbConn), m.QueryHook), tracing.QueryHook)
+	svc := service.Trace(service.New(repo, log,
+		service.WithIdempotencyTTL(cfg.IdempotencyTTL),
+		service.WithIdempotencyStaleAfter(cfg.IdempotencyStaleAfter),
+	))
+	// бизнес-метрики считаются через сервис над голым хранилищем: сбор /metrics
+	// не должен попадать в гистограммы запросов к БД и порождать корневые спаны
+	m.RegisterStats(service.New(db.NewStore(dbConn), log).Stats)
+
+	// готовность: БД отвечает и схема не отстаёт от встроенных миграций
+	health := handler.NewHealth()
+	health.Add("database", dbConn.PingContext)
+	health.Add("migrations", func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migrations, next is %03d_%s", len(pending), pending[0].Version, pending[0].Name)
+		}
+		return nil
+	})
+
+	if cfg.AdminToken == "" {
+		log.Warn().Msg("ADMIN_TOKEN is not set, admin API is disabled")
+	}
+	router := handler.NewRouter(handler.NewHandler(svc, log, handler.WithAdminToken(cfg.AdminToken)), health, m, log)
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -68,6 +128,11 @@ func main() {
 	<-ctx.Done()
 	log.Info().Msg("termination signal received, shutting down...")
 
+	// сначала /readyz отвечает отказом, чтобы балансировщик успел убрать экземпляр,
+	// и только потом сервер перестаёт принимать соединения
+	health.Drain()
+	time.Sleep(cfg.ShutdownDrainDelay)
+
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
@@ -76,4 +141,7 @@ func main() {
 	} else {
 		log.Info().Msg("server shutdown completed")
 	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("tracing shutdown failed")
+	}
 }
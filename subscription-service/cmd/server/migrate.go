@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"subscription-service/internal/db"
+
+	"github.com/rs/zerolog"
+)
+
+const migrateUsage = "usage: server migrate up | down [N] | status | force VERSION"
+
+// runMigrate выполняет подкоманду migrate: up применяет все новые миграции, down — откатывает
+// последние N (по умолчанию одну), status печатает список версий, force отмечает версии
+// до VERSION применёнными, не выполняя их
+func runMigrate(ctx context.Context, m *db.Migrator, log *zerolog.Logger, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch cmd, rest := args[0], args[1:]; {
+	case cmd == "up" && len(rest) == 0:
+		return migrateUp(ctx, m, log)
+
+	case cmd == "down" && len(rest) <= 1:
+		steps := 1
+		if len(rest) == 1 {
+			n, err := strconv.Atoi(rest[0])
+			if err != nil || n < 1 {
+				return fmt.Errorf("down: N must be a positive integer, got %q", rest[0])
+			}
+			steps = n
+		}
+		done, err := m.Down(ctx, steps)
+		for _, mg := range done {
+			log.Info().Int("version", mg.Version).Str("name", mg.Name).Msg("migration rolled back")
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			log.Info().Msg("no migrations to roll back")
+		}
+		return nil
+
+	case cmd == "status" && len(rest) == 0:
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, st := range statuses {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%03d\t%s\t%s\n", st.Version, st.Name, applied)
+		}
+		return tw.Flush()
+
+	case cmd == "force" && len(rest) == 1:
+		version, err := strconv.Atoi(rest[0])
+		if err != nil {
+			return fmt.Errorf("force: VERSION must be an integer, got %q", rest[0])
+		}
+		if err := m.Force(ctx, version); err != nil {
+			return err
+		}
+		log.Info().Int("version", version).Msg("migration version forced")
+		return nil
+	}
+
+	return errors.New(migrateUsage)
+}
+
+// migrateUp применяет новые миграции и пишет в лог каждую применённую
+func migrateUp(ctx context.Context, m *db.Migrator, log *zerolog.Logger) error {
+	done, err := m.Up(ctx)
+	for _, mg := range done {
+		log.Info().Int("version", mg.Version).Str("name", mg.Name).Msg("migration applied")
+	}
+	if errors.Is(err, db.ErrNotBaselined) {
+		return fmt.Errorf("%w: run `server migrate force VERSION` with the last version already in the database", err)
+	}
+	if err != nil {
+		return err
+	}
+	if len(done) == 0 {
+		log.Info().Msg("database schema is up to date")
+	}
+	return nil
+}
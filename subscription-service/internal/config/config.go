@@ -8,12 +8,21 @@ import (
 )
 
 type Config struct {
-	Port               string
-	DatabaseURL        string
-	LogLevel           string
-	ServerReadTimeout  time.Duration
-	ServerWriteTimeout time.Duration
-	ShutdownTimeout    time.Duration
+	Port                  string
+	DatabaseURL           string
+	LogLevel              string
+	ServerReadTimeout     time.Duration
+	ServerWriteTimeout    time.Duration
+	ShutdownTimeout       time.Duration
+	ShutdownDrainDelay    time.Duration // сколько /readyz отвечает отказом перед остановкой приёма запросов
+	IdempotencyTTL        time.Duration // сколько хранится ответ на запрос с Idempotency-Key
+	IdempotencyStaleAfter time.Duration // через сколько незавершённый запрос с Idempotency-Key считается брошенным
+	MigrateOnStart        bool          // применять ли миграции при запуске сервера
+	TraceExporter         string        // otlp, stdout, file или none; пусто — otlp при заданном коллекторе, иначе stdout
+	OTLPEndpoint          string        // URL коллектора OpenTelemetry
+	TraceFile             string        // файл трасс для TraceExporter = file
+	TraceSampleRatio      float64       // доля запросов без входящего traceparent, которые трассируются
+	AdminToken            string        // токен /admin/*; пусто — административные эндпоинты отключены
 }
 
 func Load() (*Config, error) {
@@ -31,6 +40,11 @@ func Load() (*Config, error) {
 	v.SetDefault("SERVER_READ_TIMEOUT", 10)
 	v.SetDefault("SERVER_WRITE_TIMEOUT", 10)
 	v.SetDefault("SHUTDOWN_TIMEOUT", 10)
+	v.SetDefault("SHUTDOWN_DRAIN_DELAY", 5)
+	v.SetDefault("IDEMPOTENCY_TTL", 24*60*60)
+	v.SetDefault("IDEMPOTENCY_STALE_AFTER", 60)
+	v.SetDefault("MIGRATE_ON_START", false)
+	v.SetDefault("TRACE_SAMPLE_RATIO", 1.0)
 
 	_ = v.ReadInConfig() // игнорируем ошибку если файла нет
 
@@ -50,12 +64,27 @@ func Load() (*Config, error) {
 	}
 
 	cfg := &Config{
-		Port:               v.GetString("PORT"),
-		DatabaseURL:        dbURL,
-		LogLevel:           v.GetString("LOG_LEVEL"),
-		ServerReadTimeout:  time.Second * time.Duration(v.GetInt("SERVER_READ_TIMEOUT")),
-		ServerWriteTimeout: time.Second * time.Duration(v.GetInt("SERVER_WRITE_TIMEOUT")),
-		ShutdownTimeout:    time.Second * time.Duration(v.GetInt("SHUTDOWN_TIMEOUT")),
+		Port:                  v.GetString("PORT"),
+		DatabaseURL:           dbURL,
+		LogLevel:              v.GetString("LOG_LEVEL"),
+		ServerReadTimeout:     time.Second * time.Duration(v.GetInt("SERVER_READ_TIMEOUT")),
+		ServerWriteTimeout:    time.Second * time.Duration(v.GetInt("SERVER_WRITE_TIMEOUT")),
+		ShutdownTimeout:       time.Second * time.Duration(v.GetInt("SHUTDOWN_TIMEOUT")),
+		ShutdownDrainDelay:    time.Second * time.Duration(v.GetInt("SHUTDOWN_DRAIN_DELAY")),
+		IdempotencyTTL:        time.Second * time.Duration(v.GetInt("IDEMPOTENCY_TTL")),
+		IdempotencyStaleAfter: time.Second * time.Duration(v.GetInt("IDEMPOTENCY_STALE_AFTER")),
+		MigrateOnStart:        v.GetBool("MIGRATE_ON_START"),
+		TraceExporter:         v.GetString("TRACE_EXPORTER"),
+		OTLPEndpoint:          v.GetString("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		TraceFile:             v.GetString("TRACE_FILE"),
+		TraceSampleRatio:      v.GetFloat64("TRACE_SAMPLE_RATIO"),
+		AdminToken:            v.GetString("ADMIN_TOKEN"),
+	}
+
+	// запрос не может выполняться дольше WriteTimeout, а более короткое окно отдало бы
+	// ключ повтору, пока первый запрос ещё работает
+	if cfg.IdempotencyStaleAfter < cfg.ServerWriteTimeout {
+		cfg.IdempotencyStaleAfter = cfg.ServerWriteTimeout
 	}
 
 	return cfg, nil
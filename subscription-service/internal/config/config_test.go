@@ -0,0 +1,29 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadIdempotencyStaleAfter(t *testing.T) {
+	tests := []struct {
+		staleAfter, writeTimeout string
+		want                     time.Duration
+	}{
+		{"120", "10", 2 * time.Minute},
+		// окно не короче WriteTimeout: иначе повтор перехватил бы ключ у идущего запроса
+		{"5", "30", 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Setenv("IDEMPOTENCY_STALE_AFTER", tt.staleAfter)
+		t.Setenv("SERVER_WRITE_TIMEOUT", tt.writeTimeout)
+		cfg, err := Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.IdempotencyStaleAfter != tt.want {
+			t.Errorf("IDEMPOTENCY_STALE_AFTER=%s, SERVER_WRITE_TIMEOUT=%s: got %v, want %v",
+				tt.staleAfter, tt.writeTimeout, cfg.IdempotencyStaleAfter, tt.want)
+		}
+	}
+}
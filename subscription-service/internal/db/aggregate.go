@@ -0,0 +1,303 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+// ErrNoExchangeRate — для валюты подписки нет курса к валюте итогов на нужный месяц
+var ErrNoExchangeRate = errors.New("exchange rate not found")
+
+// subscriptionMonthsCTE разворачивает подписки в строки «подписка × месяц» внутри окна
+// [$1, $2] (даты, включительно) с фильтрами $3 (user_id), $4 (service_name) и $7 (service_id). Подписка попадает
+// в месяц m, если активна в нём хотя бы один день окна; active_from/active_to — границы
+// этих дней, active_days — их число за вычетом дней на паузе. Месяцы, целиком проведённые
+// на паузе, в выборку не попадают. billable_days — активные дни вне пробного периода:
+// пробные дни бесплатны. price — цена, действовавшая в месяце m по истории цен.
+// cost — стоимость подписки в месяце, режим расчёта задаёт $6:
+//   - monthly: цена списывается по периоду оплаты от даты начала: monthly — каждый месяц,
+//     quarterly и yearly — раз в 3 и 12 месяцев, weekly — каждые 7 дней, попавшие в
+//     [active_from, active_to] (wk.charges; даты списания на паузе не оплачиваются);
+//     месяц без оплачиваемых дней (целиком в пробном периоде) бесплатен;
+//   - daily: цена, приведённая к месяцу, умножается на долю оплачиваемых дней месяца.
+//
+// rate — курс валюты подписки к валюте итогов $5, действующий в месяце m (последний
+// загруженный не позже m, прямой или обратный); NULL, если курса нет.
+// На этих строках построены все агрегаты, поэтому итоги разных режимов совпадают.
+const subscriptionMonthsCTE = `
+WITH sub_months AS (
+  SELECT s.id, s.service_name, pr.price, s.user_id, s.start_date, s.end_date, s.billing_period, s.currency,
+    s.category, s.tags,
+    m::date AS month,
+    b.active_from,
+    b.active_to,
+    b.active_to - b.active_from + 1 - pz.paused_days AS active_days,
+    CASE WHEN $6 = 'daily' THEN
+      pr.price * CASE s.billing_period
+        WHEN 'weekly' THEN 52 WHEN 'quarterly' THEN 4 WHEN 'yearly' THEN 1 ELSE 12
+      END / 12.0
+      * bd.billable_days / (b.month_end - m::date + 1)
+    WHEN bd.billable_days = 0 THEN 0
+    ELSE
+      pr.price * CASE s.billing_period
+        WHEN 'weekly' THEN wk.charges
+        WHEN 'quarterly' THEN CASE WHEN b.month_index % 3 = 0 THEN 1 ELSE 0 END
+        WHEN 'yearly' THEN CASE WHEN b.month_index % 12 = 0 THEN 1 ELSE 0 END
+        ELSE 1
+      END
+    END AS cost,
+    CASE WHEN s.currency = $5 THEN 1::numeric ELSE r.rate END AS rate
+  FROM generate_series(date_trunc('month', $1::date), $2::date, interval '1 month') AS m
+  JOIN subscriptions s
+    ON s.start_date <= LEAST((m + interval '1 month')::date - 1, $2::date)
+   AND (s.end_date IS NULL OR s.end_date >= GREATEST(m::date, $1::date))
+  CROSS JOIN LATERAL (
+    SELECT
+      (m + interval '1 month')::date - 1 AS month_end,
+      GREATEST(m::date, $1::date, s.start_date) AS active_from,
+      LEAST((m + interval '1 month')::date - 1, $2::date, COALESCE(s.end_date, $2::date)) AS active_to,
+      ((date_part('year', m) - date_part('year', s.start_date)) * 12
+        + date_part('month', m) - date_part('month', s.start_date))::int AS month_index
+  ) b
+  CROSS JOIN LATERAL (
+    SELECT COALESCE((
+      SELECT sp.price FROM subscription_prices sp
+      WHERE sp.subscription_id = s.id AND sp.effective_month <= m
+      ORDER BY sp.effective_month DESC
+      LIMIT 1
+    ), s.price) AS price
+  ) pr
+  CROSS JOIN LATERAL (
+    SELECT COALESCE(SUM(GREATEST(0,
+      LEAST(b.active_to, COALESCE(p.resumed_at - 1, b.active_to)) - GREATEST(b.active_from, p.paused_at) + 1
+    )), 0)::int AS paused_days
+    FROM subscription_pauses p
+    WHERE p.subscription_id = s.id
+  ) pz
+  CROSS JOIN LATERAL (
+    SELECT GREATEST(0, b.active_to - b.active_from + 1 - pz.paused_days
+      - GREATEST(0, LEAST(b.active_to, COALESCE(s.trial_end, b.active_from - 1)) - b.active_from + 1)
+    ) AS billable_days
+  ) bd
+  CROSS JOIN LATERAL (
+    SELECT COUNT(*)::int AS charges
+    FROM generate_series(
+      s.start_date + (GREATEST(b.active_from, s.trial_end + 1) - s.start_date + 6) / 7 * 7,
+      b.active_to,
+      interval '7 days') AS d
+    WHERE s.billing_period = 'weekly'
+      AND NOT EXISTS (
+        SELECT 1 FROM subscription_pauses p
+        WHERE p.subscription_id = s.id AND p.paused_at <= d AND (p.resumed_at IS NULL OR p.resumed_at > d)
+      )
+  ) wk
+  LEFT JOIN LATERAL (
+    SELECT x.rate FROM (
+      SELECT er.rate, er.month FROM exchange_rates er
+      WHERE er.base_currency = s.currency AND er.quote_currency = $5 AND er.month <= m
+      UNION ALL
+      SELECT 1 / er.rate, er.month FROM exchange_rates er
+      WHERE er.base_currency = $5 AND er.quote_currency = s.currency AND er.month <= m
+    ) x
+    ORDER BY x.month DESC
+    LIMIT 1
+  ) r ON true
+  WHERE ($3::uuid IS NULL OR s.user_id = $3::uuid)
+    AND ($4::text IS NULL OR s.service_name ILIKE $4::text)
+    AND ($7::uuid IS NULL OR s.service_id = $7::uuid)
+    AND pz.paused_days < b.active_to - b.active_from + 1
+)
+`
+
+// missingRateSQL — первая по месяцу, затем по валюте строка окна без курса к валюте итогов,
+// в виде "YYYY-MM CUR", или NULL. Выражение для запросов с GROUP BY: оконная функция
+// поверх агрегата считается по всей выборке до выдачи первой строки, поэтому каждая строка
+// уже знает, хватило ли курсов, и отдельный проход по sub_months для проверки не нужен.
+const missingRateSQL = `MIN(MIN(to_char(month, 'YYYY-MM') || ' ' || currency) FILTER (WHERE rate IS NULL)) OVER () AS missing_rate`
+
+// overlappingQuery — подписки окна с числом активных месяцев и стоимостью, по одной строке на подписку.
+// Без единого курса SUM(cost * rate) — NULL: такая строка сканируется как 0, а ошибку даёт missing_rate.
+const overlappingQuery = subscriptionMonthsCTE + `
+    SELECT id, service_name, user_id, start_date, end_date, billing_period, currency, category, tags,
+      ` + missingRateSQL + `,
+      (array_agg(price ORDER BY month DESC))[1] AS price,
+      COUNT(*)::int AS months,
+      SUM(active_days)::int AS days,
+      round(SUM(cost))::bigint AS cost,
+      COALESCE(round(SUM(cost * rate)), 0)::bigint AS converted_cost,
+      json_agg(json_build_object('month', to_char(month, 'MM-YYYY'), 'rate', rate) ORDER BY month)
+        FILTER (WHERE currency <> $5) AS rates
+    FROM sub_months
+    GROUP BY id, service_name, user_id, start_date, end_date, billing_period, currency, category, tags
+    ORDER BY service_name, start_date
+    `
+
+// usageRow — строка overlappingQuery вместе с признаком нехватки курса
+type usageRow struct {
+	model.SubscriptionUsage
+	MissingRate *string `db:"missing_rate"`
+}
+
+func (s *store) AggregateTotal(ctx context.Context, f model.AggregateFilter) (int64, error) {
+	// округляем по каждой подписке, как и в FindSubscriptionsOverlapping, чтобы итоги совпадали
+	query := subscriptionMonthsCTE + `
+    SELECT COALESCE(SUM(converted), 0)::bigint AS total, MIN(missing) AS missing_rate FROM (
+      SELECT id, round(SUM(cost * rate)) AS converted,
+        MIN(to_char(month, 'YYYY-MM') || ' ' || currency) FILTER (WHERE rate IS NULL) AS missing
+      FROM sub_months
+      GROUP BY id
+    ) t
+    `
+
+	var row struct {
+		Total       int64   `db:"total"`
+		MissingRate *string `db:"missing_rate"`
+	}
+	if err := s.db.GetContext(ctx, &row, query, aggregateArgs(f)...); err != nil {
+		return 0, err
+	}
+	if err := rateError(row.MissingRate, f); err != nil {
+		return 0, err
+	}
+	return row.Total, nil
+}
+
+// FindSubscriptionsOverlapping возвращает подписки, пересекающиеся с окном, вместе с числом
+// активных месяцев, стоимостью внутри окна и её пересчётом в валюту итогов.
+// price — цена в последнем активном месяце окна.
+func (s *store) FindSubscriptionsOverlapping(ctx context.Context, f model.AggregateFilter) ([]*model.SubscriptionUsage, error) {
+	rows := []usageRow{}
+	if err := s.db.SelectContext(ctx, &rows, overlappingQuery, aggregateArgs(f)...); err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 {
+		if err := rateError(rows[0].MissingRate, f); err != nil {
+			return nil, err
+		}
+	}
+
+	subs := make([]*model.SubscriptionUsage, len(rows))
+	for i := range rows {
+		subs[i] = &rows[i].SubscriptionUsage
+	}
+	return subs, nil
+}
+
+// StreamSubscriptionsOverlapping — то же, что FindSubscriptionsOverlapping, но строки читаются
+// курсором и передаются fn по одной. Нехватка курсов видна уже в первой строке, поэтому
+// ErrNoExchangeRate возвращается раньше, чем fn вызвана хоть раз.
+func (s *store) StreamSubscriptionsOverlapping(ctx context.Context, f model.AggregateFilter, fn func(*model.SubscriptionUsage) error) error {
+	rows, err := s.db.QueryxContext(ctx, overlappingQuery, aggregateArgs(f)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	first := true
+	for rows.Next() {
+		var row usageRow
+		if err := rows.StructScan(&row); err != nil {
+			return err
+		}
+		if first {
+			if err := rateError(row.MissingRate, f); err != nil {
+				return err
+			}
+			first = false
+		}
+		if err := fn(&row.SubscriptionUsage); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// AggregateMonthly раскладывает стоимость подписок по месяцам окна
+// в валюте итогов. Сумма по всем месяцам совпадает с AggregateTotal с точностью до округления.
+func (s *store) AggregateMonthly(ctx context.Context, f model.AggregateFilter) ([]model.MonthServiceTotal, error) {
+	query := subscriptionMonthsCTE + `
+    SELECT month, service_name, COALESCE(round(SUM(cost * rate)), 0)::bigint AS total, ` + missingRateSQL + `
+    FROM sub_months
+    GROUP BY month, service_name
+    ORDER BY month, service_name
+    `
+
+	var rows []struct {
+		model.MonthServiceTotal
+		MissingRate *string `db:"missing_rate"`
+	}
+	if err := s.db.SelectContext(ctx, &rows, query, aggregateArgs(f)...); err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 {
+		if err := rateError(rows[0].MissingRate, f); err != nil {
+			return nil, err
+		}
+	}
+
+	totals := make([]model.MonthServiceTotal, len(rows))
+	for i := range rows {
+		totals[i] = rows[i].MonthServiceTotal
+	}
+	return totals, nil
+}
+
+// rateError превращает missing_rate из запроса агрегации ("YYYY-MM CUR") в ErrNoExchangeRate;
+// nil — курсов хватило на все строки окна
+func rateError(missing *string, f model.AggregateFilter) error {
+	if missing == nil {
+		return nil
+	}
+	month, currency, _ := strings.Cut(*missing, " ")
+	if t, err := time.Parse("2006-01", month); err == nil {
+		month = t.Format("01-2006")
+	}
+	return fmt.Errorf("%w: %s to %s for %s", ErrNoExchangeRate, currency, f.Currency, month)
+}
+
+// UpsertExchangeRates загружает курсы валют; курс на тот же месяц перезаписывается
+func (s *store) UpsertExchangeRates(ctx context.Context, rates []model.ExchangeRate) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	query := `
+		INSERT INTO exchange_rates (base_currency, quote_currency, month, rate)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (base_currency, quote_currency, month) DO UPDATE SET rate = EXCLUDED.rate
+	`
+	for _, r := range rates {
+		if _, err := tx.ExecContext(ctx, query, r.Base, r.Quote, r.Month, r.Rate); err != nil {
+			return err
+		}
+	}
+	return tx.commit()
+}
+
+// aggregateArgs превращает фильтр агрегации в аргументы запроса; nil — фильтр не задан
+func aggregateArgs(f model.AggregateFilter) []interface{} {
+	var uid interface{} = nil
+	var sname interface{} = nil
+	var sid interface{} = nil
+	if f.UserID != nil && *f.UserID != "" {
+		uid = *f.UserID
+	}
+	if f.ServiceName != nil && *f.ServiceName != "" {
+		sname = containsPattern(*f.ServiceName)
+	}
+	if f.ServiceID != nil && *f.ServiceID != "" {
+		sid = *f.ServiceID
+	}
+	proration := f.Proration
+	if proration == "" {
+		proration = model.ProrationMonthly
+	}
+	return []interface{}{f.From, f.To, uid, sname, f.Currency, string(proration), sid}
+}
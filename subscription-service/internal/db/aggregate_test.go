@@ -0,0 +1,24 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"subscription-service/internal/model"
+)
+
+func TestRateError(t *testing.T) {
+	f := model.AggregateFilter{Currency: "RUB"}
+	if err := rateError(nil, f); err != nil {
+		t.Errorf("rateError(nil) = %v, want nil", err)
+	}
+
+	missing := "2025-07 USD"
+	err := rateError(&missing, f)
+	if !errors.Is(err, ErrNoExchangeRate) {
+		t.Fatalf("rateError(%q) = %v, want ErrNoExchangeRate", missing, err)
+	}
+	if want := "exchange rate not found: USD to RUB for 07-2025"; err.Error() != want {
+		t.Errorf("rateError(%q) = %q, want %q", missing, err.Error(), want)
+	}
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"subscription-service/internal/model"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrDuplicate — нарушено ограничение уникальности (например, название услуги уже занято)
+var ErrDuplicate = errors.New("duplicate value")
+
+// catalogColumns — колонки услуги каталога вместе с её псевдонимами
+const catalogColumns = `id, name, category, default_price, currency,
+	ARRAY(SELECT a.alias FROM service_aliases a WHERE a.service_id = services.id ORDER BY a.alias) AS aliases`
+
+// uniqueViolation переводит ошибку уникальности Postgres в ErrDuplicate
+func uniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *store) CreateService(ctx context.Context, svc *model.CatalogService) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	query := `
+		INSERT INTO services (name, category, default_price, currency)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+	if err := tx.QueryRowContext(ctx, query,
+		svc.Name, svc.Category, svc.DefaultPrice, svc.Currency,
+	).Scan(&svc.ID); err != nil {
+		return uniqueViolation(err)
+	}
+	if err := insertAliases(ctx, tx.Tx, svc); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+func (s *store) GetService(ctx context.Context, id string) (*model.CatalogService, error) {
+	query := `SELECT ` + catalogColumns + `
+		FROM services
+		WHERE id = $1
+	`
+	var svc model.CatalogService
+	if err := s.db.GetContext(ctx, &svc, query, id); err != nil {
+		return nil, err
+	}
+	return &svc, nil
+}
+
+func (s *store) ListServices(ctx context.Context) ([]*model.CatalogService, error) {
+	query := `SELECT ` + catalogColumns + `
+		FROM services
+		ORDER BY name
+	`
+	rows := []*model.CatalogService{}
+	if err := s.db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// UpdateService перезаписывает услугу и её псевдонимы. Новое название сразу
+// переносится в подписки услуги, чтобы они группировались под каноническим именем.
+func (s *store) UpdateService(ctx context.Context, svc *model.CatalogService) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	query := `
+		UPDATE services
+		SET name = $1, category = $2, default_price = $3, currency = $4
+		WHERE id = $5
+	`
+	res, err := tx.ExecContext(ctx, query, svc.Name, svc.Category, svc.DefaultPrice, svc.Currency, svc.ID)
+	if err != nil {
+		return uniqueViolation(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM service_aliases WHERE service_id = $1`, svc.ID); err != nil {
+		return err
+	}
+	if err := insertAliases(ctx, tx.Tx, svc); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE subscriptions SET service_name = $1, version = version + 1, updated_at = now()
+		WHERE service_id = $2 AND service_name <> $1`,
+		svc.Name, svc.ID,
+	); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+// DeleteService удаляет услугу; подписки остаются со своим названием, но без ссылки на каталог
+func (s *store) DeleteService(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM services WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ResolveService ищет услугу по названию или псевдониму без учёта регистра и пробелов;
+// точное совпадение названия важнее псевдонима. sql.ErrNoRows — такой услуги нет.
+func (s *store) ResolveService(ctx context.Context, name string) (*model.CatalogService, error) {
+	query := `SELECT ` + catalogColumns + `
+		FROM services
+		WHERE lower(name) = $1
+		   OR id = (SELECT service_id FROM service_aliases WHERE alias = $1)
+		ORDER BY lower(name) = $1 DESC
+		LIMIT 1
+	`
+	var svc model.CatalogService
+	if err := s.db.GetContext(ctx, &svc, query, model.NormalizeAlias(name)); err != nil {
+		return nil, err
+	}
+	return &svc, nil
+}
+
+// GetServices возвращает услуги с данными id одним запросом; ключ — id услуги.
+// Услуг, которых нет в каталоге, в ответе нет.
+func (s *store) GetServices(ctx context.Context, ids []string) (map[string]*model.CatalogService, error) {
+	query := `SELECT ` + catalogColumns + `
+		FROM services
+		WHERE id = ANY($1::uuid[])
+	`
+	rows := []*model.CatalogService{}
+	if err := s.db.SelectContext(ctx, &rows, query, pq.StringArray(ids)); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*model.CatalogService, len(rows))
+	for _, svc := range rows {
+		byID[svc.ID] = svc
+	}
+	return byID, nil
+}
+
+// ResolveServices — ResolveService для многих названий одним запросом; ключ ответа —
+// название, приведённое model.NormalizeAlias. Ненайденных названий в ответе нет.
+func (s *store) ResolveServices(ctx context.Context, names []string) (map[string]*model.CatalogService, error) {
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = model.NormalizeAlias(name)
+	}
+	query := `SELECT DISTINCT ON (n.key) n.key, ` + catalogColumns + `
+		FROM unnest($1::text[]) AS n(key)
+		JOIN services
+		  ON lower(name) = n.key
+		  OR id = (SELECT service_id FROM service_aliases WHERE alias = n.key)
+		ORDER BY n.key, lower(name) = n.key DESC
+	`
+	var rows []struct {
+		Key string `db:"key"`
+		model.CatalogService
+	}
+	if err := s.db.SelectContext(ctx, &rows, query, pq.StringArray(keys)); err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*model.CatalogService, len(rows))
+	for i := range rows {
+		byName[rows[i].Key] = &rows[i].CatalogService
+	}
+	return byName, nil
+}
+
+func insertAliases(ctx context.Context, tx *sqlx.Tx, svc *model.CatalogService) error {
+	for _, alias := range svc.Aliases {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO service_aliases (alias, service_id) VALUES ($1, $2)`,
+			model.NormalizeAlias(alias), svc.ID,
+		); err != nil {
+			return uniqueViolation(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"subscription-service/internal/dbtest"
+	"subscription-service/internal/model"
+)
+
+func TestResolveServices(t *testing.T) {
+	repo := NewStore(dbtest.Open(t))
+	ctx := context.Background()
+	create := func(name string, aliases ...string) string {
+		t.Helper()
+		svc := &model.CatalogService{Name: name, Aliases: aliases, Currency: "RUB"}
+		if err := repo.CreateService(ctx, svc); err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		return svc.ID
+	}
+	yandex := create("Yandex Plus", "яндекс плюс", "plus")
+	netflix := create("Netflix", "нетфликс")
+	// у одной услуги название совпадает с псевдонимом другой
+	plus := create("Plus")
+
+	byName, err := repo.ResolveServices(ctx, []string{"  YANDEX   plus ", "Нетфликс", "plus", "Spotify"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"yandex plus": yandex, "нетфликс": netflix, "plus": plus}
+	if len(byName) != len(want) {
+		t.Errorf("ResolveServices() = %d services, want %d: %v", len(byName), len(want), byName)
+	}
+	for key, id := range want {
+		if svc := byName[key]; svc == nil || svc.ID != id {
+			t.Errorf("ResolveServices()[%q] = %+v, want %s", key, svc, id)
+		}
+	}
+	if svc := byName["нетфликс"]; svc != nil && (svc.Name != "Netflix" || len(svc.Aliases) != 1) {
+		t.Errorf("resolved service %+v is not filled", svc)
+	}
+
+	byID, err := repo.GetServices(ctx, []string{netflix, "00000000-0000-0000-0000-000000000000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byID) != 1 || byID[netflix] == nil || byID[netflix].Name != "Netflix" {
+		t.Errorf("GetServices() = %v, want only Netflix", byID)
+	}
+}
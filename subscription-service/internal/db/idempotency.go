@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+// purgeBatch — сколько истёкших ключей удаляет одно занятие ключа. Каждое занятие добавляет не
+// больше одной записи, поэтому пачки хватает, чтобы таблица не росла, а сам запрос остаётся
+// коротким и не зависит от размера таблицы.
+const purgeBatch = 100
+
+// ClaimIdempotencyKey занимает ключ под выполнение запроса и возвращает nil, если ключ
+// свободен, истёк или был брошен незавершённым дольше staleAfter (например, процесс упал).
+// Иначе возвращает существующую запись: с сохранённым ответом или ещё выполняющуюся.
+// Занятие — одна вставка с ON CONFLICT, поэтому из параллельных повторов ключ получит только один.
+// Заодно удаляется не больше purgeBatch чужих истёкших ключей; строки, которые уже чистит
+// параллельный запрос, пропускаются. Брошенные незавершённые ключи не удаляются: их перехватывает
+// только повтор с тем же ключом.
+func (s *store) ClaimIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord, ttl, staleAfter time.Duration) (*model.IdempotencyRecord, error) {
+	purge := `
+		DELETE FROM idempotency_keys WHERE ctid IN (
+			SELECT ctid FROM idempotency_keys
+			WHERE expires_at < now() AND NOT (scope = $1 AND key = $2)
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+	`
+	if _, err := s.db.ExecContext(ctx, purge, rec.Scope, rec.Key, purgeBatch); err != nil {
+		return nil, err
+	}
+
+	claim := `
+		INSERT INTO idempotency_keys (scope, key, request_hash, expires_at)
+		VALUES ($1, $2, $3, now() + $4::int * interval '1 second')
+		ON CONFLICT (scope, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, status_code = NULL, response_headers = NULL,
+			response_body = NULL, created_at = now(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < now()
+		   OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < now() - $5::int * interval '1 second')
+		RETURNING key
+	`
+	var key string
+	err := s.db.QueryRowContext(ctx, claim,
+		rec.Scope, rec.Key, rec.RequestHash, int(ttl.Seconds()), int(staleAfter.Seconds()),
+	).Scan(&key)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	var existing model.IdempotencyRecord
+	if err := s.db.GetContext(ctx, &existing, `
+		SELECT scope, key, request_hash, status_code, response_headers, response_body
+		FROM idempotency_keys
+		WHERE scope = $1 AND key = $2
+	`, rec.Scope, rec.Key); err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// CompleteIdempotencyKey сохраняет ответ на запрос, занявший ключ
+func (s *store) CompleteIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $4, response_headers = $5, response_body = $6
+		WHERE scope = $1 AND key = $2 AND request_hash = $3 AND status_code IS NULL
+	`
+	return s.execOne(ctx, query, rec.Scope, rec.Key, rec.RequestHash, rec.StatusCode, rec.Headers, rec.Body)
+}
+
+// ReleaseIdempotencyKey освобождает незавершённый ключ, чтобы запрос можно было повторить
+func (s *store) ReleaseIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND request_hash = $3 AND status_code IS NULL`,
+		rec.Scope, rec.Key, rec.RequestHash,
+	)
+	return err
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"subscription-service/internal/dbtest"
+	"subscription-service/internal/model"
+)
+
+func TestClaimIdempotencyKey(t *testing.T) {
+	conn := dbtest.Open(t)
+	repo := NewStore(conn)
+	ctx := context.Background()
+	const ttl, staleAfter = time.Hour, time.Minute
+
+	claim := func(key, hash string) *model.IdempotencyRecord {
+		t.Helper()
+		existing, err := repo.ClaimIdempotencyKey(ctx, &model.IdempotencyRecord{Scope: "POST /subscriptions", Key: key, RequestHash: hash}, ttl, staleAfter)
+		if err != nil {
+			t.Fatalf("claim %s: %v", key, err)
+		}
+		return existing
+	}
+
+	if rec := claim("k1", "h1"); rec != nil {
+		t.Fatalf("free key: got existing record %+v", rec)
+	}
+	// повтор до завершения видит запись без ответа
+	if rec := claim("k1", "h1"); rec == nil || rec.StatusCode != nil {
+		t.Fatalf("key in progress: got %+v", rec)
+	}
+
+	status := 201
+	done := &model.IdempotencyRecord{Scope: "POST /subscriptions", Key: "k1", RequestHash: "h1",
+		StatusCode: &status, Headers: model.ResponseHeaders{"Location": "/subscriptions/1"}, Body: []byte(`{"id":"1"}`)}
+	if err := repo.CompleteIdempotencyKey(ctx, done); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	rec := claim("k1", "h2")
+	if rec == nil || rec.StatusCode == nil || *rec.StatusCode != 201 || rec.RequestHash != "h1" ||
+		rec.Headers["Location"] != "/subscriptions/1" || string(rec.Body) != `{"id":"1"}` {
+		t.Fatalf("completed key: got %+v", rec)
+	}
+	// завершённый ключ нельзя завершить повторно
+	if err := repo.CompleteIdempotencyKey(ctx, done); err == nil {
+		t.Error("second complete: want error")
+	}
+
+	// истёкший ключ занимается заново
+	if _, err := conn.Exec(`UPDATE idempotency_keys SET expires_at = now() - interval '1 second' WHERE key = 'k1'`); err != nil {
+		t.Fatal(err)
+	}
+	if rec := claim("k1", "h2"); rec != nil {
+		t.Errorf("expired key: got existing record %+v", rec)
+	}
+
+	// незавершённый ключ, брошенный дольше staleAfter, тоже
+	claim("k2", "h1")
+	if _, err := conn.Exec(`UPDATE idempotency_keys SET created_at = now() - interval '2 minutes' WHERE key = 'k2'`); err != nil {
+		t.Fatal(err)
+	}
+	if rec := claim("k2", "h1"); rec != nil {
+		t.Errorf("stale key: got existing record %+v", rec)
+	}
+
+	// освобождённый ключ свободен сразу
+	if err := repo.ReleaseIdempotencyKey(ctx, &model.IdempotencyRecord{Scope: "POST /subscriptions", Key: "k2", RequestHash: "h1"}); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if rec := claim("k2", "h3"); rec != nil {
+		t.Errorf("released key: got existing record %+v", rec)
+	}
+
+	// чистка за одно занятие ограничена purgeBatch, брошенные чужие ключи она не трогает
+	if _, err := conn.Exec(`
+		INSERT INTO idempotency_keys (scope, key, request_hash, expires_at)
+		SELECT 'POST /subscriptions', 'old' || i, 'h', now() - interval '1 hour' FROM generate_series(1, $1) AS i
+	`, purgeBatch+50); err != nil {
+		t.Fatal(err)
+	}
+	countExpired := func() int {
+		t.Helper()
+		var n int
+		if err := conn.Get(&n, `SELECT COUNT(*) FROM idempotency_keys WHERE expires_at < now()`); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	claim("k3", "h1")
+	if n := countExpired(); n != 50 {
+		t.Errorf("after one claim: %d expired keys left, want 50", n)
+	}
+	if _, err := conn.Exec(`UPDATE idempotency_keys SET created_at = now() - interval '2 minutes' WHERE key = 'k3'`); err != nil {
+		t.Fatal(err)
+	}
+	claim("k4", "h1")
+	if n := countExpired(); n != 0 {
+		t.Errorf("after two claims: %d expired keys left, want 0", n)
+	}
+	var stale int
+	if err := conn.Get(&stale, `SELECT COUNT(*) FROM idempotency_keys WHERE key = 'k3' AND status_code IS NULL`); err != nil {
+		t.Fatal(err)
+	}
+	if stale != 1 {
+		t.Error("claiming another key removed the abandoned key k3")
+	}
+}
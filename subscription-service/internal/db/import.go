@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+
+	"subscription-service/internal/model"
+
+	"github.com/lib/pq"
+)
+
+// importColumns — колонки подписки, которые приходят из файла импорта
+var importColumns = []string{
+	"service_id", "service_name", "price", "user_id", "start_date", "end_date",
+	"billing_period", "currency", "status", "trial_end", "category", "tags",
+}
+
+// ImportSubscriptions записывает подписки одной транзакцией: строки потоком идут через
+// COPY во временную таблицу, откуда одним INSERT ... SELECT попадают в subscriptions
+// вместе с начальной ценой в истории цен. Возвращает число записанных подписок.
+func (s *store) ImportSubscriptions(ctx context.Context, subs []*model.Subscription) (int64, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		CREATE TEMP TABLE import_subscriptions (
+			service_id UUID, service_name TEXT, price INTEGER, user_id UUID, start_date DATE, end_date DATE,
+			billing_period TEXT, currency TEXT, status TEXT, trial_end DATE, category TEXT, tags TEXT[]
+		) ON COMMIT DROP
+	`); err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("import_subscriptions", importColumns...))
+	if err != nil {
+		return 0, err
+	}
+	for _, sub := range subs {
+		if _, err := stmt.ExecContext(ctx,
+			sub.ServiceID, sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate,
+			sub.BillingPeriod, sub.Currency, sub.Status, sub.TrialEnd, sub.Category, sub.Tags,
+		); err != nil {
+			_ = stmt.Close()
+			return 0, err
+		}
+	}
+	// COPY завершается вызовом без аргументов
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		_ = stmt.Close()
+		return 0, err
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		WITH ins AS (
+			INSERT INTO subscriptions (service_id, service_name, price, user_id, start_date, end_date,
+				billing_period, currency, status, trial_end, category, tags)
+			SELECT service_id, service_name, price, user_id, start_date, end_date,
+				billing_period, currency, status, trial_end, category, COALESCE(tags, '{}')
+			FROM import_subscriptions
+			RETURNING id, price, start_date
+		)
+		INSERT INTO subscription_prices (subscription_id, effective_month, price)
+		SELECT id, date_trunc('month', start_date)::date, price FROM ins
+	`)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.commit()
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+// QueryHook вызывается перед каждым методом репозитория с его именем и возвращает контекст
+// для вызова и функцию, которую нужно вызвать с результатом по завершении
+type QueryHook func(ctx context.Context, query string) (context.Context, func(err error))
+
+// Instrument оборачивает репозиторий так, что каждый его метод проходит через hook.
+// Репозиторий внутри WithTx обёрнут тем же hook.
+func Instrument(repo Repository, hook QueryHook) Repository {
+	return &instrumented{next: repo, hook: hook}
+}
+
+type instrumented struct {
+	next Repository
+	hook QueryHook
+}
+
+func (r *instrumented) Create(ctx context.Context, sub *model.Subscription) (err error) {
+	ctx, done := r.hook(ctx, "Create")
+	defer func() { done(err) }()
+	return r.next.Create(ctx, sub)
+}
+
+func (r *instrumented) GetByID(ctx context.Context, id string) (_ *model.Subscription, err error) {
+	ctx, done := r.hook(ctx, "GetByID")
+	defer func() { done(err) }()
+	return r.next.GetByID(ctx, id)
+}
+
+func (r *instrumented) List(ctx context.Context, f model.ListFilter) (_ []*model.Subscription, err error) {
+	ctx, done := r.hook(ctx, "List")
+	defer func() { done(err) }()
+	return r.next.List(ctx, f)
+}
+
+func (r *instrumented) StreamSubscriptions(ctx context.Context, f model.ListFilter, fn func(*model.Subscription) error) (err error) {
+	ctx, done := r.hook(ctx, "StreamSubscriptions")
+	defer func() { done(err) }()
+	return r.next.StreamSubscriptions(ctx, f, fn)
+}
+
+func (r *instrumented) Count(ctx context.Context, f model.ListFilter) (_ int64, err error) {
+	ctx, done := r.hook(ctx, "Count")
+	defer func() { done(err) }()
+	return r.next.Count(ctx, f)
+}
+
+func (r *instrumented) Update(ctx context.Context, sub *model.Subscription) (err error) {
+	ctx, done := r.hook(ctx, "Update")
+	defer func() { done(err) }()
+	return r.next.Update(ctx, sub)
+}
+
+func (r *instrumented) Delete(ctx context.Context, id string, version int) (err error) {
+	ctx, done := r.hook(ctx, "Delete")
+	defer func() { done(err) }()
+	return r.next.Delete(ctx, id, version)
+}
+
+func (r *instrumented) AggregateTotal(ctx context.Context, f model.AggregateFilter) (_ int64, err error) {
+	ctx, done := r.hook(ctx, "AggregateTotal")
+	defer func() { done(err) }()
+	return r.next.AggregateTotal(ctx, f)
+}
+
+func (r *instrumented) FindSubscriptionsOverlapping(ctx context.Context, f model.AggregateFilter) (_ []*model.SubscriptionUsage, err error) {
+	ctx, done := r.hook(ctx, "FindSubscriptionsOverlapping")
+	defer func() { done(err) }()
+	return r.next.FindSubscriptionsOverlapping(ctx, f)
+}
+
+func (r *instrumented) StreamSubscriptionsOverlapping(ctx context.Context, f model.AggregateFilter, fn func(*model.SubscriptionUsage) error) (err error) {
+	ctx, done := r.hook(ctx, "StreamSubscriptionsOverlapping")
+	defer func() { done(err) }()
+	return r.next.StreamSubscriptionsOverlapping(ctx, f, fn)
+}
+
+func (r *instrumented) AggregateMonthly(ctx context.Context, f model.AggregateFilter) (_ []model.MonthServiceTotal, err error) {
+	ctx, done := r.hook(ctx, "AggregateMonthly")
+	defer func() { done(err) }()
+	return r.next.AggregateMonthly(ctx, f)
+}
+
+func (r *instrumented) UpsertExchangeRates(ctx context.Context, rates []model.ExchangeRate) (err error) {
+	ctx, done := r.hook(ctx, "UpsertExchangeRates")
+	defer func() { done(err) }()
+	return r.next.UpsertExchangeRates(ctx, rates)
+}
+
+func (r *instrumented) Pause(ctx context.Context, id string, from model.Status, at time.Time) (err error) {
+	ctx, done := r.hook(ctx, "Pause")
+	defer func() { done(err) }()
+	return r.next.Pause(ctx, id, from, at)
+}
+
+func (r *instrumented) Resume(ctx context.Context, id string, at time.Time) (err error) {
+	ctx, done := r.hook(ctx, "Resume")
+	defer func() { done(err) }()
+	return r.next.Resume(ctx, id, at)
+}
+
+func (r *instrumented) Cancel(ctx context.Context, id string, from model.Status, at time.Time) (err error) {
+	ctx, done := r.hook(ctx, "Cancel")
+	defer func() { done(err) }()
+	return r.next.Cancel(ctx, id, from, at)
+}
+
+func (r *instrumented) SetPrice(ctx context.Context, id string, month time.Time, price int) (err error) {
+	ctx, done := r.hook(ctx, "SetPrice")
+	defer func() { done(err) }()
+	return r.next.SetPrice(ctx, id, month, price)
+}
+
+func (r *instrumented) PriceHistory(ctx context.Context, id string) (_ []model.PriceChange, err error) {
+	ctx, done := r.hook(ctx, "PriceHistory")
+	defer func() { done(err) }()
+	return r.next.PriceHistory(ctx, id)
+}
+
+func (r *instrumented) CreateService(ctx context.Context, svc *model.CatalogService) (err error) {
+	ctx, done := r.hook(ctx, "CreateService")
+	defer func() { done(err) }()
+	return r.next.CreateService(ctx, svc)
+}
+
+func (r *instrumented) GetService(ctx context.Context, id string) (_ *model.CatalogService, err error) {
+	ctx, done := r.hook(ctx, "GetService")
+	defer func() { done(err) }()
+	return r.next.GetService(ctx, id)
+}
+
+func (r *instrumented) ListServices(ctx context.Context) (_ []*model.CatalogService, err error) {
+	ctx, done := r.hook(ctx, "ListServices")
+	defer func() { done(err) }()
+	return r.next.ListServices(ctx)
+}
+
+func (r *instrumented) UpdateService(ctx context.Context, svc *model.CatalogService) (err error) {
+	ctx, done := r.hook(ctx, "UpdateService")
+	defer func() { done(err) }()
+	return r.next.UpdateService(ctx, svc)
+}
+
+func (r *instrumented) DeleteService(ctx context.Context, id string) (err error) {
+	ctx, done := r.hook(ctx, "DeleteService")
+	defer func() { done(err) }()
+	return r.next.DeleteService(ctx, id)
+}
+
+func (r *instrumented) ResolveService(ctx context.Context, name string) (_ *model.CatalogService, err error) {
+	ctx, done := r.hook(ctx, "ResolveService")
+	defer func() { done(err) }()
+	return r.next.ResolveService(ctx, name)
+}
+
+func (r *instrumented) GetServices(ctx context.Context, ids []string) (_ map[string]*model.CatalogService, err error) {
+	ctx, done := r.hook(ctx, "GetServices")
+	defer func() { done(err) }()
+	return r.next.GetServices(ctx, ids)
+}
+
+func (r *instrumented) ResolveServices(ctx context.Context, names []string) (_ map[string]*model.CatalogService, err error) {
+	ctx, done := r.hook(ctx, "ResolveServices")
+	defer func() { done(err) }()
+	return r.next.ResolveServices(ctx, names)
+}
+
+func (r *instrumented) ClaimIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord, ttl, staleAfter time.Duration) (_ *model.IdempotencyRecord, err error) {
+	ctx, done := r.hook(ctx, "ClaimIdempotencyKey")
+	defer func() { done(err) }()
+	return r.next.ClaimIdempotencyKey(ctx, rec, ttl, staleAfter)
+}
+
+func (r *instrumented) CompleteIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) (err error) {
+	ctx, done := r.hook(ctx, "CompleteIdempotencyKey")
+	defer func() { done(err) }()
+	return r.next.CompleteIdempotencyKey(ctx, rec)
+}
+
+func (r *instrumented) ReleaseIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) (err error) {
+	ctx, done := r.hook(ctx, "ReleaseIdempotencyKey")
+	defer func() { done(err) }()
+	return r.next.ReleaseIdempotencyKey(ctx, rec)
+}
+
+// WithTx измеряет всю транзакцию целиком, а методы репозитория внутри неё — по отдельности
+func (r *instrumented) WithTx(ctx context.Context, fn func(repo Repository) error) (err error) {
+	ctx, done := r.hook(ctx, "WithTx")
+	defer func() { done(err) }()
+	return r.next.WithTx(ctx, func(tx Repository) error {
+		return fn(&instrumented{next: tx, hook: r.hook})
+	})
+}
+
+func (r *instrumented) ImportSubscriptions(ctx context.Context, subs []*model.Subscription) (_ int64, err error) {
+	ctx, done := r.hook(ctx, "ImportSubscriptions")
+	defer func() { done(err) }()
+	return r.next.ImportSubscriptions(ctx, subs)
+}
+
+func (r *instrumented) Search(ctx context.Context, query string, f model.ListFilter) (_ []*model.SearchHit, err error) {
+	ctx, done := r.hook(ctx, "Search")
+	defer func() { done(err) }()
+	return r.next.Search(ctx, query, f)
+}
+
+func (r *instrumented) Stats(ctx context.Context, today time.Time) (_ *model.Stats, err error) {
+	ctx, done := r.hook(ctx, "Stats")
+	defer func() { done(err) }()
+	return r.next.Stats(ctx, today)
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"subscription-service/internal/model"
+)
+
+// fakeRepo реализует только методы, которые вызывает тест
+type fakeRepo struct {
+	Repository
+	err error
+}
+
+func (r *fakeRepo) GetByID(ctx context.Context, id string) (*model.Subscription, error) {
+	return &model.Subscription{ID: id}, r.err
+}
+
+func (r *fakeRepo) WithTx(ctx context.Context, fn func(repo Repository) error) error {
+	return fn(r)
+}
+
+func TestInstrument(t *testing.T) {
+	var calls []string
+	var results []error
+	hook := func(ctx context.Context, query string) (context.Context, func(err error)) {
+		calls = append(calls, query)
+		return ctx, func(err error) { results = append(results, err) }
+	}
+
+	fake := &fakeRepo{}
+	repo := Instrument(fake, hook)
+	ctx := context.Background()
+	if sub, err := repo.GetByID(ctx, "1"); err != nil || sub.ID != "1" {
+		t.Fatalf("GetByID() = %+v, %v", sub, err)
+	}
+
+	fake.err = errors.New("connection reset")
+	txErr := repo.WithTx(ctx, func(tx Repository) error {
+		_, err := tx.GetByID(ctx, "2")
+		return err
+	})
+	if txErr != fake.err {
+		t.Fatalf("WithTx() error = %v", txErr)
+	}
+
+	// методы внутри транзакции измеряются по отдельности, транзакция — целиком
+	if want := []string{"GetByID", "WithTx", "GetByID"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("hook calls = %v, want %v", calls, want)
+	}
+	if want := []error{nil, fake.err, fake.err}; !reflect.DeepEqual(results, want) {
+		t.Errorf("hook results = %v, want %v", results, want)
+	}
+}
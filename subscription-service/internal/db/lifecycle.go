@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+// Pause переводит подписку из статуса from в paused и открывает интервал паузы с даты at.
+// Если статус подписки уже не from, возвращает sql.ErrNoRows.
+func (s *store) Pause(ctx context.Context, id string, from model.Status, at time.Time) error {
+	query := `
+		WITH upd AS (
+			UPDATE subscriptions SET status = 'paused', version = version + 1, updated_at = now()
+			WHERE id = $1 AND status = $2
+			RETURNING id
+		)
+		INSERT INTO subscription_pauses (subscription_id, paused_at)
+		SELECT id, $3::date FROM upd
+	`
+	return s.execOne(ctx, query, id, from, at)
+}
+
+// Resume возвращает приостановленную подписку в active и закрывает открытую паузу датой at
+func (s *store) Resume(ctx context.Context, id string, at time.Time) error {
+	query := `
+		WITH upd AS (
+			UPDATE subscriptions SET status = 'active', version = version + 1, updated_at = now()
+			WHERE id = $1 AND status = 'paused'
+			RETURNING id
+		), closed AS (
+			UPDATE subscription_pauses p SET resumed_at = GREATEST($2::date, p.paused_at)
+			FROM upd
+			WHERE p.subscription_id = upd.id AND p.resumed_at IS NULL
+		)
+		SELECT id FROM upd
+	`
+	var updated string
+	return s.db.QueryRowContext(ctx, query, id, at).Scan(&updated)
+}
+
+// Cancel отменяет подписку, находящуюся в статусе from: дата окончания сдвигается на at
+// (но не раньше даты начала и не позже уже назначенной), открытая пауза закрывается.
+func (s *store) Cancel(ctx context.Context, id string, from model.Status, at time.Time) error {
+	query := `
+		WITH upd AS (
+			UPDATE subscriptions
+			SET status = 'cancelled',
+				end_date = LEAST(COALESCE(end_date, GREATEST($3::date, start_date)), GREATEST($3::date, start_date)),
+				version = version + 1, updated_at = now()
+			WHERE id = $1 AND status = $2
+			RETURNING id
+		), closed AS (
+			UPDATE subscription_pauses p SET resumed_at = GREATEST($3::date, p.paused_at)
+			FROM upd
+			WHERE p.subscription_id = upd.id AND p.resumed_at IS NULL
+		)
+		SELECT id FROM upd
+	`
+	var updated string
+	return s.db.QueryRowContext(ctx, query, id, from, at).Scan(&updated)
+}
+
+// execOne выполняет запрос и возвращает sql.ErrNoRows, если он не затронул ни одной строки
+func (s *store) execOne(ctx context.Context, query string, args ...interface{}) error {
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
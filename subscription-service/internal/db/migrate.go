@@ -0,0 +1,295 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Миграции встроены в бинарник: NNN_name.sql применяет изменение, NNN_name.down.sql
+// откатывает его. Применённые версии записываются в schema_migrations в той же
+// транзакции, что и сама миграция, поэтому версия не может оказаться применённой наполовину.
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+?)(\.down)?\.sql$`)
+
+// migrationLock — ключ advisory-блокировки: реплики, запущенные одновременно,
+// применяют миграции по очереди
+const migrationLock = "subscription-service:migrations"
+
+var (
+	// ErrNoDownMigration — у миграции нет файла отката
+	ErrNoDownMigration = errors.New("migration has no down script")
+	// ErrNotBaselined — схема уже создана (например, через docker-entrypoint-initdb.d),
+	// но истории миграций нет; применённые версии нужно отметить через Force
+	ErrNotBaselined = errors.New("database schema exists but has no migration history")
+)
+
+// Migration — одна версия схемы
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// Reversible сообщает, есть ли у миграции откат
+func (m Migration) Reversible() bool { return m.down != "" }
+
+// MigrationStatus — миграция и время её применения; AppliedAt == nil — ещё не применена
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator применяет и откатывает встроенные миграции
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration // по возрастанию версии
+}
+
+func NewMigrator(db *sqlx.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, path := range files {
+		name := path[len("migrations/"):]
+		m := migrationFile.FindStringSubmatch(name)
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: file name must be NNN_name.sql or NNN_name.down.sql", name)
+		}
+		version, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", name, err)
+		}
+		body, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, err
+		}
+
+		mg := byVersion[version]
+		if mg == nil {
+			mg = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mg
+		}
+		if mg.Name != m[2] {
+			return nil, fmt.Errorf("migration %d: conflicting names %s and %s", version, mg.Name, m[2])
+		}
+		if m[3] != "" {
+			mg.down = string(body)
+		} else {
+			mg.up = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.up == "" {
+			return nil, fmt.Errorf("migration %d_%s: missing up script", mg.Version, mg.Name)
+		}
+		migrations = append(migrations, *mg)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up применяет все ещё не применённые миграции по возрастанию версии и возвращает их
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sqlx.Conn, applied map[int]time.Time) error {
+		if len(applied) == 0 {
+			var exists bool
+			if err := conn.GetContext(ctx, &exists, `SELECT to_regclass('subscriptions') IS NOT NULL`); err != nil {
+				return err
+			}
+			if exists {
+				return ErrNotBaselined
+			}
+		}
+
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if err := migrateTx(ctx, conn, mg.up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mg.Version, mg.Name,
+			); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mg.Version, mg.Name, err)
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down откатывает steps последних применённых миграций и возвращает их в порядке отката
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sqlx.Conn, applied map[int]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mg := m.migrations[i]
+			if _, ok := applied[mg.Version]; !ok {
+				continue
+			}
+			if !mg.Reversible() {
+				return fmt.Errorf("migration %d_%s: %w", mg.Version, mg.Name, ErrNoDownMigration)
+			}
+			if err := migrateTx(ctx, conn, mg.down,
+				`DELETE FROM schema_migrations WHERE version = $1`, mg.Version,
+			); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mg.Version, mg.Name, err)
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Force отмечает миграции до version включительно применёнными, а более поздние —
+// неприменёнными, не выполняя их. Нужен, чтобы завести историю для уже созданной схемы.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	return m.locked(ctx, func(conn *sqlx.Conn, applied map[int]time.Time) error {
+		tx, err := conn.BeginTxx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version > $1`, version); err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			if mg.Version > version {
+				break
+			}
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+				mg.Version, mg.Name,
+			); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+}
+
+// Status возвращает все известные миграции с отметкой о применении
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var res []MigrationStatus
+	err := m.locked(ctx, func(conn *sqlx.Conn, applied map[int]time.Time) error {
+		res = make([]MigrationStatus, len(m.migrations))
+		for i, mg := range m.migrations {
+			res[i].Migration = mg
+			if at, ok := applied[mg.Version]; ok {
+				res[i].AppliedAt = &at
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// Pending возвращает встроенные миграции, ещё не применённые к БД. Блокировку не берёт,
+// чтобы проверка готовности не ждала идущую на другой реплике миграцию.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	var versions []int
+	if err := m.db.SelectContext(ctx, &versions, `SELECT version FROM schema_migrations`); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+
+	var pending []Migration
+	for _, mg := range m.migrations {
+		if !applied[mg.Version] {
+			pending = append(pending, mg)
+		}
+	}
+	return pending, nil
+}
+
+// locked выполняет fn на отдельном соединении под advisory-блокировкой миграций.
+// Блокировка сессионная, поэтому всё, что делает fn, должно идти через conn.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sqlx.Conn, applied map[int]time.Time) error) (err error) {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(hashtext($1))`, migrationLock); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// ctx может быть уже отменён, а блокировку нужно снять до возврата соединения в пул
+		if _, uerr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, migrationLock); uerr != nil && err == nil {
+			err = fmt.Errorf("release migration lock: %w", uerr)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`); err != nil {
+		return err
+	}
+
+	var rows []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := conn.SelectContext(ctx, &rows, `SELECT version, applied_at FROM schema_migrations`); err != nil {
+		return err
+	}
+	applied := make(map[int]time.Time, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r.AppliedAt
+	}
+
+	return fn(conn, applied)
+}
+
+// migrateTx выполняет скрипт миграции и запись в schema_migrations одной транзакцией
+func migrateTx(ctx context.Context, conn *sqlx.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
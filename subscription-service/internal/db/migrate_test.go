@@ -0,0 +1,229 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"subscription-service/internal/dbtest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/010_add_tags.sql":         {Data: []byte("up 10")},
+		"migrations/002_create_services.sql":  {Data: []byte("up 2")},
+		"migrations/001_init.sql":             {Data: []byte("up 1")},
+		"migrations/001_init.down.sql":        {Data: []byte("down 1")},
+		"migrations/010_add_tags.down.sql":    {Data: []byte("down 10")},
+		"migrations/README.md":                {Data: []byte("not a migration")},
+		"migrations/nested/099_ignored.sql":   {Data: []byte("not embedded")},
+		"migrations/002_create_services.sqlx": {Data: []byte("ignored")},
+	}
+	got, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "init", up: "up 1", down: "down 1"},
+		{Version: 2, Name: "create_services", up: "up 2"},
+		{Version: 10, Name: "add_tags", up: "up 10", down: "down 10"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("loadMigrations() = %d migrations, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad file name": {
+			"migrations/init.sql": {Data: []byte("up")},
+		},
+		"down without up": {
+			"migrations/001_init.sql":      {Data: []byte("up 1")},
+			"migrations/002_tags.down.sql": {Data: []byte("down 2")},
+		},
+		"conflicting names": {
+			"migrations/001_init.sql":       {Data: []byte("up 1")},
+			"migrations/001_other.down.sql": {Data: []byte("down 1")},
+		},
+		"two up scripts for one version": {
+			"migrations/001_init.sql":  {Data: []byte("up 1")},
+			"migrations/001_other.sql": {Data: []byte("up 1")},
+		},
+	}
+	for name, fsys := range tests {
+		if got, err := loadMigrations(fsys); err == nil {
+			t.Errorf("%s: loadMigrations() = %+v, want error", name, got)
+		}
+	}
+}
+
+// встроенные миграции должны загружаться, и у каждой должен быть скрипт отката
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatalf("loadMigrations(embedded) error = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	for _, m := range migrations {
+		if !m.Reversible() {
+			t.Errorf("migration %03d_%s has no down script", m.Version, m.Name)
+		}
+	}
+}
+
+func testMigrator(t *testing.T, fsys fstest.MapFS) *Migrator {
+	t.Helper()
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Migrator{db: dbtest.Empty(t), migrations: migrations}
+}
+
+func versions(migrations []Migration) []int {
+	out := []int{}
+	for _, m := range migrations {
+		out = append(out, m.Version)
+	}
+	return out
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	ctx := context.Background()
+	m := testMigrator(t, fstest.MapFS{
+		"migrations/001_create_subscriptions.sql":      {Data: []byte("CREATE TABLE subscriptions (id INT PRIMARY KEY)")},
+		"migrations/001_create_subscriptions.down.sql": {Data: []byte("DROP TABLE subscriptions")},
+		"migrations/002_add_price.sql":                 {Data: []byte("ALTER TABLE subscriptions ADD COLUMN price INT")},
+		"migrations/002_add_price.down.sql":            {Data: []byte("ALTER TABLE subscriptions DROP COLUMN price")},
+		"migrations/003_add_name.sql":                  {Data: []byte("ALTER TABLE subscriptions ADD COLUMN name TEXT")},
+	})
+
+	done, err := m.Up(ctx)
+	if err != nil || !reflect.DeepEqual(versions(done), []int{1, 2, 3}) {
+		t.Fatalf("Up() = %v, %v; want [1 2 3]", versions(done), err)
+	}
+	if done, err := m.Up(ctx); err != nil || len(done) != 0 {
+		t.Errorf("second Up() = %v, %v; want nothing to apply", versions(done), err)
+	}
+	if pending, err := m.Pending(ctx); err != nil || len(pending) != 0 {
+		t.Errorf("Pending() after Up = %v, %v; want none", versions(pending), err)
+	}
+	if _, err := m.db.Exec(`INSERT INTO subscriptions (id, price, name) VALUES (1, 100, 'Netflix')`); err != nil {
+		t.Fatalf("schema after Up: %v", err)
+	}
+
+	// у 003 нет отката: Down останавливается на ней, ничего не откатив
+	if _, err := m.Down(ctx, 1); !errors.Is(err, ErrNoDownMigration) {
+		t.Fatalf("Down() over irreversible migration: err = %v, want ErrNoDownMigration", err)
+	}
+	if err := m.Force(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.db.Exec(`ALTER TABLE subscriptions DROP COLUMN name`); err != nil {
+		t.Fatal(err)
+	}
+
+	done, err = m.Down(ctx, 1)
+	if err != nil || !reflect.DeepEqual(versions(done), []int{2}) {
+		t.Fatalf("Down(1) = %v, %v; want [2]", versions(done), err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied []int
+	for _, s := range status {
+		if s.AppliedAt != nil {
+			applied = append(applied, s.Version)
+		}
+	}
+	if len(status) != 3 || !reflect.DeepEqual(applied, []int{1}) {
+		t.Errorf("Status() applied = %v of %d, want [1] of 3", applied, len(status))
+	}
+
+	pending, err := m.Pending(ctx)
+	if err != nil || !reflect.DeepEqual(versions(pending), []int{2, 3}) {
+		t.Errorf("Pending() = %v, %v; want [2 3]", versions(pending), err)
+	}
+
+	done, err = m.Up(ctx)
+	if err != nil || !reflect.DeepEqual(versions(done), []int{2, 3}) {
+		t.Errorf("Up() after Down = %v, %v; want [2 3]", versions(done), err)
+	}
+}
+
+func TestMigratorFailedMigration(t *testing.T) {
+	ctx := context.Background()
+	m := testMigrator(t, fstest.MapFS{
+		"migrations/001_create_subscriptions.sql": {Data: []byte("CREATE TABLE subscriptions (id INT PRIMARY KEY)")},
+		"migrations/002_broken.sql":               {Data: []byte("CREATE TABLE broken_services (id INT); SELECT no_such_column FROM subscriptions")},
+	})
+
+	done, err := m.Up(ctx)
+	if err == nil || !reflect.DeepEqual(versions(done), []int{1}) {
+		t.Fatalf("Up() = %v, %v; want [1] and error", versions(done), err)
+	}
+	// миграция и запись о ней откатываются вместе
+	var table *string
+	if err := m.db.Get(&table, `SELECT to_regclass('broken_services')::text`); err != nil || table != nil {
+		t.Errorf("broken migration left table broken_services: %v, %v", table, err)
+	}
+	var count int
+	if err := m.db.Get(&count, `SELECT count(*) FROM schema_migrations`); err != nil || count != 1 {
+		t.Errorf("schema_migrations has %d rows, %v; want 1", count, err)
+	}
+}
+
+func TestMigratorNotBaselined(t *testing.T) {
+	ctx := context.Background()
+	m := testMigrator(t, fstest.MapFS{
+		"migrations/001_create_subscriptions.sql": {Data: []byte("CREATE TABLE subscriptions (id INT PRIMARY KEY)")},
+		"migrations/002_add_price.sql":            {Data: []byte("ALTER TABLE subscriptions ADD COLUMN price INT")},
+	})
+	// схема создана в обход миграций, как через docker-entrypoint-initdb.d
+	if _, err := m.db.Exec(`CREATE TABLE subscriptions (id INT PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(ctx); !errors.Is(err, ErrNotBaselined) {
+		t.Fatalf("Up() on existing schema: err = %v, want ErrNotBaselined", err)
+	}
+	if err := m.Force(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	done, err := m.Up(ctx)
+	if err != nil || !reflect.DeepEqual(versions(done), []int{2}) {
+		t.Errorf("Up() after Force(1) = %v, %v; want [2]", versions(done), err)
+	}
+}
+
+// встроенные миграции применяются к пустой базе
+func TestEmbeddedMigrationsUp(t *testing.T) {
+	conn := dbtest.Empty(t)
+	m, err := NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := m.Up(context.Background())
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if len(done) != len(m.migrations) {
+		t.Errorf("Up() applied %d of %d migrations", len(done), len(m.migrations))
+	}
+	if _, err := conn.Exec(`INSERT INTO subscriptions (service_name, price, user_id, start_date)
+		VALUES ('Netflix', 400, '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-01-01')`); err != nil {
+		t.Errorf("insert after migrations: %v", err)
+	}
+}
@@ -0,0 +1,2 @@
+-- internal/db/migrations/000_enable_pgcrypto.down.sql
+DROP EXTENSION IF EXISTS pgcrypto;
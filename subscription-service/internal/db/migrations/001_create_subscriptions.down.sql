@@ -0,0 +1,2 @@
+-- internal/db/migrations/001_create_subscriptions.down.sql
+DROP TABLE IF EXISTS subscriptions;
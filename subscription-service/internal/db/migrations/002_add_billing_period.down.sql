@@ -0,0 +1,3 @@
+-- internal/db/migrations/002_add_billing_period.down.sql
+ALTER TABLE subscriptions
+DROP COLUMN IF EXISTS billing_period;
@@ -0,0 +1,4 @@
+-- internal/db/migrations/002_add_billing_period.sql
+ALTER TABLE subscriptions
+ADD COLUMN IF NOT EXISTS billing_period TEXT NOT NULL DEFAULT 'monthly'
+CHECK (billing_period IN ('weekly', 'monthly', 'quarterly', 'yearly'));
@@ -0,0 +1,6 @@
+-- internal/db/migrations/003_add_currency.down.sql
+DROP TABLE IF EXISTS exchange_rates;
+
+
+ALTER TABLE subscriptions
+DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,14 @@
+-- internal/db/migrations/003_add_currency.sql
+ALTER TABLE subscriptions
+ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'RUB'
+CHECK (currency ~ '^[A-Z]{3}$');
+
+
+-- 1 base_currency = rate quote_currency в месяце month
+CREATE TABLE IF NOT EXISTS exchange_rates (
+base_currency TEXT NOT NULL,
+quote_currency TEXT NOT NULL,
+month DATE NOT NULL,
+rate NUMERIC(20, 8) NOT NULL CHECK (rate > 0),
+PRIMARY KEY (base_currency, quote_currency, month)
+);
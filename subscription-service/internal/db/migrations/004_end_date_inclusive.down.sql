@@ -0,0 +1,6 @@
+-- internal/db/migrations/004_end_date_inclusive.down.sql
+-- возврат к точности до месяца: end_date — первое число последнего месяца подписки.
+-- День окончания внутри месяца при этом теряется.
+UPDATE subscriptions
+SET end_date = date_trunc('month', end_date)::date
+WHERE end_date IS NOT NULL;
@@ -0,0 +1,7 @@
+-- internal/db/migrations/004_end_date_inclusive.sql
+-- end_date раньше хранился с точностью до месяца (первое число) и означал
+-- «по этот месяц включительно». Теперь даты хранятся с точностью до дня,
+-- поэтому переносим старые значения на последний день месяца.
+UPDATE subscriptions
+SET end_date = (date_trunc('month', end_date) + interval '1 month' - interval '1 day')::date
+WHERE end_date = date_trunc('month', end_date)::date;
@@ -0,0 +1,6 @@
+-- internal/db/migrations/005_add_status.down.sql
+DROP TABLE IF EXISTS subscription_pauses;
+
+
+ALTER TABLE subscriptions
+DROP COLUMN IF EXISTS status;
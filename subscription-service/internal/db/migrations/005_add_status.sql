@@ -0,0 +1,19 @@
+-- internal/db/migrations/005_add_status.sql
+ALTER TABLE subscriptions
+ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+CHECK (status IN ('trial', 'active', 'paused', 'cancelled', 'expired'));
+
+
+-- интервалы приостановки: с paused_at включительно до resumed_at
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+paused_at DATE NOT NULL,
+resumed_at DATE,
+CHECK (resumed_at IS NULL OR resumed_at >= paused_at)
+);
+
+
+CREATE INDEX IF NOT EXISTS idx_subscription_pauses_subscription ON subscription_pauses(subscription_id);
+-- у подписки может быть не больше одной незакрытой паузы
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscription_pauses_open ON subscription_pauses(subscription_id) WHERE resumed_at IS NULL;
@@ -0,0 +1,3 @@
+-- internal/db/migrations/006_add_trial.down.sql
+ALTER TABLE subscriptions
+DROP COLUMN IF EXISTS trial_end;
@@ -0,0 +1,8 @@
+-- internal/db/migrations/006_add_trial.sql
+-- trial_end — последний день бесплатного пробного периода
+ALTER TABLE subscriptions
+ADD COLUMN IF NOT EXISTS trial_end DATE
+CHECK (trial_end IS NULL OR trial_end >= start_date);
+
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_trial_end ON subscriptions(trial_end) WHERE trial_end IS NOT NULL;
@@ -0,0 +1,13 @@
+-- internal/db/migrations/007_add_price_history.down.sql
+-- subscriptions.price получает цену, действовавшую последней
+UPDATE subscriptions s
+SET price = p.price
+FROM (
+SELECT DISTINCT ON (subscription_id) subscription_id, price
+FROM subscription_prices
+ORDER BY subscription_id, effective_month DESC
+) p
+WHERE s.id = p.subscription_id;
+
+
+DROP TABLE IF EXISTS subscription_prices;
@@ -0,0 +1,15 @@
+-- internal/db/migrations/007_add_price_history.sql
+-- цена подписки действует с effective_month до следующей записи
+CREATE TABLE IF NOT EXISTS subscription_prices (
+subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+effective_month DATE NOT NULL CHECK (effective_month = date_trunc('month', effective_month)),
+price INTEGER NOT NULL CHECK (price >= 0),
+created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+PRIMARY KEY (subscription_id, effective_month)
+);
+
+
+-- начальная цена уже существующих подписок
+INSERT INTO subscription_prices (subscription_id, effective_month, price)
+SELECT id, date_trunc('month', start_date)::date, price FROM subscriptions
+ON CONFLICT DO NOTHING;
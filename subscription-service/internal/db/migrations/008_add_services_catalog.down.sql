@@ -0,0 +1,7 @@
+-- internal/db/migrations/008_add_services_catalog.down.sql
+ALTER TABLE subscriptions
+DROP COLUMN IF EXISTS service_id;
+
+
+DROP TABLE IF EXISTS service_aliases;
+DROP TABLE IF EXISTS services;
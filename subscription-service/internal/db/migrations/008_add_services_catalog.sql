@@ -0,0 +1,40 @@
+-- internal/db/migrations/008_add_services_catalog.sql
+-- каталог услуг: каноническое название, категория и цена по умолчанию
+CREATE TABLE IF NOT EXISTS services (
+id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+name TEXT NOT NULL CHECK (btrim(name) <> ''),
+category TEXT,
+default_price INTEGER CHECK (default_price >= 0),
+currency TEXT NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$'),
+created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_services_name ON services (lower(name));
+
+
+-- псевдонимы хранятся нормализованными: в нижнем регистре, без лишних пробелов
+CREATE TABLE IF NOT EXISTS service_aliases (
+alias TEXT PRIMARY KEY,
+service_id UUID NOT NULL REFERENCES services(id) ON DELETE CASCADE
+);
+
+
+ALTER TABLE subscriptions
+ADD COLUMN IF NOT EXISTS service_id UUID REFERENCES services(id) ON DELETE SET NULL;
+
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_id ON subscriptions(service_id);
+
+
+-- каталог из уже введённых названий: варианты, отличающиеся регистром и пробелами, — одна услуга
+INSERT INTO services (name)
+SELECT min(btrim(service_name)) FROM subscriptions
+GROUP BY lower(btrim(service_name))
+ON CONFLICT DO NOTHING;
+
+
+UPDATE subscriptions s
+SET service_id = c.id, service_name = c.name
+FROM services c
+WHERE s.service_id IS NULL AND lower(btrim(s.service_name)) = lower(c.name);
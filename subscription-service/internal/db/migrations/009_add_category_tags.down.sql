@@ -0,0 +1,4 @@
+-- internal/db/migrations/009_add_category_tags.down.sql
+ALTER TABLE subscriptions
+DROP COLUMN IF EXISTS category,
+DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,15 @@
+-- internal/db/migrations/009_add_category_tags.sql
+ALTER TABLE subscriptions
+ADD COLUMN IF NOT EXISTS category TEXT,
+ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_category ON subscriptions(category);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_tags ON subscriptions USING GIN (tags);
+
+
+-- категория подписок, связанных с каталогом, по умолчанию берётся из каталога
+UPDATE subscriptions s
+SET category = c.category
+FROM services c
+WHERE s.service_id = c.id AND s.category IS NULL;
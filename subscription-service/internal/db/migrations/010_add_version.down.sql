@@ -0,0 +1,4 @@
+-- internal/db/migrations/010_add_version.down.sql
+ALTER TABLE subscriptions
+DROP COLUMN IF EXISTS version,
+DROP COLUMN IF EXISTS updated_at;
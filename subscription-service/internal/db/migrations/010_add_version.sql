@@ -0,0 +1,5 @@
+-- internal/db/migrations/010_add_version.sql
+-- version растёт при каждом изменении подписки и служит ETag для оптимистичных блокировок
+ALTER TABLE subscriptions
+ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1,
+ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,2 @@
+-- internal/db/migrations/011_add_idempotency_keys.down.sql
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,16 @@
+-- internal/db/migrations/011_add_idempotency_keys.sql
+-- сохранённые ответы на запросы с Idempotency-Key; status_code IS NULL — запрос ещё выполняется
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+scope TEXT NOT NULL,
+key TEXT NOT NULL,
+request_hash TEXT NOT NULL,
+status_code INTEGER,
+response_headers JSONB,
+response_body BYTEA,
+created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+expires_at TIMESTAMPTZ NOT NULL,
+PRIMARY KEY (scope, key)
+);
+
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
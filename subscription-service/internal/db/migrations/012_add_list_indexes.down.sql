@@ -0,0 +1,3 @@
+-- internal/db/migrations/012_add_list_indexes.down.sql
+DROP INDEX IF EXISTS idx_subscriptions_start_date_id;
+DROP INDEX IF EXISTS idx_subscriptions_end_date;
@@ -0,0 +1,5 @@
+-- internal/db/migrations/012_add_list_indexes.sql
+-- порядок списка по умолчанию и курсор: (start_date, id) по убыванию
+CREATE INDEX IF NOT EXISTS idx_subscriptions_start_date_id ON subscriptions(start_date DESC, id DESC);
+-- фильтры по диапазону дат окончания и бессрочным подпискам
+CREATE INDEX IF NOT EXISTS idx_subscriptions_end_date ON subscriptions(end_date);
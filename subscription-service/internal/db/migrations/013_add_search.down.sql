@@ -0,0 +1,5 @@
+-- internal/db/migrations/013_add_search.down.sql
+DROP INDEX IF EXISTS idx_subscriptions_service_name_trgm;
+DROP INDEX IF EXISTS idx_service_aliases_alias_trgm;
+DROP FUNCTION IF EXISTS search_key(TEXT);
+DROP EXTENSION IF EXISTS pg_trgm;
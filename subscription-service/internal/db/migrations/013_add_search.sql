@@ -0,0 +1,24 @@
+-- internal/db/migrations/013_add_search.sql
+-- нечёткий поиск по названиям услуг: триграммы pg_trgm поверх ключа поиска
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+
+-- search_key приводит название к ключу поиска: нижний регистр, одиночные пробелы и
+-- кириллица в латинице, чтобы «нетфликс» и «Netflix» сравнивались как близкие строки.
+-- «кс» передаётся как x: так записываются заимствованные названия (Netflix, Xbox).
+CREATE OR REPLACE FUNCTION search_key(name TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE AS $$
+SELECT translate(
+replace(replace(replace(replace(replace(replace(replace(replace(replace(replace(
+regexp_replace(lower(btrim(name)), '\s+', ' ', 'g'),
+'кс', 'x'), 'щ', 'shch'), 'ж', 'zh'), 'ч', 'ch'), 'ш', 'sh'), 'ю', 'yu'), 'я', 'ya'), 'х', 'kh'), 'ц', 'ts'), 'ё', 'e'),
+'абвгдезийклмнопрстуфыэъь',
+'abvgdeziiklmnoprstufye'
+)
+$$;
+
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name_trgm
+ON subscriptions USING GIN (search_key(service_name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_service_aliases_alias_trgm
+ON service_aliases USING GIN (search_key(alias) gin_trgm_ops);
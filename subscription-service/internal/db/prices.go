@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+// currentPriceSQL — цена подписки из таблицы subscriptions, действующая в текущем месяце;
+// если истории нет, берётся цена из самой подписки
+const currentPriceSQL = `COALESCE((
+		SELECT sp.price FROM subscription_prices sp
+		WHERE sp.subscription_id = subscriptions.id AND sp.effective_month <= CURRENT_DATE
+		ORDER BY sp.effective_month DESC
+		LIMIT 1
+	), subscriptions.price)`
+
+// SetPrice назначает цену подписки с месяца month; цена на тот же месяц перезаписывается.
+// Версия подписки растёт: от истории цен зависит её текущая цена.
+func (s *store) SetPrice(ctx context.Context, id string, month time.Time, price int) error {
+	query := `
+		WITH upd AS (
+			UPDATE subscriptions SET version = version + 1, updated_at = now()
+			WHERE id = $1
+		)
+		INSERT INTO subscription_prices (subscription_id, effective_month, price)
+		VALUES ($1, date_trunc('month', $2::date)::date, $3)
+		ON CONFLICT (subscription_id, effective_month) DO UPDATE SET price = EXCLUDED.price, created_at = now()
+	`
+	_, err := s.db.ExecContext(ctx, query, id, month, price)
+	return err
+}
+
+// PriceHistory возвращает историю цен подписки по возрастанию месяца
+func (s *store) PriceHistory(ctx context.Context, id string) ([]model.PriceChange, error) {
+	query := `
+		SELECT subscription_id, effective_month, price, created_at
+		FROM subscription_prices
+		WHERE subscription_id = $1
+		ORDER BY effective_month
+	`
+	history := []model.PriceChange{}
+	if err := s.db.SelectContext(ctx, &history, query, id); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
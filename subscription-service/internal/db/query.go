@@ -0,0 +1,141 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"subscription-service/internal/model"
+
+	"github.com/lib/pq"
+)
+
+// queryBuilder собирает условия WHERE и ORDER BY. Значения никогда не попадают в текст
+// запроса: каждое становится параметром $n, а сортировать можно только по колонкам
+// из белого списка sortColumns.
+type queryBuilder struct {
+	conds []string
+	args  []interface{}
+	order []string
+}
+
+// arg добавляет параметр и возвращает его плейсхолдер
+func (b *queryBuilder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// where добавляет условие; каждый ? в cond заменяется плейсхолдером очередного значения из args
+func (b *queryBuilder) where(cond string, args ...interface{}) {
+	var sb strings.Builder
+	for _, v := range args {
+		i := strings.IndexByte(cond, '?')
+		if i < 0 {
+			panic("queryBuilder: more args than placeholders in " + cond)
+		}
+		sb.WriteString(cond[:i])
+		sb.WriteString(b.arg(v))
+		cond = cond[i+1:]
+	}
+	sb.WriteString(cond)
+	b.conds = append(b.conds, sb.String())
+}
+
+// orderBy добавляет сортировку по полю из белого списка
+func (b *queryBuilder) orderBy(field string, desc bool) error {
+	column, ok := sortColumns[field]
+	if !ok {
+		return fmt.Errorf("unsupported sort field %q", field)
+	}
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+	b.order = append(b.order, column+" "+dir)
+	return nil
+}
+
+// whereSQL — условия через AND с ключевым словом WHERE или пустая строка
+func (b *queryBuilder) whereSQL() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conds, " AND ")
+}
+
+func (b *queryBuilder) orderSQL() string {
+	if len(b.order) == 0 {
+		return ""
+	}
+	return " ORDER BY " + strings.Join(b.order, ", ")
+}
+
+// sortColumns — поля сортировки списка (model.SortFields) и их выражения в SQL
+var sortColumns = map[string]string{
+	"start_date":   "start_date",
+	"end_date":     "end_date",
+	"price":        currentPriceSQL,
+	"service_name": "service_name",
+	"user_id":      "user_id",
+	"status":       "status",
+	"category":     "category",
+	"updated_at":   "updated_at",
+	"id":           "id",
+}
+
+// likeEscaper экранирует спецсимволы LIKE; \ — escape-символ Postgres по умолчанию
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// containsPattern — шаблон ILIKE для поиска подстроки s как есть, без подстановочных знаков из s
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+// listFilter добавляет в запрос условия фильтра списка подписок
+func (b *queryBuilder) listFilter(f model.ListFilter) {
+	if len(f.UserIDs) > 0 {
+		b.where("user_id = ANY(?::uuid[])", pq.StringArray(f.UserIDs))
+	}
+	if f.ServiceID != "" {
+		b.where("service_id = ?", f.ServiceID)
+	}
+	if f.ServiceName != "" {
+		b.where("service_name ILIKE ?", containsPattern(f.ServiceName))
+	}
+	if f.Category != "" {
+		b.where("category = ?", f.Category)
+	}
+	if len(f.Tags) > 0 {
+		b.where("tags @> ?::text[]", pq.StringArray(f.Tags))
+	}
+	if f.TrialEndsWithin != nil {
+		b.where("trial_end BETWEEN CURRENT_DATE AND CURRENT_DATE + ?::int", *f.TrialEndsWithin)
+	}
+	if f.PriceMin != nil {
+		b.where(currentPriceSQL+" >= ?", *f.PriceMin)
+	}
+	if f.PriceMax != nil {
+		b.where(currentPriceSQL+" <= ?", *f.PriceMax)
+	}
+	if f.StartFrom != nil {
+		b.where("start_date >= ?::date", *f.StartFrom)
+	}
+	if f.StartTo != nil {
+		b.where("start_date <= ?::date", *f.StartTo)
+	}
+	if f.EndFrom != nil {
+		b.where("end_date >= ?::date", *f.EndFrom)
+	}
+	if f.EndTo != nil {
+		b.where("end_date <= ?::date", *f.EndTo)
+	}
+	if f.ActiveAt != nil {
+		b.where("start_date <= ?::date AND (end_date IS NULL OR end_date >= ?::date)", *f.ActiveAt, *f.ActiveAt)
+	}
+	if f.NoEndDate != nil {
+		if *f.NoEndDate {
+			b.where("end_date IS NULL")
+		} else {
+			b.where("end_date IS NOT NULL")
+		}
+	}
+}
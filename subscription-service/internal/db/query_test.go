@@ -0,0 +1,87 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"subscription-service/internal/model"
+)
+
+func TestQueryBuilderWhere(t *testing.T) {
+	var b queryBuilder
+	b.where("end_date IS NULL")
+	b.where("user_id = ?", "u1")
+	b.where("start_date <= ?::date AND (end_date IS NULL OR end_date >= ?::date)", "2025-07-01", "2025-07-01")
+	b.where("price BETWEEN ? AND ?", 10, 20)
+
+	want := " WHERE end_date IS NULL AND user_id = $1" +
+		" AND start_date <= $2::date AND (end_date IS NULL OR end_date >= $3::date)" +
+		" AND price BETWEEN $4 AND $5"
+	if got := b.whereSQL(); got != want {
+		t.Errorf("whereSQL() =\n%s\nwant\n%s", got, want)
+	}
+	wantArgs := []interface{}{"u1", "2025-07-01", "2025-07-01", 10, 20}
+	if !reflect.DeepEqual(b.args, wantArgs) {
+		t.Errorf("args = %v, want %v", b.args, wantArgs)
+	}
+
+	// плейсхолдеры продолжают нумерацию параметров, добавленных через arg
+	if p := b.arg(50); p != "$6" {
+		t.Errorf("arg() = %s, want $6", p)
+	}
+}
+
+func TestQueryBuilderWhereTooManyArgs(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("where() with more args than placeholders did not panic")
+		}
+	}()
+	var b queryBuilder
+	b.where("user_id = ?", "u1", "u2")
+}
+
+func TestContainsPattern(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Netflix", `%Netflix%`},
+		{"100%", `%100\%%`},
+		{"my_tv", `%my\_tv%`},
+		{`a\b`, `%a\\b%`},
+		{"", `%%`},
+	}
+	for _, tt := range tests {
+		if got := containsPattern(tt.in); got != tt.want {
+			t.Errorf("containsPattern(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQueryBuilderOrderBy(t *testing.T) {
+	var b queryBuilder
+	if b.whereSQL() != "" || b.orderSQL() != "" {
+		t.Fatalf("empty builder produced SQL: %q %q", b.whereSQL(), b.orderSQL())
+	}
+	if err := b.orderBy("start_date", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.orderBy("id", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.orderBy("id; DROP TABLE subscriptions", false); err == nil {
+		t.Error("orderBy() accepted a field outside the whitelist")
+	}
+	if got, want := b.orderSQL(), " ORDER BY start_date DESC, id ASC"; got != want {
+		t.Errorf("orderSQL() = %q, want %q", got, want)
+	}
+}
+
+// каждое поле, которое пропускает model.ParseSort, должно иметь колонку в sortColumns
+func TestSortColumnsCoverSortFields(t *testing.T) {
+	for _, f := range model.SortFields {
+		if _, ok := sortColumns[f]; !ok {
+			t.Errorf("sort field %q has no column in sortColumns", f)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"subscription-service/internal/model"
+)
+
+// Search ищет подписки, название услуги которых похоже на query, с учётом опечаток и
+// записи кириллицей (см. search_key в миграции 013). Подписки, связанные с каталогом,
+// находятся и по псевдонимам услуги. Близость — наибольшая из similarity и
+// word_similarity pg_trgm по всем совпавшим названиям; выдача упорядочена по ней,
+// при равенстве — как список по умолчанию. Из фильтра учитываются условия и Limit.
+func (s *store) Search(ctx context.Context, query string, f model.ListFilter) ([]*model.SearchHit, error) {
+	var b queryBuilder
+	term := b.arg(query)
+	b.listFilter(f)
+
+	// % и <% используют триграммные индексы по search_key
+	qb := `
+		WITH q AS (
+			SELECT search_key(` + term + `::text) AS term
+		), hits AS (
+			SELECT s.id, s.service_name AS matched, search_key(s.service_name) AS key
+			FROM subscriptions s, q
+			WHERE search_key(s.service_name) % q.term OR q.term <% search_key(s.service_name)
+			UNION ALL
+			SELECT s.id, a.alias, search_key(a.alias)
+			FROM service_aliases a
+			JOIN subscriptions s ON s.service_id = a.service_id, q
+			WHERE search_key(a.alias) % q.term OR q.term <% search_key(a.alias)
+		), ranked AS (
+			SELECT DISTINCT ON (hits.id) hits.id, hits.matched,
+				GREATEST(similarity(hits.key, q.term), word_similarity(q.term, hits.key))::float8 AS score
+			FROM hits, q
+			ORDER BY hits.id, score DESC
+		)
+		SELECT ` + subscriptionColumns + `, ranked.matched, ranked.score
+		FROM subscriptions
+		JOIN ranked USING (id)` + b.whereSQL() + `
+		ORDER BY ranked.score DESC, start_date DESC, id DESC`
+	if f.Limit > 0 {
+		qb += fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+
+	rows := []*model.SearchHit{}
+	if err := s.db.SelectContext(ctx, &rows, qb, b.args...); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+// effectiveStatusSQL — статус подписки на дату $1, как model.Subscription.EffectiveStatus
+const effectiveStatusSQL = `CASE
+		WHEN status <> 'cancelled' AND end_date < $1 THEN 'expired'
+		WHEN status = 'trial' AND trial_end < $1 THEN 'active'
+		ELSE status
+	END`
+
+// Stats считает подписки по статусам на дату today и их стоимость в месяц по валютам.
+// В стоимость входят подписки, которые на today действуют и не в пробном периоде.
+func (s *store) Stats(ctx context.Context, today time.Time) (*model.Stats, error) {
+	var stats model.Stats
+
+	byStatus := `SELECT ` + effectiveStatusSQL + ` AS status, count(*) AS count
+		FROM subscriptions
+		GROUP BY 1
+		ORDER BY 1`
+	if err := s.db.SelectContext(ctx, &stats.ByStatus, byStatus, today); err != nil {
+		return nil, err
+	}
+
+	spend := `SELECT currency, COALESCE(SUM(round(` + currentPriceSQL + ` * CASE billing_period
+			WHEN 'weekly' THEN 52 WHEN 'quarterly' THEN 4 WHEN 'yearly' THEN 1 ELSE 12
+		END / 12.0)), 0)::bigint AS monthly
+		FROM subscriptions
+		WHERE start_date <= $1 AND ` + effectiveStatusSQL + ` = 'active'
+		GROUP BY currency
+		ORDER BY currency`
+	if err := s.db.SelectContext(ctx, &stats.MonthlySpend, spend, today); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
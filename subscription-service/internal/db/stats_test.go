@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"subscription-service/internal/dbtest"
+	"subscription-service/internal/model"
+)
+
+func TestStats(t *testing.T) {
+	conn := dbtest.Open(t)
+	_, err := conn.Exec(`INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date, status, trial_end, billing_period, currency) VALUES
+		('Netflix',  400,  '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-01-01', NULL,         'active',    NULL,         'monthly', 'RUB'),
+		('Yandex',   1200, '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-01-01', NULL,         'active',    NULL,         'yearly',  'RUB'),
+		('GitHub',   10,   '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-01-01', NULL,         'active',    NULL,         'weekly',  'USD'),
+		('Ivi',      200,  '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-01-01', '2025-05-31', 'active',    NULL,         'monthly', 'RUB'),
+		('Okko',     250,  '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-06-01', NULL,         'trial',     '2025-06-30', 'monthly', 'RUB'),
+		('Spotify',  300,  '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-05-01', NULL,         'trial',     '2025-06-01', 'monthly', 'RUB'),
+		('Kion',     150,  '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-01-01', NULL,         'paused',    NULL,         'monthly', 'RUB'),
+		('Wink',     100,  '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-01-01', '2025-03-31', 'cancelled', NULL,         'monthly', 'RUB'),
+		('Dropbox',  500,  '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-07-01', NULL,         'active',    NULL,         'monthly', 'RUB')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := NewStore(conn).Stats(context.Background(), time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// статусы — с учётом дат: истёкшая подписка expired, закончившийся пробный период active
+	wantStatus := []model.StatusCount{
+		{Status: model.StatusActive, Count: 5},
+		{Status: model.StatusCancelled, Count: 1},
+		{Status: model.StatusExpired, Count: 1},
+		{Status: model.StatusPaused, Count: 1},
+		{Status: model.StatusTrial, Count: 1},
+	}
+	if !reflect.DeepEqual(stats.ByStatus, wantStatus) {
+		t.Errorf("ByStatus = %+v, want %+v", stats.ByStatus, wantStatus)
+	}
+	// RUB: 400 + 1200/12 + 300 (пробный период закончился); не начавшаяся подписка не входит.
+	// USD: 10 * 52 / 12 = 43.33
+	wantSpend := []model.CurrencySpend{{Currency: "RUB", Monthly: 800}, {Currency: "USD", Monthly: 43}}
+	if !reflect.DeepEqual(stats.MonthlySpend, wantSpend) {
+		t.Errorf("MonthlySpend = %+v, want %+v", stats.MonthlySpend, wantSpend)
+	}
+}
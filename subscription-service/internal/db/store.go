@@ -203,8 +203,9 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"strings"
+	"time"
 
 	"subscription-service/internal/model"
 
@@ -214,35 +215,133 @@ import (
 type Repository interface {
 	Create(ctx context.Context, sub *model.Subscription) error
 	GetByID(ctx context.Context, id string) (*model.Subscription, error)
-	List(ctx context.Context, userID, serviceName string, limit, offset int) ([]*model.Subscription, error)
+	List(ctx context.Context, f model.ListFilter) ([]*model.Subscription, error)
+	StreamSubscriptions(ctx context.Context, f model.ListFilter, fn func(*model.Subscription) error) error
+	Count(ctx context.Context, f model.ListFilter) (int64, error)
 	Update(ctx context.Context, sub *model.Subscription) error
-	Delete(ctx context.Context, id string) error
-	AggregateTotal(ctx context.Context, from, to string, userID, serviceName *string) (int64, error)
-	FindSubscriptionsOverlapping(ctx context.Context, from, to string, userID, serviceName *string) ([]*model.Subscription, error)
+	Delete(ctx context.Context, id string, version int) error
+	AggregateTotal(ctx context.Context, f model.AggregateFilter) (int64, error)
+	FindSubscriptionsOverlapping(ctx context.Context, f model.AggregateFilter) ([]*model.SubscriptionUsage, error)
+	StreamSubscriptionsOverlapping(ctx context.Context, f model.AggregateFilter, fn func(*model.SubscriptionUsage) error) error
+	AggregateMonthly(ctx context.Context, f model.AggregateFilter) ([]model.MonthServiceTotal, error)
+	UpsertExchangeRates(ctx context.Context, rates []model.ExchangeRate) error
+	Pause(ctx context.Context, id string, from model.Status, at time.Time) error
+	Resume(ctx context.Context, id string, at time.Time) error
+	Cancel(ctx context.Context, id string, from model.Status, at time.Time) error
+	SetPrice(ctx context.Context, id string, month time.Time, price int) error
+	PriceHistory(ctx context.Context, id string) ([]model.PriceChange, error)
+	CreateService(ctx context.Context, svc *model.CatalogService) error
+	GetService(ctx context.Context, id string) (*model.CatalogService, error)
+	ListServices(ctx context.Context) ([]*model.CatalogService, error)
+	UpdateService(ctx context.Context, svc *model.CatalogService) error
+	DeleteService(ctx context.Context, id string) error
+	ResolveService(ctx context.Context, name string) (*model.CatalogService, error)
+	GetServices(ctx context.Context, ids []string) (map[string]*model.CatalogService, error)
+	ResolveServices(ctx context.Context, names []string) (map[string]*model.CatalogService, error)
+	ClaimIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord, ttl, staleAfter time.Duration) (*model.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, rec *model.IdempotencyRecord) error
+	WithTx(ctx context.Context, fn func(repo Repository) error) error
+	ImportSubscriptions(ctx context.Context, subs []*model.Subscription) (int64, error)
+	Search(ctx context.Context, query string, f model.ListFilter) ([]*model.SearchHit, error)
+	Stats(ctx context.Context, today time.Time) (*model.Stats, error)
+}
+
+// subscriptionColumns — колонки подписки в порядке полей model.Subscription.
+// price — цена, действующая в текущем месяце по истории цен.
+const subscriptionColumns = `id, service_id, service_name, ` + currentPriceSQL + ` AS price,
+	user_id, start_date, end_date, billing_period, currency, status, trial_end, category, tags,
+	version, updated_at`
+
+// ErrVersionConflict — подписку успели изменить: её версия не совпадает с ожидаемой
+var ErrVersionConflict = errors.New("subscription version conflict")
+
+// dbtx — общее у *sqlx.DB и *sqlx.Tx: репозиторий работает либо с пулом соединений,
+// либо внутри транзакции WithTx
+type dbtx interface {
+	sqlx.QueryerContext
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 }
 
 type store struct {
-	db *sqlx.DB
+	db dbtx
 }
 
 func NewStore(db *sqlx.DB) Repository {
 	return &store{db: db}
 }
 
+// WithTx выполняет fn в одной транзакции: все вызовы repo внутри fn идут через неё.
+// Ошибка fn откатывает транзакцию, иначе она фиксируется. Внутри уже открытой
+// транзакции WithTx вложенной не создаёт.
+func (s *store) WithTx(ctx context.Context, fn func(repo Repository) error) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	if err := fn(&store{db: tx.Tx}); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+// txn — транзакция метода репозитория. Внутри WithTx метод работает в уже открытой
+// транзакции: её фиксирует или откатывает WithTx, поэтому commit и rollback ничего не делают.
+type txn struct {
+	*sqlx.Tx
+	nested bool
+}
+
+func (s *store) begin(ctx context.Context) (*txn, error) {
+	if tx, ok := s.db.(*sqlx.Tx); ok {
+		return &txn{Tx: tx, nested: true}, nil
+	}
+	tx, err := s.db.(*sqlx.DB).BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &txn{Tx: tx}, nil
+}
+
+func (t *txn) rollback() {
+	if !t.nested {
+		_ = t.Tx.Rollback()
+	}
+}
+
+func (t *txn) commit() error {
+	if t.nested {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+// Create сохраняет подписку и открывает её историю цен начальной ценой с месяца начала
 func (s *store) Create(ctx context.Context, sub *model.Subscription) error {
 	query := `
-		INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
+		WITH ins AS (
+			INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date, billing_period, currency, status, trial_end, service_id, category, tags)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE($12::text[], '{}'))
+			RETURNING id, price, start_date, version, updated_at
+		), history AS (
+			INSERT INTO subscription_prices (subscription_id, effective_month, price)
+			SELECT id, date_trunc('month', start_date)::date, price FROM ins
+		)
+		SELECT id, version, updated_at FROM ins
 	`
 	return s.db.QueryRowContext(ctx, query,
-		sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate,
-	).Scan(&sub.ID)
+		sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.BillingPeriod, sub.Currency, sub.Status, sub.TrialEnd, sub.ServiceID,
+		sub.Category, sub.Tags,
+	).Scan(&sub.ID, &sub.Version, &sub.UpdatedAt)
 }
 
 func (s *store) GetByID(ctx context.Context, id string) (*model.Subscription, error) {
-	query := `
-		SELECT id, service_name, price, user_id, start_date, end_date
+	query := `SELECT ` + subscriptionColumns + `
 		FROM subscriptions
 		WHERE id = $1
 	`
@@ -253,28 +352,12 @@ func (s *store) GetByID(ctx context.Context, id string) (*model.Subscription, er
 	return &sub, nil
 }
 
-func (s *store) List(ctx context.Context, userID, serviceName string, limit, offset int) ([]*model.Subscription, error) {
-	qb := `
-		SELECT id, service_name, price, user_id, start_date, end_date
-		FROM subscriptions
-	`
-	conds := []string{}
-	args := []interface{}{}
-	argIdx := 1
-	if userID != "" {
-		conds = append(conds, fmt.Sprintf("user_id = $%d", argIdx))
-		args = append(args, userID)
-		argIdx++
-	}
-	if serviceName != "" {
-		conds = append(conds, fmt.Sprintf("service_name ILIKE $%d", argIdx))
-		args = append(args, "%"+serviceName+"%")
-		argIdx++
-	}
-	if len(conds) > 0 {
-		qb += " WHERE " + strings.Join(conds, " AND ")
+func (s *store) List(ctx context.Context, f model.ListFilter) ([]*model.Subscription, error) {
+	qb, args, err := listQuery(f)
+	if err != nil {
+		return nil, err
 	}
-	qb += fmt.Sprintf(" ORDER BY start_date DESC LIMIT %d OFFSET %d", limit, offset)
+	qb += fmt.Sprintf(" LIMIT %d OFFSET %d", f.Limit, f.Offset)
 
 	rows := []*model.Subscription{}
 	if err := s.db.SelectContext(ctx, &rows, qb, args...); err != nil {
@@ -283,30 +366,143 @@ func (s *store) List(ctx context.Context, userID, serviceName string, limit, off
 	return rows, nil
 }
 
+// StreamSubscriptions читает подписки по фильтру курсором и передаёт их fn по одной,
+// не собирая выборку в памяти. Limit и Offset применяются, только если Limit > 0.
+// Ошибка fn прерывает чтение и возвращается как есть.
+func (s *store) StreamSubscriptions(ctx context.Context, f model.ListFilter, fn func(*model.Subscription) error) error {
+	qb, args, err := listQuery(f)
+	if err != nil {
+		return err
+	}
+	if f.Limit > 0 {
+		qb += fmt.Sprintf(" LIMIT %d OFFSET %d", f.Limit, f.Offset)
+	}
+
+	rows, err := s.db.QueryxContext(ctx, qb, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sub model.Subscription
+		if err := rows.StructScan(&sub); err != nil {
+			return err
+		}
+		if err := fn(&sub); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Count возвращает число подписок по фильтру; курсор, сортировка, Limit и Offset не учитываются
+func (s *store) Count(ctx context.Context, f model.ListFilter) (int64, error) {
+	var b queryBuilder
+	b.listFilter(f)
+
+	var total int64
+	if err := s.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM subscriptions`+b.whereSQL(), b.args...); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// listQuery строит выборку подписок по фильтру, без LIMIT и OFFSET. Порядок по умолчанию —
+// (start_date, id) по убыванию: id делает его однозначным, и курсор продолжает
+// выдачу строго после последней увиденной пары, не пропуская и не повторяя строк.
+// Заданная сортировка тоже дополняется id, чтобы страницы по offset не перекрывались.
+func listQuery(f model.ListFilter) (string, []interface{}, error) {
+	var b queryBuilder
+	b.listFilter(f)
+	if f.Cursor != nil {
+		b.where("(start_date, id) < (?::date, ?::uuid)", f.Cursor.StartDate, f.Cursor.ID)
+	}
+
+	if len(f.Sort) == 0 {
+		f.Sort = []model.SortField{{Field: "start_date", Desc: true}, {Field: "id", Desc: true}}
+	}
+	byID := false
+	for _, sf := range f.Sort {
+		if err := b.orderBy(sf.Field, sf.Desc); err != nil {
+			return "", nil, err
+		}
+		byID = byID || sf.Field == "id"
+	}
+	if !byID {
+		_ = b.orderBy("id", false)
+	}
+
+	qb := `SELECT ` + subscriptionColumns + `
+		FROM subscriptions` + b.whereSQL() + b.orderSQL()
+	return qb, b.args, nil
+}
+
+// Update перезаписывает поля подписки. Изменение цены не переписывает прошлое: новая цена
+// записывается в историю с текущего месяца (или с месяца начала, если он ещё не наступил).
+// Если sub.Version задана, запись проходит только при совпадении версии, иначе
+// возвращается ErrVersionConflict. После записи sub.Version — новая версия.
 func (s *store) Update(ctx context.Context, sub *model.Subscription) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
 	query := `
 		UPDATE subscriptions
-		SET service_name = $1, price = $2, user_id = $3, start_date = $4, end_date = $5
-		WHERE id = $6
+		SET service_name = $1, price = $2, user_id = $3, start_date = $4, end_date = $5,
+			billing_period = $6, currency = $7, trial_end = $8, service_id = $10,
+			category = $11, tags = COALESCE($12::text[], '{}'),
+			version = version + 1, updated_at = now()
+		WHERE id = $9 AND ($13::int = 0 OR version = $13::int)
+		RETURNING version, updated_at
 	`
-	res, err := s.db.ExecContext(ctx, query,
-		sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.ID,
-	)
+	err = tx.QueryRowContext(ctx, query,
+		sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate,
+		sub.BillingPeriod, sub.Currency, sub.TrialEnd, sub.ID, sub.ServiceID,
+		sub.Category, sub.Tags, sub.Version,
+	).Scan(&sub.Version, &sub.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.versionConflict(ctx, tx, sub.ID)
+	}
 	if err != nil {
 		return err
 	}
-	n, err := res.RowsAffected()
-	if err != nil {
+
+	// если начало подписки сдвинули раньше, начальная цена должна действовать с нового начала
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE subscription_prices
+		SET effective_month = date_trunc('month', $2::date)::date
+		WHERE subscription_id = $1::uuid
+		  AND effective_month > date_trunc('month', $2::date)
+		  AND effective_month = (SELECT MIN(effective_month) FROM subscription_prices WHERE subscription_id = $1::uuid)
+	`, sub.ID, sub.StartDate); err != nil {
 		return err
 	}
-	if n == 0 {
-		return sql.ErrNoRows
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO subscription_prices (subscription_id, effective_month, price)
+		SELECT $1::uuid, m.month, $2::int
+		FROM (SELECT GREATEST(date_trunc('month', CURRENT_DATE), date_trunc('month', $3::date))::date AS month) m
+		WHERE $2::int IS DISTINCT FROM (
+			SELECT sp.price FROM subscription_prices sp
+			WHERE sp.subscription_id = $1::uuid AND sp.effective_month <= m.month
+			ORDER BY sp.effective_month DESC
+			LIMIT 1
+		)
+		ON CONFLICT (subscription_id, effective_month) DO UPDATE SET price = EXCLUDED.price
+	`, sub.ID, sub.Price, sub.StartDate); err != nil {
+		return err
 	}
-	return nil
+
+	return tx.commit()
 }
 
-func (s *store) Delete(ctx context.Context, id string) error {
-	res, err := s.db.ExecContext(ctx, `DELETE FROM subscriptions WHERE id = $1`, id)
+// Delete удаляет подписку; version != 0 — только если её версия не изменилась
+func (s *store) Delete(ctx context.Context, id string, version int) error {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM subscriptions WHERE id = $1 AND ($2::int = 0 OR version = $2::int)`, id, version)
 	if err != nil {
 		return err
 	}
@@ -315,70 +511,20 @@ func (s *store) Delete(ctx context.Context, id string) error {
 		return err
 	}
 	if n == 0 {
-		return sql.ErrNoRows
+		return s.versionConflict(ctx, s.db, id)
 	}
 	return nil
 }
 
-func (s *store) AggregateTotal(ctx context.Context, from, to string, userID, serviceName *string) (int64, error) {
-	query := `
-SELECT COALESCE(SUM(price * months), 0)::bigint AS total FROM (
-  SELECT price,
-    CASE
-      WHEN LEAST(COALESCE(end_date, to_date($2,'MM-YYYY')), to_date($2,'MM-YYYY')) >= GREATEST(start_date, to_date($1,'MM-YYYY')) THEN
-        (
-          (date_part('year', age(LEAST(COALESCE(end_date, to_date($2,'MM-YYYY')), to_date($2,'MM-YYYY')), GREATEST(start_date, to_date($1,'MM-YYYY')))) * 12)
-          + date_part('month', age(LEAST(COALESCE(end_date, to_date($2,'MM-YYYY')), to_date($2,'MM-YYYY')), GREATEST(start_date, to_date($1,'MM-YYYY'))))
-          + 1
-        )::int
-      ELSE 0
-    END AS months
-  FROM subscriptions
-  WHERE start_date <= to_date($2,'MM-YYYY')
-    AND (end_date IS NULL OR end_date >= to_date($1,'MM-YYYY'))
-    AND ($3::uuid IS NULL OR user_id = $3::uuid)
-    AND ($4::text IS NULL OR service_name ILIKE $4::text)
-) t;
-`
-	var uid interface{} = nil
-	var sname interface{} = nil
-	if userID != nil && *userID != "" {
-		uid = *userID
-	}
-	if serviceName != nil && *serviceName != "" {
-		sname = "%" + *serviceName + "%"
-	}
-
-	var total int64
-	if err := s.db.GetContext(ctx, &total, query, from, to, uid, sname); err != nil {
-		return 0, err
-	}
-	return total, nil
-}
-
-func (s *store) FindSubscriptionsOverlapping(ctx context.Context, from, to string, userID, serviceName *string) ([]*model.Subscription, error) {
-	query := `
-    SELECT id, service_name, price, user_id, start_date, end_date
-    FROM subscriptions
-    WHERE start_date <= to_date($2,'MM-YYYY')
-    AND (end_date IS NULL OR end_date >= to_date($1,'MM-YYYY'))
-    AND ($3::uuid IS NULL OR user_id = $3::uuid)
-    AND ($4::text IS NULL OR service_name ILIKE $4::text)
-    ORDER BY service_name
-    `
-
-	var uid interface{} = nil
-	var sname interface{} = nil
-	if userID != nil && *userID != "" {
-		uid = *userID
-	}
-	if serviceName != nil && *serviceName != "" {
-		sname = "%" + *serviceName + "%"
+// versionConflict объясняет, почему условная запись не затронула подписку:
+// её нет (sql.ErrNoRows) или у неё другая версия (ErrVersionConflict)
+func (s *store) versionConflict(ctx context.Context, q sqlx.QueryerContext, id string) error {
+	var exists bool
+	if err := sqlx.GetContext(ctx, q, &exists, `SELECT EXISTS (SELECT 1 FROM subscriptions WHERE id = $1)`, id); err != nil {
+		return err
 	}
-
-	subs := []*model.Subscription{}
-	if err := s.db.SelectContext(ctx, &subs, query, from, to, uid, sname); err != nil {
-		return nil, err
+	if !exists {
+		return sql.ErrNoRows
 	}
-	return subs, nil
+	return ErrVersionConflict
 }
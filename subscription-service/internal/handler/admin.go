@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"subscription-service/internal/model"
+)
+
+// requireAdmin пропускает к next только запросы с токеном администратора в
+// Authorization: Bearer. Без настроенного токена административные эндпоинты отключены.
+func (h *Handler) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.adminToken == "" {
+			writeProblem(w, r, http.StatusForbidden, codeAdminDisabled, "admin API is disabled: ADMIN_TOKEN is not set")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		// сравнение за постоянное время не выдаёт по задержке, сколько символов токена угадано
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeProblem(w, r, http.StatusUnauthorized, codeUnauthorized, "valid admin bearer token is required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// LoadExchangeRates загружает курсы валют по месяцам; курс на тот же месяц перезаписывается
+func (h *Handler) LoadExchangeRates(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Rates []struct {
+			Base  string  `json:"base"`
+			Quote string  `json:"quote"`
+			Month string  `json:"month"`
+			Rate  float64 `json:"rate"`
+		} `json:"rates"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid json: "+err.Error())
+		return
+	}
+	if len(in.Rates) == 0 {
+		writeInvalid(w, r, invalidField("rates", "rates are required"))
+		return
+	}
+
+	rates := make([]model.ExchangeRate, 0, len(in.Rates))
+	for i, rate := range in.Rates {
+		base := strings.ToUpper(rate.Base)
+		quote := strings.ToUpper(rate.Quote)
+		if !model.ValidCurrency(base) || !model.ValidCurrency(quote) {
+			writeInvalid(w, r, invalidField(fmt.Sprintf("rates[%d]", i), fmt.Sprintf("rates[%d]: base and quote must be ISO 4217 codes", i)))
+			return
+		}
+		if base == quote {
+			writeInvalid(w, r, invalidField(fmt.Sprintf("rates[%d]", i), fmt.Sprintf("rates[%d]: base and quote must differ", i)))
+			return
+		}
+		month, err := parseMonthYear(rate.Month)
+		if err != nil {
+			writeInvalid(w, r, invalidField(fmt.Sprintf("rates[%d].month", i), fmt.Sprintf("rates[%d]: invalid month format, expected MM-YYYY", i)))
+			return
+		}
+		if rate.Rate <= 0 {
+			writeInvalid(w, r, invalidField(fmt.Sprintf("rates[%d].rate", i), fmt.Sprintf("rates[%d]: rate must be > 0", i)))
+			return
+		}
+		rates = append(rates, model.ExchangeRate{Base: base, Quote: quote, Month: month, Rate: rate.Rate})
+	}
+
+	if err := h.svc.LoadExchangeRates(r.Context(), rates); err != nil {
+		h.writeError(w, r, err, "load exchange rates failed")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
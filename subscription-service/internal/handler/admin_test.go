@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"subscription-service/internal/metrics"
+	"subscription-service/internal/model"
+	"subscription-service/internal/service"
+
+	"github.com/rs/zerolog"
+)
+
+// ratesService запоминает загруженные курсы
+type ratesService struct {
+	service.SubscriptionService
+	loaded []model.ExchangeRate
+}
+
+func (s *ratesService) LoadExchangeRates(ctx context.Context, rates []model.ExchangeRate) error {
+	s.loaded = append(s.loaded, rates...)
+	return nil
+}
+
+func TestAdminToken(t *testing.T) {
+	const body = `{"rates":[{"base":"USD","quote":"RUB","month":"01-2025","rate":90}]}`
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		status        int
+		code          string
+	}{
+		{"valid token", "secret", "Bearer secret", http.StatusNoContent, ""},
+		{"no header", "secret", "", http.StatusUnauthorized, codeUnauthorized},
+		{"wrong token", "secret", "Bearer secret2", http.StatusUnauthorized, codeUnauthorized},
+		{"not bearer", "secret", "Basic secret", http.StatusUnauthorized, codeUnauthorized},
+		{"token not configured", "", "Bearer ", http.StatusForbidden, codeAdminDisabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := zerolog.Nop()
+			svc := &ratesService{}
+			router := NewRouter(NewHandler(svc, &log, WithAdminToken(tt.token)), NewHealth(), metrics.New(&log), &log)
+			r := httptest.NewRequest("PUT", "/admin/exchange-rates", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.code == "" {
+				if len(svc.loaded) != 1 {
+					t.Errorf("loaded %d rates, want 1", len(svc.loaded))
+				}
+				return
+			}
+			var p problem
+			decodeBody(t, w, &p)
+			if p.Code != tt.code {
+				t.Errorf("code %q, want %q", p.Code, tt.code)
+			}
+			if len(svc.loaded) != 0 {
+				t.Errorf("rejected request loaded %d rates", len(svc.loaded))
+			}
+			if got := w.Header().Get("WWW-Authenticate"); (tt.status == http.StatusUnauthorized) != (got != "") {
+				t.Errorf("WWW-Authenticate %q with status %d", got, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"subscription-service/internal/metrics"
+	"subscription-service/internal/model"
+	"subscription-service/internal/service"
+
+	"github.com/rs/zerolog"
+)
+
+func TestAggregateMonthly(t *testing.T) {
+	api := newTestAPI(t)
+	api.create(map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025", "end_date": "02-2025"})
+	api.create(map[string]interface{}{"service_name": "Spotify", "price": 200, "user_id": testUser, "start_date": "02-2025"})
+	api.create(map[string]interface{}{"service_name": "Netflix", "price": 999, "user_id": otherUser, "start_date": "01-2025"})
+
+	var got model.MonthlyAggregateResponse
+	api.get("/subscriptions/aggregate?mode=monthly&from=12-2024&to=03-2025&user_id="+testUser, &got)
+
+	want := []model.MonthCost{
+		{Month: "12-2024", Total: 0, Services: []model.ServiceCost{}},
+		{Month: "01-2025", Total: 400, Services: []model.ServiceCost{{ServiceName: "Netflix", Total: 400}}},
+		{Month: "02-2025", Total: 600, Services: []model.ServiceCost{{ServiceName: "Netflix", Total: 400}, {ServiceName: "Spotify", Total: 200}}},
+		{Month: "03-2025", Total: 200, Services: []model.ServiceCost{{ServiceName: "Spotify", Total: 200}}},
+	}
+	if !reflect.DeepEqual(got.Months, want) {
+		t.Errorf("months = %+v, want %+v", got.Months, want)
+	}
+	if got.Total != 1200 {
+		t.Errorf("total = %d, want 1200", got.Total)
+	}
+}
+
+func TestAggregateInvalidMode(t *testing.T) {
+	api := newTestAPI(t)
+	if w := api.do("GET", "/subscriptions/aggregate?mode=weekly&from=01-2025&to=03-2025", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("mode=weekly: status %d, want 400", w.Code)
+	}
+}
+
+func TestAggregateWeightsByActiveMonths(t *testing.T) {
+	api := newTestAPI(t)
+	api.create(map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025", "end_date": "02-2025"})
+	api.create(map[string]interface{}{"service_name": "Spotify", "price": 200, "user_id": testUser, "start_date": "02-2025"})
+
+	var got model.AggregateResponse
+	api.get("/subscriptions/aggregate?from=01-2025&to=03-2025", &got)
+
+	type usage struct {
+		name   string
+		months int
+		cost   int64
+	}
+	var subs []usage
+	for _, s := range got.Subscriptions {
+		subs = append(subs, usage{s.ServiceName, s.Months, s.Cost})
+	}
+	want := []usage{{"Netflix", 2, 800}, {"Spotify", 2, 400}}
+	if !reflect.DeepEqual(subs, want) {
+		t.Errorf("subscriptions = %+v, want %+v", subs, want)
+	}
+	if got.Total != 1200 || got.AggregateTotal != 1200 {
+		t.Errorf("total = %d, aggregate_total = %d, want 1200 both", got.Total, got.AggregateTotal)
+	}
+
+	// помесячный ряд складывается в тот же итог
+	var monthly model.MonthlyAggregateResponse
+	api.get("/subscriptions/aggregate?mode=monthly&from=01-2025&to=03-2025", &monthly)
+	if monthly.Total != got.Total {
+		t.Errorf("monthly total = %d, summary total = %d", monthly.Total, got.Total)
+	}
+}
+
+func TestAggregateBillingPeriods(t *testing.T) {
+	api := newTestAPI(t)
+	// 2025-01-01 — среда: списания 1, 8, 15, 22 и 29 января, 5, 12, 19 и 26 февраля
+	api.create(map[string]interface{}{"service_name": "Weekly", "price": 100, "user_id": testUser, "start_date": "01-2025", "billing_period": "weekly"})
+	api.create(map[string]interface{}{"service_name": "Quarterly", "price": 300, "user_id": testUser, "start_date": "01-2025", "billing_period": "quarterly"})
+	api.create(map[string]interface{}{"service_name": "Yearly", "price": 1200, "user_id": testUser, "start_date": "03-2024", "billing_period": "YEARLY"})
+
+	tests := []struct {
+		from, to string
+		costs    map[string]int64
+	}{
+		{"01-2025", "01-2025", map[string]int64{"Weekly": 500, "Quarterly": 300, "Yearly": 0}},
+		{"01-2025", "02-2025", map[string]int64{"Weekly": 900, "Quarterly": 300, "Yearly": 0}},
+		// окно с точностью до дня: из январских списаний в него попадают только 15 и 22
+		{"2025-01-10", "2025-01-25", map[string]int64{"Weekly": 200}},
+		{"2025-01-29", "2025-02-05", map[string]int64{"Weekly": 200}},
+		{"01-2025", "12-2025", map[string]int64{"Quarterly": 1200, "Yearly": 1200}},
+	}
+	for _, tt := range tests {
+		var got model.AggregateResponse
+		api.get("/subscriptions/aggregate?from="+tt.from+"&to="+tt.to, &got)
+		var total int64
+		for _, s := range got.Subscriptions {
+			total += s.Cost
+			if want, ok := tt.costs[s.ServiceName]; ok && s.Cost != want {
+				t.Errorf("%s..%s: %s cost = %d, want %d", tt.from, tt.to, s.ServiceName, s.Cost, want)
+			}
+		}
+		if got.Total != total || got.AggregateTotal != total {
+			t.Errorf("%s..%s: total = %d, aggregate_total = %d, sum of costs = %d", tt.from, tt.to, got.Total, got.AggregateTotal, total)
+		}
+	}
+}
+
+func TestCreateInvalidBillingPeriod(t *testing.T) {
+	api := newTestAPI(t)
+	w := api.do("POST", "/subscriptions", map[string]interface{}{
+		"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025", "billing_period": "daily",
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("billing_period=daily: status %d, want 400", w.Code)
+	}
+}
+
+func TestAggregateConvertsByMonthlyRates(t *testing.T) {
+	api := newTestAPI(t)
+	api.create(map[string]interface{}{"service_name": "Spotify", "price": 10, "user_id": testUser, "start_date": "01-2025", "currency": "usd"})
+	api.create(map[string]interface{}{"service_name": "Yandex", "price": 900, "user_id": testUser, "start_date": "01-2025"})
+	w := api.do("PUT", "/admin/exchange-rates", map[string]interface{}{"rates": []map[string]interface{}{
+		{"base": "USD", "quote": "RUB", "month": "01-2025", "rate": 90},
+		{"base": "USD", "quote": "RUB", "month": "03-2025", "rate": 100},
+	}})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PUT /admin/exchange-rates: %d %s", w.Code, w.Body)
+	}
+
+	// в феврале действует январский курс
+	var rub model.AggregateResponse
+	api.get("/subscriptions/aggregate?from=01-2025&to=03-2025", &rub)
+	if rub.Currency != "RUB" || rub.Total != 2800+2700 || rub.AggregateTotal != rub.Total {
+		t.Errorf("RUB: currency %q, total %d, aggregate_total %d, want RUB 5500", rub.Currency, rub.Total, rub.AggregateTotal)
+	}
+	for _, s := range rub.Subscriptions {
+		if s.ServiceName == "Spotify" {
+			wantRates := []model.MonthRate{{Month: "01-2025", Rate: 90}, {Month: "02-2025", Rate: 90}, {Month: "03-2025", Rate: 100}}
+			if s.Cost != 30 || s.ConvertedCost != 2800 || !reflect.DeepEqual(s.Rates, wantRates) {
+				t.Errorf("Spotify: cost %d, converted %d, rates %+v", s.Cost, s.ConvertedCost, s.Rates)
+			}
+		}
+	}
+
+	// обратный курс: 900 / 90 × 2 + 900 / 100 + 10 × 3
+	var usd model.MonthlyAggregateResponse
+	api.get("/subscriptions/aggregate?mode=monthly&from=01-2025&to=03-2025&currency=USD", &usd)
+	if usd.Total != 59 {
+		t.Errorf("USD monthly total = %d, want 59", usd.Total)
+	}
+}
+
+func TestAggregateMissingRate(t *testing.T) {
+	api := newTestAPI(t)
+	api.create(map[string]interface{}{"service_name": "Spotify", "price": 10, "user_id": testUser, "start_date": "01-2025", "currency": "USD"})
+	api.create(map[string]interface{}{"service_name": "Yandex", "price": 900, "user_id": testUser, "start_date": "01-2025"})
+	api.do("PUT", "/admin/exchange-rates", map[string]interface{}{"rates": []map[string]interface{}{
+		{"base": "USD", "quote": "RUB", "month": "02-2025", "rate": 90},
+	}})
+
+	for _, mode := range []string{"summary", "monthly"} {
+		w := api.do("GET", "/subscriptions/aggregate?mode="+mode+"&from=01-2025&to=03-2025", nil)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("mode=%s without January rate: status %d, want 422", mode, w.Code)
+		}
+	}
+	// с февраля курс есть
+	var got model.AggregateResponse
+	api.get("/subscriptions/aggregate?from=02-2025&to=03-2025", &got)
+	if got.Total != 1800+1800 {
+		t.Errorf("total = %d, want 3600", got.Total)
+	}
+}
+
+// без единого курса сумма пересчёта в SQL — NULL; клиент всё равно получает 422, а не 500
+func TestAggregateNoRates(t *testing.T) {
+	api := newTestAPI(t)
+	api.create(map[string]interface{}{"service_name": "Spotify", "price": 10, "user_id": testUser, "start_date": "01-2025", "currency": "USD"})
+
+	for _, query := range []string{"mode=summary", "mode=monthly", "mode=summary&format=csv", "mode=monthly&format=csv"} {
+		w := api.do("GET", "/subscriptions/aggregate?from=01-2025&to=03-2025&"+query, nil)
+		var p problem
+		decodeBody(t, w, &p)
+		if w.Code != http.StatusUnprocessableEntity || p.Code != "exchange_rate_not_found" {
+			t.Errorf("%s: %d %+v, want 422 exchange_rate_not_found", query, w.Code, p)
+		}
+	}
+}
+
+func TestLoadExchangeRatesValidation(t *testing.T) {
+	api := newTestAPI(t)
+	for _, rate := range []map[string]interface{}{
+		{"base": "USD", "quote": "USD", "month": "01-2025", "rate": 1},
+		{"base": "US", "quote": "RUB", "month": "01-2025", "rate": 90},
+		{"base": "USD", "quote": "RUB", "month": "2025-01", "rate": 90},
+		{"base": "USD", "quote": "RUB", "month": "01-2025", "rate": 0},
+	} {
+		w := api.do("PUT", "/admin/exchange-rates", map[string]interface{}{"rates": []map[string]interface{}{rate}})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%v: status %d, want 400", rate, w.Code)
+		}
+	}
+}
+
+func TestAggregateDailyProration(t *testing.T) {
+	api := newTestAPI(t)
+	api.create(map[string]interface{}{"service_name": "Netflix", "price": 310, "user_id": testUser, "start_date": "2025-01-11"})
+	api.create(map[string]interface{}{"service_name": "Spotify", "price": 280, "user_id": testUser, "start_date": "02-2025", "end_date": "2025-02-14"})
+
+	tests := []struct {
+		query string
+		costs map[string]int64
+		days  map[string]int
+	}{
+		// в помесячном режиме неполный месяц учитывается целиком
+		{"from=01-2025&to=02-2025", map[string]int64{"Netflix": 620, "Spotify": 280}, map[string]int{"Netflix": 49, "Spotify": 14}},
+		// 310 × 21/31 + 310 × 28/28 и 280 × 14/28
+		{"from=01-2025&to=02-2025&proration=daily", map[string]int64{"Netflix": 520, "Spotify": 140}, map[string]int{"Netflix": 49, "Spotify": 14}},
+		// окно с точностью до дня: 310 × 16/31
+		{"from=2025-01-16&to=01-2025&proration=daily", map[string]int64{"Netflix": 160}, map[string]int{"Netflix": 16}},
+	}
+	for _, tt := range tests {
+		var got model.AggregateResponse
+		api.get("/subscriptions/aggregate?"+tt.query, &got)
+		costs := map[string]int64{}
+		days := map[string]int{}
+		for _, s := range got.Subscriptions {
+			costs[s.ServiceName] = s.Cost
+			days[s.ServiceName] = s.Days
+		}
+		if !reflect.DeepEqual(costs, tt.costs) || !reflect.DeepEqual(days, tt.days) {
+			t.Errorf("%s: costs %v, days %v; want %v, %v", tt.query, costs, days, tt.costs, tt.days)
+		}
+	}
+
+	if w := api.do("GET", "/subscriptions/aggregate?from=01-2025&to=02-2025&proration=hourly", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("proration=hourly: status %d, want 400", w.Code)
+	}
+	if w := api.do("GET", "/subscriptions/aggregate?from=2025-02-10&to=2025-02-09", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("from after to: status %d, want 400", w.Code)
+	}
+}
+
+// aggregateService отдаёт заданные итоги деталей и SQL-агрегации
+type aggregateService struct {
+	service.SubscriptionService
+	total, aggregateTotal int64
+}
+
+func (s *aggregateService) AggregateWithDetails(ctx context.Context, f model.AggregateFilter) ([]model.SubscriptionInfo, int64, error) {
+	return []model.SubscriptionInfo{}, s.total, nil
+}
+
+func (s *aggregateService) Aggregate(ctx context.Context, f model.AggregateFilter) (int64, error) {
+	return s.aggregateTotal, nil
+}
+
+func TestAggregateTotalMismatch(t *testing.T) {
+	tests := []struct {
+		total, aggregateTotal int64
+		warn                  bool
+	}{
+		{1200, 1200, false},
+		{1200, 1199, true},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		log := zerolog.New(&buf)
+		svc := &aggregateService{total: tt.total, aggregateTotal: tt.aggregateTotal}
+		router := NewRouter(NewHandler(svc, &log), NewHealth(), metrics.New(&log), &log)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/subscriptions/aggregate?from=01-2025&to=03-2025", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%d/%d: status %d %s", tt.total, tt.aggregateTotal, w.Code, w.Body)
+		}
+
+		var got model.AggregateResponse
+		decodeBody(t, w, &got)
+		if got.Total != tt.total || got.AggregateTotal != tt.aggregateTotal {
+			t.Errorf("total = %d, aggregate_total = %d, want %d, %d", got.Total, got.AggregateTotal, tt.total, tt.aggregateTotal)
+		}
+		if warned := strings.Contains(buf.String(), "aggregate total differs from details"); warned != tt.warn {
+			t.Errorf("%d/%d: warning logged = %v, want %v\n%s", tt.total, tt.aggregateTotal, warned, tt.warn, buf.String())
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"subscription-service/internal/db"
+	"subscription-service/internal/dbtest"
+	"subscription-service/internal/metrics"
+	"subscription-service/internal/model"
+	"subscription-service/internal/service"
+	"subscription-service/internal/tracing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog"
+)
+
+// testAPI — роутер сервиса поверх настоящего хранилища в отдельной схеме тестовой базы
+type testAPI struct {
+	t      *testing.T
+	db     *sqlx.DB
+	router http.Handler
+}
+
+// newTestAPI собирает сервис так же, как main; opts передаются в service.New
+func newTestAPI(t *testing.T, opts ...service.Option) *testAPI {
+	t.Helper()
+	log := zerolog.Nop()
+	conn := dbtest.Open(t)
+	m := metrics.New(&log)
+	repo := db.Instrument(db.Instrument(db.NewStore(conn), m.QueryHook), tracing.QueryHook)
+	svc := service.Trace(service.New(repo, &log, opts...))
+	m.RegisterStats(service.New(db.NewStore(conn), &log).Stats)
+	return &testAPI{t: t, db: conn, router: NewRouter(NewHandler(svc, &log, WithAdminToken(testAdminToken)), NewHealth(), m, &log)}
+}
+
+// do выполняет запрос; body, если не nil, кодируется в JSON
+func (a *testAPI) do(method, path string, body interface{}) *httptest.ResponseRecorder {
+	a.t.Helper()
+	if body == nil {
+		return a.send(method, path, "", "")
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		a.t.Fatal(err)
+	}
+	return a.send(method, path, "application/json", buf.String())
+}
+
+// send выполняет запрос с телом body как есть; пустой contentType не выставляется.
+// Запрос несёт токен администратора, чтобы тесты могли загружать курсы.
+func (a *testAPI) send(method, path, contentType, body string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+testAdminToken)
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, r)
+	return w
+}
+
+// create создаёт подписку и возвращает её id
+func (a *testAPI) create(body map[string]interface{}) string {
+	a.t.Helper()
+	w := a.do("POST", "/subscriptions", body)
+	if w.Code != http.StatusCreated {
+		a.t.Fatalf("POST /subscriptions %v: %d %s", body, w.Code, w.Body)
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	decodeBody(a.t, w, &created)
+	return created.ID
+}
+
+// exec выполняет SQL напрямую, минуя API, например чтобы задать даты пауз в прошлом
+func (a *testAPI) exec(query string, args ...interface{}) {
+	a.t.Helper()
+	if _, err := a.db.Exec(query, args...); err != nil {
+		a.t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// get выполняет GET и декодирует ответ 200 в out
+func (a *testAPI) get(path string, out interface{}) {
+	a.t.Helper()
+	w := a.do("GET", path, nil)
+	if w.Code != http.StatusOK {
+		a.t.Fatalf("GET %s: %d %s", path, w.Code, w.Body)
+	}
+	decodeBody(a.t, w, out)
+}
+
+// list возвращает подписки первой страницы списка
+func (a *testAPI) list(path string) []*model.Subscription {
+	a.t.Helper()
+	var page model.SubscriptionPage
+	a.get(path, &page)
+	return page.Items
+}
+
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder, out interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+		t.Fatalf("decode response %s: %v", w.Body, err)
+	}
+}
+
+const (
+	testUser       = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+	otherUser      = "40601fee-2bf1-4721-ae6f-7636e79a0cba"
+	testAdminToken = "test-admin-token"
+)
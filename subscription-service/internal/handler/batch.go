@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// maxBatchSize — сколько операций можно передать в одном пакете
+const maxBatchSize = 1000
+
+const (
+	batchAtomic     = "atomic"
+	batchBestEffort = "best_effort"
+)
+
+type batchRequest struct {
+	Mode       string             `json:"mode"`
+	Operations []batchRequestItem `json:"operations"`
+}
+
+type batchRequestItem struct {
+	Op           model.BatchOp      `json:"op"`
+	ID           string             `json:"id,omitempty"`
+	Version      int                `json:"version,omitempty"` // как If-Match: 0 — любая версия
+	Subscription *subscriptionInput `json:"subscription,omitempty"`
+}
+
+type batchItemResult struct {
+	Index        int                 `json:"index"`
+	Op           model.BatchOp       `json:"op"`
+	ID           string              `json:"id,omitempty"`
+	Status       int                 `json:"status"`         // HTTP-статус, который получила бы операция отдельным запросом
+	Code         string              `json:"code,omitempty"` // код ошибки, как в problem+json
+	Error        string              `json:"error,omitempty"`
+	Errors       []fieldError        `json:"errors,omitempty"` // ошибки проверки по полям
+	Subscription *model.Subscription `json:"subscription,omitempty"`
+}
+
+type batchResponse struct {
+	Mode      string            `json:"mode"`
+	Committed bool              `json:"committed"` // false — атомарный пакет откачен целиком
+	Results   []batchItemResult `json:"results"`
+}
+
+// BatchSubscriptions выполняет пакет операций create, update и delete.
+// mode=atomic (по умолчанию) — все операции в одной транзакции: при первой ошибке пакет
+// откатывается и ответ 422; mode=best_effort — операции применяются независимо.
+// В results у каждой операции свой статус.
+func (h *Handler) BatchSubscriptions(w http.ResponseWriter, r *http.Request) {
+	var in batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid json: "+err.Error())
+		return
+	}
+	if in.Mode == "" {
+		in.Mode = batchAtomic
+	}
+	if in.Mode != batchAtomic && in.Mode != batchBestEffort {
+		writeInvalid(w, r, invalidField("mode", "invalid mode, expected atomic or best_effort"))
+		return
+	}
+	if len(in.Operations) == 0 {
+		writeInvalid(w, r, invalidField("operations", "operations are required"))
+		return
+	}
+	if len(in.Operations) > maxBatchSize {
+		writeInvalid(w, r, invalidField("operations", fmt.Sprintf("too many operations, max %d", maxBatchSize)))
+		return
+	}
+
+	resp := batchResponse{Mode: in.Mode, Committed: true, Results: make([]batchItemResult, len(in.Operations))}
+	ops := make([]model.BatchOperation, 0, len(in.Operations))
+	index := make([]int, 0, len(in.Operations)) // номер операции запроса для каждой из ops
+	invalid := false
+	for i, item := range in.Operations {
+		resp.Results[i] = batchItemResult{Index: i, Op: item.Op, ID: item.ID}
+
+		op, err := item.toOperation()
+		if err != nil {
+			resp.Results[i].Status = http.StatusBadRequest
+			resp.Results[i].Code = codeValidationFailed
+			resp.Results[i].Error = err.Error()
+			var fields validationErrors
+			if errors.As(err, &fields) {
+				resp.Results[i].Errors = fields
+			}
+			invalid = true
+			continue
+		}
+		ops = append(ops, op)
+		index = append(index, i)
+	}
+
+	if invalid && in.Mode == batchAtomic {
+		resp.Committed = false
+		for i := range resp.Results {
+			if resp.Results[i].Status == 0 {
+				resp.Results[i].Status = http.StatusFailedDependency
+				resp.Results[i].Code = "batch_rolled_back"
+				resp.Results[i].Error = "not executed: batch has invalid operations"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	results, committed, err := h.svc.Batch(r.Context(), ops, in.Mode == batchAtomic)
+	if err != nil {
+		h.writeError(w, r, err, "batch failed")
+		return
+	}
+	resp.Committed = committed
+
+	for j, res := range results {
+		item := &resp.Results[index[j]]
+		item.Status, item.Code, item.Error = h.batchStatus(r.Context(), ops[j].Op, res.Err)
+		if res.Err == nil && res.Subscription != nil {
+			item.ID = res.Subscription.ID
+			item.Subscription = res.Subscription
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !committed {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// toOperation проверяет операцию пакета так же, как отдельный запрос
+func (item batchRequestItem) toOperation() (model.BatchOperation, error) {
+	op := model.BatchOperation{Op: item.Op, ID: item.ID, Version: item.Version}
+
+	switch item.Op {
+	case model.BatchCreate:
+		if item.ID != "" {
+			return op, errors.New("id must not be set for create")
+		}
+	case model.BatchUpdate, model.BatchDelete:
+		if _, err := uuid.Parse(item.ID); err != nil {
+			return op, errors.New("id must be a valid UUID")
+		}
+	default:
+		return op, errors.New("op must be one of create, update, delete")
+	}
+	if item.Version < 0 {
+		return op, errors.New("version must be >= 0")
+	}
+
+	if item.Op == model.BatchDelete {
+		return op, nil
+	}
+	if item.Subscription == nil {
+		return op, errors.New("subscription is required")
+	}
+	sub, err := item.Subscription.toModel()
+	if err != nil {
+		return op, err
+	}
+	op.Subscription = sub
+	return op, nil
+}
+
+// batchStatus переводит итог операции пакета в HTTP-статус, код и текст ошибки —
+// те же, что получил бы отдельный запрос
+func (h *Handler) batchStatus(ctx context.Context, op model.BatchOp, err error) (int, string, string) {
+	switch {
+	case err == nil && op == model.BatchCreate:
+		return http.StatusCreated, "", ""
+	case err == nil && op == model.BatchDelete:
+		return http.StatusNoContent, "", ""
+	case err == nil:
+		return http.StatusOK, "", ""
+	case errors.Is(err, service.ErrServiceNotFound):
+		return http.StatusBadRequest, codeValidationFailed, "unknown service_id"
+	}
+	status, code, ok := domainError(err)
+	if !ok {
+		h.logger(ctx).Error().Ctx(ctx).Err(err).Str("op", string(op)).Msg("batch operation failed")
+		return status, code, "internal error"
+	}
+	return status, code, err.Error()
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"subscription-service/internal/model"
+)
+
+type batchResult struct {
+	Committed bool `json:"committed"`
+	Results   []struct {
+		Status int    `json:"status"`
+		ID     string `json:"id"`
+	} `json:"results"`
+}
+
+func TestBatchAtomic(t *testing.T) {
+	api := newTestAPI(t)
+	netflix := map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025"}
+	spotify := map[string]interface{}{"service_name": "Spotify", "price": 200, "user_id": testUser, "start_date": "01-2025"}
+	id := api.create(netflix)
+	updated := map[string]interface{}{"service_name": "Netflix", "price": 500, "user_id": testUser, "start_date": "01-2025"}
+
+	// удаление несуществующей подписки откатывает весь пакет, включая обновление с вложенной транзакцией
+	w := api.do("POST", "/subscriptions/batch", map[string]interface{}{"operations": []map[string]interface{}{
+		{"op": "create", "subscription": spotify},
+		{"op": "update", "id": id, "version": 1, "subscription": updated},
+		{"op": "delete", "id": "00000000-0000-0000-0000-000000000000"},
+	}})
+	var got batchResult
+	decodeBody(t, w, &got)
+	if w.Code != http.StatusUnprocessableEntity || got.Committed || statuses(got) != "[424 424 404]" {
+		t.Fatalf("failed atomic batch: %d committed=%v statuses %s", w.Code, got.Committed, statuses(got))
+	}
+	subs := api.list("/subscriptions")
+	if len(subs) != 1 || subs[0].Price != 400 || subs[0].Version != 1 {
+		t.Fatalf("after rollback: %+v", subs)
+	}
+
+	w = api.do("POST", "/subscriptions/batch", map[string]interface{}{"operations": []map[string]interface{}{
+		{"op": "create", "subscription": spotify},
+		{"op": "update", "id": id, "version": 1, "subscription": updated},
+	}})
+	decodeBody(t, w, &got)
+	if w.Code != http.StatusOK || !got.Committed || statuses(got) != "[201 200]" || got.Results[0].ID == "" {
+		t.Fatalf("atomic batch: %d %s", w.Code, w.Body)
+	}
+	var sub model.Subscription
+	api.get("/subscriptions/"+id, &sub)
+	if sub.Price != 500 || sub.Version != 2 {
+		t.Errorf("after batch update: price %d, version %d", sub.Price, sub.Version)
+	}
+
+	// ошибка проверки одной операции: атомарный пакет не выполняется вовсе
+	w = api.do("POST", "/subscriptions/batch", map[string]interface{}{"operations": []map[string]interface{}{
+		{"op": "create", "subscription": spotify},
+		{"op": "merge", "id": id},
+	}})
+	decodeBody(t, w, &got)
+	if w.Code != http.StatusUnprocessableEntity || statuses(got) != "[424 400]" {
+		t.Errorf("invalid atomic batch: %d statuses %s", w.Code, statuses(got))
+	}
+	subs = api.list("/subscriptions")
+	if len(subs) != 2 {
+		t.Errorf("%d subscriptions after invalid batch, want 2", len(subs))
+	}
+}
+
+func TestBatchBestEffort(t *testing.T) {
+	api := newTestAPI(t)
+	sub := map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025"}
+	id := api.create(sub)
+	api.do("PUT", "/subscriptions/"+id, sub) // версия 2
+
+	w := api.do("POST", "/subscriptions/batch", map[string]interface{}{"mode": "best_effort", "operations": []map[string]interface{}{
+		{"op": "update", "id": id, "version": 1, "subscription": sub},
+		{"op": "create", "subscription": map[string]interface{}{"price": 1}},
+		{"op": "create", "subscription": sub},
+		{"op": "delete", "id": id},
+	}})
+	var got batchResult
+	decodeBody(t, w, &got)
+	if w.Code != http.StatusOK || !got.Committed || statuses(got) != "[412 400 201 204]" {
+		t.Errorf("best effort batch: %d committed=%v statuses %s", w.Code, got.Committed, statuses(got))
+	}
+
+	for _, body := range []map[string]interface{}{
+		{"mode": "parallel", "operations": []map[string]interface{}{{"op": "delete", "id": id}}},
+		{"operations": []map[string]interface{}{}},
+	} {
+		if w := api.do("POST", "/subscriptions/batch", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%v: status %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func statuses(r batchResult) string {
+	s := make([]int, len(r.Results))
+	for i, res := range r.Results {
+		s[i] = res.Status
+	}
+	return fmt.Sprint(s)
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// catalogInput — тело запроса на создание и обновление услуги каталога
+type catalogInput struct {
+	Name         string   `json:"name"`
+	Aliases      []string `json:"aliases,omitempty"`
+	Category     *string  `json:"category,omitempty"`
+	DefaultPrice *int     `json:"default_price,omitempty"`
+	Currency     string   `json:"currency,omitempty"` // валюта цены по умолчанию
+}
+
+func (in catalogInput) toModel() (*model.CatalogService, error) {
+	var errs validationErrors
+	if strings.TrimSpace(in.Name) == "" {
+		errs.add("name", "name is required")
+	}
+	if in.DefaultPrice != nil && *in.DefaultPrice < 0 {
+		errs.add("default_price", "default_price must be >= 0")
+	}
+
+	currency := model.DefaultCurrency
+	if in.Currency != "" {
+		currency = strings.ToUpper(in.Currency)
+		if !model.ValidCurrency(currency) {
+			errs.add("currency", "currency must be an ISO 4217 code")
+		}
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	var category *string
+	if in.Category != nil && strings.TrimSpace(*in.Category) != "" {
+		c := strings.TrimSpace(*in.Category)
+		category = &c
+	}
+
+	return &model.CatalogService{
+		Name:         in.Name,
+		Aliases:      in.Aliases,
+		Category:     category,
+		DefaultPrice: in.DefaultPrice,
+		Currency:     currency,
+	}, nil
+}
+
+// serviceID читает id услуги из пути. Услуги с id не в формате UUID быть не может:
+// отвечает 404 и возвращает ok = false.
+func serviceID(w http.ResponseWriter, r *http.Request) (id string, ok bool) {
+	id = mux.Vars(r)["id"]
+	if _, err := uuid.Parse(id); err != nil {
+		writeProblem(w, r, http.StatusNotFound, "service_not_found", service.ErrServiceNotFound.Error())
+		return "", false
+	}
+	return id, true
+}
+
+func (h *Handler) CreateService(w http.ResponseWriter, r *http.Request) {
+	var in catalogInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid json: "+err.Error())
+		return
+	}
+
+	svc, err := in.toModel()
+	if err != nil {
+		writeInvalid(w, r, err)
+		return
+	}
+
+	if err := h.svc.CreateService(r.Context(), svc); err != nil {
+		h.writeError(w, r, err, "create service failed")
+		return
+	}
+
+	w.Header().Set("Location", "/services/"+svc.ID)
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, svc)
+}
+
+func (h *Handler) ListServices(w http.ResponseWriter, r *http.Request) {
+	services, err := h.svc.ListServices(r.Context())
+	if err != nil {
+		h.writeError(w, r, err, "list services failed")
+		return
+	}
+
+	writeJSON(w, services)
+}
+
+func (h *Handler) GetService(w http.ResponseWriter, r *http.Request) {
+	id, ok := serviceID(w, r)
+	if !ok {
+		return
+	}
+
+	svc, err := h.svc.GetService(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err, "get service failed")
+		return
+	}
+
+	writeJSON(w, svc)
+}
+
+func (h *Handler) UpdateService(w http.ResponseWriter, r *http.Request) {
+	id, ok := serviceID(w, r)
+	if !ok {
+		return
+	}
+
+	var in catalogInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid json: "+err.Error())
+		return
+	}
+
+	svc, err := in.toModel()
+	if err != nil {
+		writeInvalid(w, r, err)
+		return
+	}
+	svc.ID = id
+
+	if err := h.svc.UpdateService(r.Context(), svc); err != nil {
+		h.writeError(w, r, err, "update service failed")
+		return
+	}
+
+	writeJSON(w, svc)
+}
+
+func (h *Handler) DeleteService(w http.ResponseWriter, r *http.Request) {
+	id, ok := serviceID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.DeleteService(r.Context(), id); err != nil {
+		h.writeError(w, r, err, "delete service failed")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
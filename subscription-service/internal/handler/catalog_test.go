@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"subscription-service/internal/model"
+)
+
+func TestCatalogResolvesSubscriptions(t *testing.T) {
+	api := newTestAPI(t)
+	w := api.do("POST", "/services", map[string]interface{}{
+		"name": " Yandex  Plus ", "aliases": []string{"Яндекс  Плюс", "yandex plus"}, "default_price": 299,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /services: %d %s", w.Code, w.Body)
+	}
+	var svc model.CatalogService
+	decodeBody(t, w, &svc)
+	if svc.Name != "Yandex Plus" || len(svc.Aliases) != 1 || svc.Aliases[0] != "яндекс плюс" {
+		t.Errorf("service normalized to %q %v", svc.Name, svc.Aliases)
+	}
+
+	// псевдоним уже занят
+	if w := api.do("POST", "/services", map[string]interface{}{"name": "Plus", "aliases": []string{"ЯНДЕКС плюс"}}); w.Code != http.StatusConflict {
+		t.Errorf("duplicate alias: status %d, want 409", w.Code)
+	}
+
+	// название подписки заменяется каноническим, цена берётся из каталога
+	id := api.create(map[string]interface{}{"service_name": "яндекс ПЛЮС", "user_id": testUser, "start_date": "01-2025"})
+	var sub model.Subscription
+	api.get("/subscriptions/"+id, &sub)
+	if sub.ServiceName != "Yandex Plus" || sub.Price != 299 || sub.ServiceID == nil || *sub.ServiceID != svc.ID {
+		t.Errorf("subscription %+v not linked to catalog service %s", sub, svc.ID)
+	}
+	// названия вне каталога сохраняются как есть
+	api.create(map[string]interface{}{"service_name": "Yandex Plus Family", "price": 500, "user_id": testUser, "start_date": "01-2025"})
+
+	// название из каталога фильтрует по услуге, а не по подстроке
+	var got model.AggregateResponse
+	api.get("/subscriptions/aggregate?from=01-2025&to=01-2025&service_name=Яндекс%20Плюс", &got)
+	if got.Total != 299 {
+		t.Errorf("aggregate by alias: total %d, want 299", got.Total)
+	}
+	api.get("/subscriptions/aggregate?from=01-2025&to=01-2025&service_id="+svc.ID, &got)
+	if got.Total != 299 {
+		t.Errorf("aggregate by service_id: total %d, want 299", got.Total)
+	}
+
+	if w := api.do("POST", "/subscriptions", map[string]interface{}{
+		"service_id": "00000000-0000-0000-0000-000000000000", "price": 100, "user_id": testUser, "start_date": "01-2025",
+	}); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown service_id: status %d, want 400", w.Code)
+	}
+
+	// удаление услуги отвязывает подписки, но не удаляет их
+	if w := api.do("DELETE", "/services/"+svc.ID, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /services: %d %s", w.Code, w.Body)
+	}
+	if w := api.do("GET", "/services/"+svc.ID, nil); w.Code != http.StatusNotFound {
+		t.Errorf("deleted service: status %d, want 404", w.Code)
+	}
+	api.get("/subscriptions/"+id, &sub)
+	if sub.ServiceID != nil || sub.ServiceName != "Yandex Plus" {
+		t.Errorf("after service delete: %+v", sub)
+	}
+}
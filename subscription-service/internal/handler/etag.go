@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"subscription-service/internal/model"
+)
+
+// etag — ETag подписки: её версия, которая растёт при каждом изменении
+func etag(sub *model.Subscription) string {
+	return `"` + strconv.Itoa(sub.Version) + `"`
+}
+
+// ifMatch разбирает If-Match в список ожидаемых версий; any — заголовка нет или он равен "*".
+// Слабые ETag (W/"...") If-Match не удовлетворяют и пропускаются.
+func ifMatch(r *http.Request) (versions []int, any bool) {
+	header := strings.Join(r.Header.Values("If-Match"), ",")
+	if strings.TrimSpace(header) == "" {
+		return nil, true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true
+		}
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		if v, err := strconv.Atoi(tag[1 : len(tag)-1]); err == nil && v > 0 {
+			versions = append(versions, v)
+		}
+	}
+	return versions, false
+}
+
+// matchVersion проверяет If-Match против текущей версии подписки
+func matchVersion(r *http.Request, current int) bool {
+	versions, any := ifMatch(r)
+	if any {
+		return true
+	}
+	for _, v := range versions {
+		if v == current {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedVersion возвращает версию, при которой должна пройти запись в подписку id:
+// 0 — If-Match не задан, писать можно в любую версию. Если ни один ETag не совпадает
+// с текущей версией, отвечает 412 и возвращает ok = false.
+func (h *Handler) expectedVersion(w http.ResponseWriter, r *http.Request, id string) (version int, ok bool) {
+	if _, any := ifMatch(r); any {
+		return 0, true
+	}
+
+	current, err := h.svc.GetByID(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err, "get subscription failed")
+		return 0, false
+	}
+	if !matchVersion(r, current.Version) {
+		w.Header().Set("ETag", etag(current))
+		writeProblem(w, r, http.StatusPreconditionFailed, codePreconditionFailed, "subscription was modified, ETag does not match")
+		return 0, false
+	}
+	return current.Version, true
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  []string
+		versions []int
+		any      bool
+	}{
+		{"no header", nil, nil, true},
+		{"blank header", []string{"  "}, nil, true},
+		{"wildcard", []string{"*"}, nil, true},
+		{"wildcard in list", []string{`"1", *`}, nil, true},
+		{"single tag", []string{`"3"`}, []int{3}, false},
+		{"list", []string{`"1", "2"`}, []int{1, 2}, false},
+		{"repeated headers", []string{`"1"`, `"5"`}, []int{1, 5}, false},
+		{"weak tag skipped", []string{`W/"3", "4"`}, []int{4}, false},
+		{"unquoted skipped", []string{`3`}, nil, false},
+		{"not a version", []string{`"abc"`, `"0"`, `"-1"`}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "/subscriptions/1", nil)
+			for _, h := range tt.headers {
+				r.Header.Add("If-Match", h)
+			}
+			versions, any := ifMatch(r)
+			if !reflect.DeepEqual(versions, tt.versions) || any != tt.any {
+				t.Errorf("ifMatch() = %v, %v; want %v, %v", versions, any, tt.versions, tt.any)
+			}
+		})
+	}
+}
+
+func TestMatchVersion(t *testing.T) {
+	tests := []struct {
+		header  string
+		current int
+		want    bool
+	}{
+		{"", 7, true},
+		{"*", 7, true},
+		{`"7"`, 7, true},
+		{`"6"`, 7, false},
+		{`"6", "7"`, 7, true},
+		{`W/"7"`, 7, false},
+		{`"abc"`, 7, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("PUT", "/subscriptions/1", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+		if got := matchVersion(r, tt.current); got != tt.want {
+			t.Errorf("matchVersion(If-Match: %s, %d) = %v, want %v", tt.header, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestOptimisticLocking(t *testing.T) {
+	api := newTestAPI(t)
+	sub := map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025"}
+	id := api.create(sub)
+	path := "/subscriptions/" + id
+
+	request := func(method, ifMatch, contentType, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		api.router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request("GET", "", "", "")
+	if tag := w.Header().Get("ETag"); tag != `"1"` {
+		t.Fatalf("GET ETag = %s, want \"1\"", tag)
+	}
+
+	w = request("PUT", `"1"`, "application/json", `{"service_name":"Netflix","price":500,"user_id":"`+testUser+`","start_date":"01-2025"}`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("PUT with current ETag: %d, ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	// устаревший ETag: 412 и текущая версия в ответе
+	w = request("PUT", `"1"`, "application/json", `{"service_name":"Netflix","price":600,"user_id":"`+testUser+`","start_date":"01-2025"}`)
+	if w.Code != http.StatusPreconditionFailed || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("PUT with stale ETag: %d, ETag %s; want 412, \"2\"", w.Code, w.Header().Get("ETag"))
+	}
+	if w = request("PATCH", `"1", W/"2"`, mergePatchType, `{"price":600}`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("PATCH with stale and weak ETags: %d, want 412", w.Code)
+	}
+	if w = request("PATCH", `"7", "2"`, mergePatchType, `{"price":700}`); w.Code != http.StatusOK || w.Header().Get("ETag") != `"3"` {
+		t.Errorf("PATCH with matching ETag in list: %d, ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+	if w = request("DELETE", `"2"`, "", ""); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("DELETE with stale ETag: %d, want 412", w.Code)
+	}
+	if w = request("DELETE", "*", "", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE with If-Match *: %d, want 204", w.Code)
+	}
+}
@@ -0,0 +1,356 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"subscription-service/internal/model"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Форматы выдачи списков: json — обычный ответ API, остальные — выгрузки
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+	formatXLSX   = "xlsx"
+)
+
+// exportMediaTypes — типы содержимого из Accept и соответствующие им форматы
+var exportMediaTypes = map[string]string{
+	"application/json":     formatJSON,
+	"text/csv":             formatCSV,
+	"application/x-ndjson": formatNDJSON,
+	"application/ndjson":   formatNDJSON,
+	xlsxType:               formatXLSX,
+}
+
+var exportContentTypes = map[string]string{
+	formatCSV:    "text/csv; charset=utf-8",
+	formatNDJSON: "application/x-ndjson",
+	formatXLSX:   xlsxType,
+}
+
+// exportFlushRows — через сколько строк выгрузка сбрасывается клиенту
+const exportFlushRows = 1000
+
+// exportFormat выбирает формат ответа: параметр format важнее заголовка Accept.
+// Из Accept берётся поддерживаемый тип с наибольшим q; если такого нет — JSON.
+func exportFormat(r *http.Request) (string, error) {
+	if v := r.URL.Query().Get("format"); v != "" {
+		switch v = strings.ToLower(v); v {
+		case formatJSON, formatCSV, formatNDJSON, formatXLSX:
+			return v, nil
+		}
+		return "", invalidField("format", "invalid format, expected json, csv, ndjson or xlsx")
+	}
+
+	format, best := formatJSON, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		f, ok := exportMediaTypes[mt]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > best {
+			format, best = f, q
+		}
+	}
+	return format, nil
+}
+
+// exporter пишет выгрузку построчно. CSV и NDJSON уходят клиенту по мере записи;
+// XLSX собирается потоковым писателем excelize (большие листы он держит во временном
+// файле, а не в памяти) и отправляется целиком в finish.
+// Заголовки ответа пишутся с первой строкой, поэтому ошибку, случившуюся до неё,
+// ещё можно отдать обычным ответом — см. started.
+type exporter struct {
+	w       http.ResponseWriter
+	format  string
+	name    string // имя файла без расширения
+	columns []string
+	rows    int
+	sent    bool // заголовки ответа отправлены
+
+	csv   *csv.Writer
+	json  *json.Encoder
+	book  *excelize.File
+	sheet *excelize.StreamWriter
+}
+
+func newExporter(w http.ResponseWriter, format, name string, columns []string) *exporter {
+	return &exporter{w: w, format: format, name: name, columns: columns}
+}
+
+// started сообщает, ушло ли что-то клиенту: после этого статус ответа уже не изменить
+func (e *exporter) started() bool {
+	return e.sent
+}
+
+// begin снимает WriteTimeout сервера с соединения: выгрузка сотен тысяч строк идёт
+// дольше него, и по таймауту клиент получил бы обрезанный файл
+func (e *exporter) begin() error {
+	if err := http.NewResponseController(e.w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+
+	if e.format == formatXLSX {
+		e.book = excelize.NewFile()
+		sheet, err := e.book.NewStreamWriter(e.book.GetSheetName(0))
+		if err != nil {
+			return err
+		}
+		e.sheet = sheet
+		header := make([]interface{}, len(e.columns))
+		for i, c := range e.columns {
+			header[i] = c
+		}
+		return e.sheet.SetRow("A1", header)
+	}
+
+	e.writeHeaders()
+	switch e.format {
+	case formatCSV:
+		e.csv = csv.NewWriter(e.w)
+		return e.csv.Write(e.columns)
+	default:
+		e.json = json.NewEncoder(e.w)
+		return nil
+	}
+}
+
+func (e *exporter) writeHeaders() {
+	e.w.Header().Set("Content-Type", exportContentTypes[e.format])
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, e.name, e.format))
+	e.w.WriteHeader(http.StatusOK)
+	e.sent = true
+}
+
+// write добавляет строку: v — для NDJSON, cells — значения колонок для CSV и XLSX
+func (e *exporter) write(v interface{}, cells []interface{}) error {
+	if e.rows == 0 {
+		if err := e.begin(); err != nil {
+			return err
+		}
+	}
+	e.rows++
+	if e.format != formatNDJSON {
+		escapeFormulas(cells)
+	}
+
+	switch e.format {
+	case formatXLSX:
+		cell, err := excelize.CoordinatesToCellName(1, e.rows+1)
+		if err != nil {
+			return err
+		}
+		return e.sheet.SetRow(cell, cells)
+	case formatCSV:
+		record := make([]string, len(cells))
+		for i, c := range cells {
+			record[i] = cellString(c)
+		}
+		if err := e.csv.Write(record); err != nil {
+			return err
+		}
+	default:
+		if err := e.json.Encode(v); err != nil {
+			return err
+		}
+	}
+
+	if e.rows%exportFlushRows == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *exporter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := http.NewResponseController(e.w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// finish дописывает выгрузку; пустая выгрузка содержит только заголовок
+func (e *exporter) finish() error {
+	if e.rows == 0 {
+		if err := e.begin(); err != nil {
+			return err
+		}
+	}
+	if e.format != formatXLSX {
+		return e.flush()
+	}
+
+	defer e.book.Close()
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	e.writeHeaders()
+	_, err := e.book.WriteTo(e.w)
+	return err
+}
+
+// escapeFormulas защищает от CSV-инъекции: строку, которую Excel или LibreOffice прочли бы
+// как формулу, предваряет апостроф, и она открывается как текст. Числа не меняются.
+func escapeFormulas(cells []interface{}) {
+	for i, c := range cells {
+		if v, ok := c.(string); ok && v != "" && strings.ContainsRune("=+-@", rune(v[0])) {
+			cells[i] = "'" + v
+		}
+	}
+}
+
+// cellString — значение ячейки для CSV; nil — пустая ячейка
+func cellString(v interface{}) string {
+	switch c := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return c
+	case int:
+		return strconv.Itoa(c)
+	case int64:
+		return strconv.FormatInt(c, 10)
+	default:
+		return fmt.Sprint(c)
+	}
+}
+
+func optString(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+func optDate(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Format("2006-01-02")
+}
+
+// subscriptionExportColumns — колонки выгрузки подписок в порядке полей модели
+var subscriptionExportColumns = []string{
+	"id", "service_id", "service_name", "price", "monthly_cost", "user_id", "start_date", "end_date",
+	"billing_period", "currency", "status", "trial_end", "category", "tags", "version", "updated_at",
+}
+
+func subscriptionCells(sub *model.Subscription) []interface{} {
+	return []interface{}{
+		sub.ID, optString(sub.ServiceID), sub.ServiceName, sub.Price, sub.MonthlyCost, sub.UserID,
+		sub.StartDate.Format("2006-01-02"), optDate(sub.EndDate), string(sub.BillingPeriod), sub.Currency,
+		string(sub.Status), optDate(sub.TrialEnd), optString(sub.Category), strings.Join(sub.Tags, ","),
+		sub.Version, sub.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+var aggregateExportColumns = []string{
+	"number", "service_name", "user_id", "category", "tags", "billing_period", "price", "monthly_cost",
+	"currency", "months", "days", "cost", "converted_cost",
+}
+
+func aggregateCells(info model.SubscriptionInfo) []interface{} {
+	return []interface{}{
+		info.Number, info.ServiceName, info.UserID, optString(info.Category), strings.Join(info.Tags, ","),
+		string(info.BillingPeriod), info.Price, info.MonthlyCost, info.Currency, info.Months, info.Days,
+		info.Cost, info.ConvertedCost,
+	}
+}
+
+// monthServiceRow — строка помесячной выгрузки: вклад сервиса в месяц
+type monthServiceRow struct {
+	Month       string `json:"month"`
+	ServiceName string `json:"service_name"`
+	Total       int64  `json:"total"`
+}
+
+var monthlyExportColumns = []string{"month", "service_name", "total"}
+
+// exportSubscriptions выгружает подписки по фильтру прямо из курсора БД
+func (h *Handler) exportSubscriptions(w http.ResponseWriter, r *http.Request, f model.ListFilter, format string) {
+	e := newExporter(w, format, "subscriptions", subscriptionExportColumns)
+	err := h.svc.ExportSubscriptions(r.Context(), f, func(sub *model.Subscription) error {
+		return e.write(sub, subscriptionCells(sub))
+	})
+	if err == nil {
+		err = e.finish()
+	}
+	if err != nil {
+		if !e.started() {
+			h.writeError(w, r, err, "export failed")
+			return
+		}
+		h.exportError(w, r, e, err)
+	}
+}
+
+// exportAggregate выгружает детали агрегации по подпискам, по строке на подписку
+func (h *Handler) exportAggregate(w http.ResponseWriter, r *http.Request, f model.AggregateFilter, format string) {
+	e := newExporter(w, format, "aggregate", aggregateExportColumns)
+	_, err := h.svc.ExportAggregate(r.Context(), f, func(info model.SubscriptionInfo) error {
+		return e.write(info, aggregateCells(info))
+	})
+	if err == nil {
+		err = e.finish()
+	}
+	if err != nil {
+		if !e.started() {
+			h.writeError(w, r, err, "aggregate failed")
+			return
+		}
+		h.exportError(w, r, e, err)
+	}
+}
+
+// exportMonthly выгружает помесячный ряд: строка на пару «месяц × сервис».
+// Ряд уже свёрнут в БД до месяцев окна, поэтому строится в памяти.
+func (h *Handler) exportMonthly(w http.ResponseWriter, r *http.Request, months []model.MonthCost, format string) {
+	e := newExporter(w, format, "aggregate-monthly", monthlyExportColumns)
+	for _, m := range months {
+		for _, svc := range m.Services {
+			row := monthServiceRow{Month: m.Month, ServiceName: svc.ServiceName, Total: svc.Total}
+			if err := e.write(row, []interface{}{row.Month, row.ServiceName, row.Total}); err != nil {
+				h.exportError(w, r, e, err)
+				return
+			}
+		}
+	}
+	if err := e.finish(); err != nil {
+		h.exportError(w, r, e, err)
+	}
+}
+
+// exportError отвечает ошибкой, если выгрузка ещё не началась; иначе ответ уже
+// частично отправлен, и остаётся только записать ошибку в лог — клиент получит обрыв
+func (h *Handler) exportError(w http.ResponseWriter, r *http.Request, e *exporter, err error) {
+	h.logger(r.Context()).Error().Ctx(r.Context()).Err(err).Str("format", e.format).Int("rows", e.rows).Msg("export failed")
+	if !e.started() {
+		writeProblem(w, r, http.StatusInternalServerError, codeInternal, "internal error")
+	}
+}
@@ -0,0 +1,263 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"subscription-service/internal/metrics"
+	"subscription-service/internal/model"
+	"subscription-service/internal/service"
+
+	"github.com/rs/zerolog"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExportFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		accept  string
+		want    string
+		wantErr bool
+	}{
+		{"default", "", "", formatJSON, false},
+		{"any", "", "*/*", formatJSON, false},
+		{"csv", "", "text/csv", formatCSV, false},
+		{"ndjson alias", "", "application/ndjson", formatNDJSON, false},
+		{"xlsx", "", xlsxType, formatXLSX, false},
+		{"first of equal q wins", "", "text/csv, application/x-ndjson", formatCSV, false},
+		{"highest q wins", "", "text/csv;q=0.5, application/x-ndjson;q=0.9", formatNDJSON, false},
+		{"implicit q is 1", "", "application/json;q=0.8, text/csv", formatCSV, false},
+		{"q=0 is not acceptable", "", "text/csv;q=0", formatJSON, false},
+		{"unknown types skipped", "", "text/html, text/csv;q=0.1", formatCSV, false},
+		{"bad q skipped", "", "text/csv;q=abc, application/x-ndjson;q=0.1", formatNDJSON, false},
+		{"query overrides accept", "format=XLSX", "text/csv", formatXLSX, false},
+		{"invalid query format", "format=pdf", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/subscriptions/export?"+tt.query, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			got, err := exportFormat(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("exportFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("exportFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExporter(t *testing.T) {
+	columns := []string{"name", "price", "note"}
+	rows := []struct {
+		v     map[string]interface{}
+		cells []interface{}
+	}{
+		{map[string]interface{}{"name": "Netflix"}, []interface{}{"Netflix", 400, nil}},
+		{map[string]interface{}{"name": "Spotify"}, []interface{}{"Spotify", int64(200), "a,b"}},
+	}
+	export := func(format string, n int) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		e := newExporter(w, format, "subs", columns)
+		for _, row := range rows[:n] {
+			if err := e.write(row.v, row.cells); err != nil {
+				t.Fatalf("%s: write: %v", format, err)
+			}
+		}
+		if err := e.finish(); err != nil {
+			t.Fatalf("%s: finish: %v", format, err)
+		}
+		if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="subs.`+format+`"` {
+			t.Errorf("%s: Content-Disposition %q", format, cd)
+		}
+		return w
+	}
+
+	table, err := csv.NewReader(export(formatCSV, 2).Body).ReadAll()
+	want := [][]string{columns, {"Netflix", "400", ""}, {"Spotify", "200", "a,b"}}
+	if err != nil || !reflect.DeepEqual(table, want) {
+		t.Errorf("csv = %q, %v; want %q", table, err, want)
+	}
+	if body := export(formatCSV, 0).Body.String(); body != "name,price,note\n" {
+		t.Errorf("empty csv = %q, want header only", body)
+	}
+
+	w := export(formatNDJSON, 2)
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("ndjson Content-Type %q", ct)
+	}
+	var names []string
+	for sc := bufio.NewScanner(w.Body); sc.Scan(); {
+		var v map[string]string
+		if err := json.Unmarshal(sc.Bytes(), &v); err != nil {
+			t.Fatalf("ndjson line %q: %v", sc.Text(), err)
+		}
+		names = append(names, v["name"])
+	}
+	if !reflect.DeepEqual(names, []string{"Netflix", "Spotify"}) {
+		t.Errorf("ndjson names %v", names)
+	}
+
+	book, err := excelize.OpenReader(bytes.NewReader(export(formatXLSX, 2).Body.Bytes()))
+	if err != nil {
+		t.Fatalf("open xlsx: %v", err)
+	}
+	defer book.Close()
+	sheet, err := book.GetRows(book.GetSheetName(0))
+	want = [][]string{columns, {"Netflix", "400"}, {"Spotify", "200", "a,b"}}
+	if err != nil || !reflect.DeepEqual(sheet, want) {
+		t.Errorf("xlsx = %q, %v; want %q", sheet, err, want)
+	}
+}
+
+func TestExporterEscapesFormulas(t *testing.T) {
+	columns := []string{"name", "price", "note"}
+	export := func(format string) []byte {
+		w := httptest.NewRecorder()
+		e := newExporter(w, format, "subs", columns)
+		rows := [][]interface{}{{`=HYPERLINK("http://evil")`, -5, "@SUM(A1)"}, {"+1", 1, "-x"}, {"a=b", 2, ""}}
+		for _, row := range rows {
+			if err := e.write(nil, row); err != nil {
+				t.Fatalf("%s: write: %v", format, err)
+			}
+		}
+		if err := e.finish(); err != nil {
+			t.Fatalf("%s: finish: %v", format, err)
+		}
+		return w.Body.Bytes()
+	}
+	want := [][]string{columns, {`'=HYPERLINK("http://evil")`, "-5", "'@SUM(A1)"}, {"'+1", "1", "'-x"}, {"a=b", "2"}}
+
+	table, err := csv.NewReader(bytes.NewReader(export(formatCSV))).ReadAll()
+	wantCSV := append(want[:3:3], []string{"a=b", "2", ""})
+	if err != nil || !reflect.DeepEqual(table, wantCSV) {
+		t.Errorf("csv = %q, %v; want %q", table, err, wantCSV)
+	}
+
+	book, err := excelize.OpenReader(bytes.NewReader(export(formatXLSX)))
+	if err != nil {
+		t.Fatalf("open xlsx: %v", err)
+	}
+	defer book.Close()
+	sheet, err := book.GetRows(book.GetSheetName(0))
+	if err != nil || !reflect.DeepEqual(sheet, want) {
+		t.Errorf("xlsx = %q, %v; want %q", sheet, err, want)
+	}
+}
+
+func TestExportEndpoints(t *testing.T) {
+	api := newTestAPI(t)
+	api.create(map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025", "tags": []string{"a", "b"}})
+	api.create(map[string]interface{}{"service_name": "Spotify", "price": 200, "user_id": otherUser, "start_date": "02-2025"})
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("GET", path, nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		api.router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s (%s): %d %s", path, accept, w.Code, w.Body)
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("GET %s: Vary %q", path, w.Header().Get("Vary"))
+		}
+		return w
+	}
+	readCSVBody := func(w *httptest.ResponseRecorder) [][]string {
+		t.Helper()
+		table, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("csv: %v", err)
+		}
+		return table
+	}
+
+	// выгрузка не ограничена размером страницы по умолчанию, фильтры работают как в списке
+	table := readCSVBody(get("/subscriptions?format=csv&user_id="+testUser, ""))
+	if len(table) != 2 || !reflect.DeepEqual(table[0], subscriptionExportColumns) || table[1][2] != "Netflix" || table[1][13] != "a,b" {
+		t.Errorf("subscriptions csv = %q", table)
+	}
+
+	w := get("/subscriptions", "application/x-ndjson")
+	if lines := strings.Count(w.Body.String(), "\n"); lines != 2 {
+		t.Errorf("subscriptions ndjson: %d lines, want 2", lines)
+	}
+
+	table = readCSVBody(get("/subscriptions/aggregate?from=01-2025&to=02-2025&format=csv", ""))
+	if len(table) != 3 || !reflect.DeepEqual(table[0], aggregateExportColumns) {
+		t.Errorf("aggregate csv = %q", table)
+	}
+
+	table = readCSVBody(get("/subscriptions/aggregate?mode=monthly&from=01-2025&to=02-2025", "text/csv"))
+	want := [][]string{monthlyExportColumns, {"01-2025", "Netflix", "400"}, {"02-2025", "Netflix", "400"}, {"02-2025", "Spotify", "200"}}
+	if !reflect.DeepEqual(table, want) {
+		t.Errorf("monthly csv = %q, want %q", table, want)
+	}
+
+	// JSON по умолчанию не меняется
+	subs := api.list("/subscriptions")
+	if len(subs) != 2 {
+		t.Errorf("json list: %d subscriptions", len(subs))
+	}
+
+	for _, path := range []string{"/subscriptions?format=pdf", "/subscriptions/aggregate?from=01-2025&to=02-2025&format=csv&group_by=tag"} {
+		if w := api.do("GET", path, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", path, w.Code)
+		}
+	}
+}
+
+// exportService отдаёт ошибку выгрузки до первой строки
+type exportService struct {
+	service.SubscriptionService
+	err error
+}
+
+func (s *exportService) ExportSubscriptions(ctx context.Context, f model.ListFilter, fn func(*model.Subscription) error) error {
+	return s.err
+}
+
+func TestExportErrorBeforeFirstRow(t *testing.T) {
+	log := zerolog.Nop()
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{fmt.Errorf("%w: 00000000-0000-0000-0000-000000000000", service.ErrServiceNotFound), http.StatusNotFound, "service_not_found"},
+		{errors.New("connection reset"), http.StatusInternalServerError, codeInternal},
+	}
+	for _, tt := range tests {
+		router := NewRouter(NewHandler(&exportService{err: tt.err}, &log), NewHealth(), metrics.New(&log), &log)
+		r := httptest.NewRequest("GET", "/subscriptions?format=csv", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		// заголовки выгрузки ещё не отправлены — клиент получает обычную ошибку
+		var p problem
+		decodeBody(t, w, &p)
+		if w.Code != tt.status || p.Code != tt.code || w.Header().Get("Content-Type") != problemContentType {
+			t.Errorf("%v: %d %s %+v, want %d %s", tt.err, w.Code, w.Header().Get("Content-Type"), p, tt.status, tt.code)
+		}
+		if cd := w.Header().Get("Content-Disposition"); cd != "" {
+			t.Errorf("%v: Content-Disposition %q on error", tt.err, cd)
+		}
+	}
+}
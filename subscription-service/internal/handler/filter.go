@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"subscription-service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// parseListFilter разбирает фильтры и сортировку списка подписок из параметров запроса.
+// Неверное значение — ошибка, а не пропущенный фильтр.
+func parseListFilter(q url.Values) (model.ListFilter, error) {
+	f := model.ListFilter{
+		ServiceID:   q.Get("service_id"),
+		ServiceName: q.Get("service_name"),
+		Category:    strings.TrimSpace(q.Get("category")),
+		Tags:        normalizeTags(q["tag"]),
+	}
+
+	// user_id можно повторять или перечислять через запятую
+	for _, v := range q["user_id"] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id == "" {
+				continue
+			}
+			if _, err := uuid.Parse(id); err != nil {
+				return f, invalidField("user_id", "user_id must be a valid UUID")
+			}
+			f.UserIDs = append(f.UserIDs, id)
+		}
+	}
+	if f.ServiceID != "" {
+		if _, err := uuid.Parse(f.ServiceID); err != nil {
+			return f, invalidField("service_id", "service_id must be a valid UUID")
+		}
+	}
+
+	var err error
+	if f.TrialEndsWithin, err = queryInt(q, "trial_ends_within"); err != nil || (f.TrialEndsWithin != nil && *f.TrialEndsWithin < 0) {
+		return f, invalidField("trial_ends_within", "trial_ends_within must be a non-negative number of days")
+	}
+	if f.PriceMin, err = queryInt(q, "price_min"); err != nil || (f.PriceMin != nil && *f.PriceMin < 0) {
+		return f, invalidField("price_min", "price_min must be a non-negative integer")
+	}
+	if f.PriceMax, err = queryInt(q, "price_max"); err != nil || (f.PriceMax != nil && *f.PriceMax < 0) {
+		return f, invalidField("price_max", "price_max must be a non-negative integer")
+	}
+	if f.PriceMin != nil && f.PriceMax != nil && *f.PriceMin > *f.PriceMax {
+		return f, invalidField("price_min", "price_min must be less than or equal to price_max")
+	}
+
+	// нижние границы — первый день месяца MM-YYYY, верхние — последний
+	dates := []struct {
+		name string
+		dst  **time.Time
+		end  bool
+	}{
+		{"start_from", &f.StartFrom, false},
+		{"start_to", &f.StartTo, true},
+		{"end_from", &f.EndFrom, false},
+		{"end_to", &f.EndTo, true},
+		{"active_at", &f.ActiveAt, false},
+	}
+	for _, d := range dates {
+		if *d.dst, err = queryDate(q, d.name, d.end); err != nil {
+			return f, err
+		}
+	}
+	if f.StartFrom != nil && f.StartTo != nil && f.StartFrom.After(*f.StartTo) {
+		return f, invalidField("start_from", "start_from must be less than or equal to start_to")
+	}
+	if f.EndFrom != nil && f.EndTo != nil && f.EndFrom.After(*f.EndTo) {
+		return f, invalidField("end_from", "end_from must be less than or equal to end_to")
+	}
+
+	if v := q.Get("no_end_date"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, invalidField("no_end_date", "no_end_date must be true or false")
+		}
+		f.NoEndDate = &b
+	}
+
+	if v := q.Get("sort"); v != "" {
+		if f.Sort, err = model.ParseSort(v); err != nil {
+			return f, invalidField("sort", err.Error())
+		}
+	}
+	return f, nil
+}
+
+// queryInt читает необязательный целый параметр; nil — параметр не задан
+func queryInt(q url.Values, name string) (*int, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// queryDate читает необязательную дату в форматах dateFormats; end — MM-YYYY означает конец месяца
+func queryDate(q url.Values, name string, end bool) (*time.Time, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	var t time.Time
+	var err error
+	if end {
+		t, err = parseEndDate(v)
+	} else {
+		t, _, err = parseDate(v)
+	}
+	if err != nil {
+		return nil, invalidField(name, fmt.Sprintf("invalid %s format, expected %s", name, dateFormats))
+	}
+	return &t, nil
+}
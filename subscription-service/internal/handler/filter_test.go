@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"subscription-service/internal/model"
+)
+
+func TestParseListFilter(t *testing.T) {
+	q, _ := url.ParseQuery("user_id=" + testUser + "," + otherUser + "&user_id=" + testUser +
+		"&price_min=100&price_max=500&start_from=01-2025&start_to=02-2025&active_at=2025-03-15&no_end_date=false&sort=-price,service_name")
+	f, err := parseListFilter(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{testUser, otherUser, testUser}; !reflect.DeepEqual(f.UserIDs, want) {
+		t.Errorf("UserIDs = %v, want %v", f.UserIDs, want)
+	}
+	if *f.PriceMin != 100 || *f.PriceMax != 500 || *f.NoEndDate {
+		t.Errorf("price %d..%d, no_end_date %v", *f.PriceMin, *f.PriceMax, *f.NoEndDate)
+	}
+	// верхняя граница MM-YYYY — последний день месяца
+	if !f.StartFrom.Equal(date(2025, 1, 1)) || !f.StartTo.Equal(date(2025, 2, 28)) || !f.ActiveAt.Equal(date(2025, 3, 15)) {
+		t.Errorf("dates %v %v %v", f.StartFrom, f.StartTo, f.ActiveAt)
+	}
+	if want := []model.SortField{{Field: "price", Desc: true}, {Field: "service_name"}}; !reflect.DeepEqual(f.Sort, want) {
+		t.Errorf("Sort = %+v, want %+v", f.Sort, want)
+	}
+
+	for _, query := range []string{
+		"user_id=bob",
+		"service_id=1",
+		"price_min=-1",
+		"price_max=ten",
+		"price_min=500&price_max=100",
+		"start_from=03-2025&start_to=02-2025",
+		"end_from=2025-02-01&end_to=2025-01-31",
+		"active_at=yesterday",
+		"no_end_date=maybe",
+		"trial_ends_within=-3",
+		"sort=monthly_cost",
+	} {
+		q, _ := url.ParseQuery(query)
+		if _, err := parseListFilter(q); err == nil {
+			t.Errorf("%s: want error", query)
+		}
+	}
+}
+
+func TestListFiltersAndSort(t *testing.T) {
+	api := newTestAPI(t)
+	api.create(map[string]interface{}{"service_name": "Netflix Premium", "price": 900, "user_id": testUser, "start_date": "01-2025", "end_date": "03-2025"})
+	api.create(map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": otherUser, "start_date": "02-2025"})
+	api.create(map[string]interface{}{"service_name": "Spotify", "price": 200, "user_id": testUser, "start_date": "05-2025"})
+	api.create(map[string]interface{}{"service_name": "Dropbox", "price": 400, "user_id": "10601fee-2bf1-4721-ae6f-7636e79a0cba", "start_date": "01-2025"})
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"sort=service_name", []string{"Dropbox", "Netflix", "Netflix Premium", "Spotify"}},
+		{"sort=-price,service_name", []string{"Netflix Premium", "Dropbox", "Netflix", "Spotify"}},
+		{"user_id=" + testUser + "," + otherUser + "&sort=service_name", []string{"Netflix", "Netflix Premium", "Spotify"}},
+		{"service_name=netflix&sort=service_name", []string{"Netflix", "Netflix Premium"}},
+		{"price_min=300&price_max=500&sort=service_name", []string{"Dropbox", "Netflix"}},
+		{"start_from=02-2025&start_to=05-2025&sort=start_date", []string{"Netflix", "Spotify"}},
+		{"active_at=2025-04-01&sort=service_name", []string{"Dropbox", "Netflix"}},
+		{"no_end_date=false", []string{"Netflix Premium"}},
+		{"end_to=03-2025", []string{"Netflix Premium"}},
+	}
+	for _, tt := range tests {
+		var names []string
+		for _, sub := range api.list("/subscriptions?" + tt.query) {
+			names = append(names, sub.ServiceName)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.query, names, tt.want)
+		}
+	}
+
+	var page model.SubscriptionPage
+	api.get("/subscriptions?price_min=300&limit=1", &page)
+	if page.Total != 3 || len(page.Items) != 1 {
+		t.Errorf("total with filter = %d, items %d; want 3, 1", page.Total, len(page.Items))
+	}
+	if w := api.do("GET", "/subscriptions?sort=price;drop", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("bad sort: status %d, want 400", w.Code)
+	}
+}
+
+// % и _ в service_name ищутся как обычные символы, а не как шаблон ILIKE
+func TestServiceNameFilterIsLiteral(t *testing.T) {
+	api := newTestAPI(t)
+	api.create(map[string]interface{}{"service_name": "Cloud 100%", "price": 100, "user_id": testUser, "start_date": "01-2025"})
+	api.create(map[string]interface{}{"service_name": "Cloud 1000", "price": 200, "user_id": testUser, "start_date": "01-2025"})
+	api.create(map[string]interface{}{"service_name": "my_tv", "price": 300, "user_id": testUser, "start_date": "01-2025"})
+	api.create(map[string]interface{}{"service_name": "myXtv", "price": 400, "user_id": testUser, "start_date": "01-2025"})
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"100%25", []string{"Cloud 100%"}},
+		{"y_t", []string{"my_tv"}},
+		{"%25", []string{"Cloud 100%"}},
+	}
+	for _, tt := range tests {
+		var names []string
+		for _, sub := range api.list("/subscriptions?sort=service_name&service_name=" + tt.name) {
+			names = append(names, sub.ServiceName)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("list service_name=%s: got %v, want %v", tt.name, names, tt.want)
+		}
+
+		var got model.AggregateResponse
+		api.get("/subscriptions/aggregate?from=01-2025&to=01-2025&service_name="+tt.name, &got)
+		names = nil
+		for _, sub := range got.Subscriptions {
+			names = append(names, sub.ServiceName)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("aggregate service_name=%s: got %v, want %v", tt.name, names, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+// healthCheckTimeout — сколько ждать одну зависимость: зависшая БД должна давать
+// отказ в готовности, а не зависший /readyz
+const healthCheckTimeout = 2 * time.Second
+
+// Health отвечает на проверки живости и готовности экземпляра
+type Health struct {
+	checks   []healthCheck
+	draining atomic.Bool
+}
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func NewHealth() *Health {
+	return &Health{}
+}
+
+// Add регистрирует зависимость, без которой экземпляр не готов принимать трафик
+func (hc *Health) Add(name string, check func(ctx context.Context) error) {
+	hc.checks = append(hc.checks, healthCheck{name: name, check: check})
+}
+
+// Drain переводит экземпляр в режим остановки: /readyz отвечает отказом, чтобы
+// балансировщик перестал присылать новые запросы, пока дорабатывают текущие
+func (hc *Health) Drain() {
+	hc.draining.Store(true)
+}
+
+// Live — проверка живости: процесс запущен и обслуживает HTTP. Зависимости не проверяются,
+// чтобы недоступная БД не приводила к перезапуску всех экземпляров.
+func (hc *Health) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, model.HealthResponse{Status: model.HealthOK})
+}
+
+// Ready — проверка готовности: все зависимости проверяются параллельно, при отказе
+// хотя бы одной или во время остановки ответ 503
+func (hc *Health) Ready(w http.ResponseWriter, r *http.Request) {
+	resp := model.HealthResponse{Status: model.HealthOK, Checks: make(map[string]model.HealthCheck, len(hc.checks)+1)}
+
+	shutdown := model.HealthCheck{Status: model.HealthOK}
+	if hc.draining.Load() {
+		shutdown = model.HealthCheck{Status: model.HealthFail, Error: "shutting down"}
+	}
+	resp.Checks["shutdown"] = shutdown
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range hc.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := runCheck(r.Context(), c.check)
+			mu.Lock()
+			resp.Checks[c.name] = res
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	for _, c := range resp.Checks {
+		if c.Status != model.HealthOK {
+			resp.Status = model.HealthFail
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func runCheck(ctx context.Context, check func(ctx context.Context) error) model.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	res := model.HealthCheck{
+		Status:    model.HealthOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status = model.HealthFail
+		res.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			res.Error = "timed out after " + healthCheckTimeout.String()
+		}
+	}
+	return res
+}
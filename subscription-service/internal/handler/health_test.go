@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"subscription-service/internal/metrics"
+	"subscription-service/internal/model"
+
+	"github.com/rs/zerolog"
+)
+
+func readyz(t *testing.T, hc *Health, ctx context.Context) (int, model.HealthResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	hc.Ready(w, httptest.NewRequest("GET", "/readyz", nil).WithContext(ctx))
+	var resp model.HealthResponse
+	decodeBody(t, w, &resp)
+	return w.Code, resp
+}
+
+func TestHealthReady(t *testing.T) {
+	hc := NewHealth()
+	hc.Add("database", func(ctx context.Context) error { return nil })
+	code, resp := readyz(t, hc, context.Background())
+	if code != http.StatusOK || resp.Status != model.HealthOK || len(resp.Checks) != 2 {
+		t.Fatalf("all checks ok: %d %+v", code, resp)
+	}
+
+	hc.Add("migrations", func(ctx context.Context) error { return errors.New("2 pending migrations") })
+	code, resp = readyz(t, hc, context.Background())
+	if code != http.StatusServiceUnavailable || resp.Status != model.HealthFail {
+		t.Errorf("failed check: %d %+v", code, resp)
+	}
+	if c := resp.Checks["migrations"]; c.Status != model.HealthFail || c.Error != "2 pending migrations" {
+		t.Errorf("migrations check %+v", c)
+	}
+	if c := resp.Checks["database"]; c.Status != model.HealthOK {
+		t.Errorf("database check %+v, want ok", c)
+	}
+}
+
+func TestHealthReadyTimeout(t *testing.T) {
+	hc := NewHealth()
+	hc.Add("database", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	code, resp := readyz(t, hc, ctx)
+	if c := resp.Checks["database"]; code != http.StatusServiceUnavailable || c.Error != "timed out after "+healthCheckTimeout.String() {
+		t.Errorf("hung check: %d %+v", code, c)
+	}
+}
+
+func TestHealthDrain(t *testing.T) {
+	hc := NewHealth()
+	log := zerolog.Nop()
+	router := NewRouter(NewHandler(nil, &log), hc, metrics.New(&log), &log)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("/readyz before drain: %d", w.Code)
+	}
+
+	hc.Drain()
+	code, resp := readyz(t, hc, context.Background())
+	if code != http.StatusServiceUnavailable || resp.Checks["shutdown"].Error != "shutting down" {
+		t.Errorf("/readyz while draining: %d %+v", code, resp)
+	}
+	// живость от остановки не зависит
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	var live model.HealthResponse
+	decodeBody(t, w, &live)
+	if w.Code != http.StatusOK || live.Status != model.HealthOK || live.Checks != nil {
+		t.Errorf("/healthz while draining: %d %+v", w.Code, live)
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"subscription-service/internal/model"
+)
+
+// maxIdempotencyKeyLen — ограничение длины Idempotency-Key
+const maxIdempotencyKeyLen = 255
+
+// replayedHeaders — заголовки ответа, которые сохраняются для повтора
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// idempotent выполняет запрос с заголовком Idempotency-Key не больше одного раза: повтор
+// с тем же ключом и телом получает сохранённый ответ, с другим телом — 409.
+// Запросы без заголовка выполняются как обычно.
+func (h *Handler) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			writeInvalid(w, r, invalidField("Idempotency-Key", "Idempotency-Key is too long"))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "failed to read body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec, err := h.svc.BeginIdempotent(r.Context(), r.Method+" "+r.URL.Path, key, body)
+		if err != nil {
+			h.writeError(w, r, err, "idempotency key claim failed")
+			return
+		}
+
+		if rec.StatusCode != nil {
+			for name, value := range rec.Headers {
+				w.Header().Set(name, value)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(*rec.StatusCode)
+			_, _ = w.Write(rec.Body)
+			return
+		}
+
+		rw := &recordingWriter{ResponseWriter: w}
+		next(rw, r)
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		rec.StatusCode = &status
+		rec.Body = rw.body.Bytes()
+		rec.Headers = model.ResponseHeaders{}
+		for _, name := range replayedHeaders {
+			if v := w.Header().Get(name); v != "" {
+				rec.Headers[name] = v
+			}
+		}
+
+		// ответ уже отправлен: сохраняем его, даже если клиент успел отключиться
+		if err := h.svc.FinishIdempotent(context.WithoutCancel(r.Context()), rec); err != nil {
+			h.logger(r.Context()).Error().Ctx(r.Context()).Err(err).Msg("idempotent response save failed")
+		}
+	}
+}
+
+// recordingWriter пропускает ответ клиенту и запоминает его статус и тело
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
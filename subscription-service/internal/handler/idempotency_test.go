@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"subscription-service/internal/service"
+)
+
+func TestIdempotentCreate(t *testing.T) {
+	api := newTestAPI(t)
+	post := func(key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/subscriptions", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		api.router.ServeHTTP(w, r)
+		return w
+	}
+	body := `{"service_name":"Netflix","price":400,"user_id":"` + testUser + `","start_date":"01-2025"}`
+
+	first := post("key-1", body)
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first request: %d, replayed %q", first.Code, first.Header().Get("Idempotent-Replayed"))
+	}
+	second := post("key-1", body)
+	if second.Code != http.StatusCreated || second.Header().Get("Idempotent-Replayed") != "true" ||
+		second.Body.String() != first.Body.String() || second.Header().Get("Location") != first.Header().Get("Location") {
+		t.Errorf("replay: %d %s, headers %v; want copy of %s", second.Code, second.Body, second.Header(), first.Body)
+	}
+
+	if w := post("key-1", strings.Replace(body, "400", "500", 1)); w.Code != http.StatusConflict {
+		t.Errorf("same key, different body: status %d, want 409", w.Code)
+	}
+	if w := post(strings.Repeat("k", 256), body); w.Code != http.StatusBadRequest {
+		t.Errorf("too long key: status %d, want 400", w.Code)
+	}
+	// ошибка валидации сохраняется так же, как успешный ответ
+	if w := post("key-2", `{"price":-1}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid body: status %d, want 400", w.Code)
+	}
+	if w := post("key-2", `{"price":-1}`); w.Code != http.StatusBadRequest || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replayed invalid body: status %d, replayed %q", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+
+	subs := api.list("/subscriptions")
+	if len(subs) != 1 {
+		t.Errorf("%d subscriptions created, want 1", len(subs))
+	}
+}
+
+// брошенный запрос (процесс упал, не сохранив ответ) отдаёт ключ повтору только
+// по истечении окна IdempotencyStaleAfter
+func TestIdempotencyStaleAfter(t *testing.T) {
+	body := `{"service_name":"Netflix","price":400,"user_id":"` + testUser + `","start_date":"01-2025"}`
+	tests := []struct {
+		name   string
+		opts   []service.Option
+		status int
+	}{
+		{"default window", nil, http.StatusCreated},
+		{"longer window", []service.Option{service.WithIdempotencyStaleAfter(time.Hour)}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		api := newTestAPI(t, tt.opts...)
+		api.exec(`INSERT INTO idempotency_keys (scope, key, request_hash, created_at, expires_at)
+			VALUES ('POST /subscriptions', 'abandoned', 'other', now() - interval '10 minutes', now() + interval '1 day')`)
+
+		r := httptest.NewRequest("POST", "/subscriptions", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Idempotency-Key", "abandoned")
+		w := httptest.NewRecorder()
+		api.router.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"subscription-service/internal/model"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// maxImportSize — ограничение размера файла импорта
+const maxImportSize = 32 << 20
+
+const (
+	csvType  = "text/csv"
+	xlsxType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// importFields — поля подписки, которые можно загрузить из файла; по умолчанию колонка
+// называется так же, как поле (без учёта регистра)
+var importFields = []string{
+	"service_id", "service_name", "price", "user_id", "start_date", "end_date", "billing_period",
+	"currency", "status", "trial_end", "trial_days", "category", "tags",
+}
+
+// dateFields — поля с датами: в XLSX они могут прийти числом (серийная дата Excel)
+var dateFields = map[string]bool{"start_date": true, "end_date": true, "trial_end": true}
+
+// ImportSubscriptions загружает подписки из CSV или XLSX. Файл передаётся телом запроса
+// (Content-Type text/csv или XLSX) или полем file формы multipart/form-data.
+// Параметры: dry_run=true — только проверить; mapping — JSON {"поле": "колонка"}
+// для файлов с другими названиями колонок; sheet — лист XLSX (по умолчанию первый).
+// Каждая строка проверяется так же, как при POST /subscriptions; при ошибках ничего не пишется.
+func (h *Handler) ImportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	dryRun := false
+	if v := q.Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeInvalid(w, r, invalidField("dry_run", "dry_run must be true or false"))
+			return
+		}
+		dryRun = b
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	data, format, mappingParam, err := readImportFile(r)
+	if err != nil {
+		writeInvalid(w, r, err)
+		return
+	}
+	if mappingParam == "" {
+		mappingParam = q.Get("mapping")
+	}
+	mapping := map[string]string{}
+	if mappingParam != "" {
+		if err := json.Unmarshal([]byte(mappingParam), &mapping); err != nil {
+			writeInvalid(w, r, invalidField("mapping", "mapping must be a JSON object {\"field\": \"column\"}"))
+			return
+		}
+		for field := range mapping {
+			if !isImportField(field) {
+				writeInvalid(w, r, invalidField("mapping", fmt.Sprintf("mapping: unknown field %q", field)))
+				return
+			}
+		}
+	}
+
+	var table [][]string
+	switch format {
+	case csvType:
+		table, err = readCSV(data)
+	case xlsxType:
+		table, err = readXLSX(data, q.Get("sheet"))
+	}
+	if err != nil {
+		writeInvalid(w, r, err)
+		return
+	}
+	if len(table) == 0 {
+		writeInvalid(w, r, invalidField("file", "file is empty, header row is required"))
+		return
+	}
+
+	columns, err := mapColumns(table[0], mapping)
+	if err != nil {
+		writeInvalid(w, r, err)
+		return
+	}
+
+	report := model.ImportReport{DryRun: dryRun, Errors: []model.ImportError{}}
+	rows := make([]model.ImportRow, 0, len(table)-1)
+	for i, record := range table[1:] {
+		line := i + 2
+		if isBlank(record) {
+			continue
+		}
+		report.Rows++
+
+		in, err := importInput(record, columns, format == xlsxType)
+		if err == nil {
+			var sub *model.Subscription
+			if sub, err = in.toModel(); err == nil {
+				rows = append(rows, model.ImportRow{Line: line, Subscription: sub})
+				continue
+			}
+		}
+		var colErr *importColumnError
+		if errors.As(err, &colErr) {
+			report.Errors = append(report.Errors, model.ImportError{Line: line, Column: colErr.column, Error: colErr.err.Error()})
+			continue
+		}
+		var fields validationErrors
+		if errors.As(err, &fields) {
+			for _, fe := range fields {
+				report.Errors = append(report.Errors, model.ImportError{Line: line, Column: fe.Field, Error: fe.Message})
+			}
+			continue
+		}
+		report.Errors = append(report.Errors, model.ImportError{Line: line, Error: err.Error()})
+	}
+
+	imported, rowErrors, err := h.svc.Import(r.Context(), rows, dryRun || len(report.Errors) > 0)
+	if err != nil {
+		h.writeError(w, r, err, "import subscriptions failed")
+		return
+	}
+	report.Imported = imported
+	report.Errors = mergeImportErrors(report.Errors, rowErrors)
+
+	status := http.StatusOK
+	switch {
+	case len(report.Errors) > 0 && !dryRun:
+		status = http.StatusUnprocessableEntity
+	case !dryRun:
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// readImportFile читает файл из тела запроса или из поля file формы и определяет его формат.
+// mapping из формы возвращается отдельно: в multipart его удобнее передать полем.
+func readImportFile(r *http.Request) (data []byte, format, mapping string, err error) {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mt != "multipart/form-data" {
+		format, err = importFormat(mt, "")
+		if err != nil {
+			return nil, "", "", err
+		}
+		data, err = io.ReadAll(r.Body)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("failed to read body: %w", err)
+		}
+		return data, format, "", nil
+	}
+
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		return nil, "", "", fmt.Errorf("invalid multipart form: %w", err)
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, "", "", errors.New("file field is required")
+	}
+	defer file.Close()
+
+	partType, _, _ := mime.ParseMediaType(header.Header.Get("Content-Type"))
+	format, err = importFormat(partType, header.Filename)
+	if err != nil {
+		return nil, "", "", err
+	}
+	data, err = io.ReadAll(file)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return data, format, r.FormValue("mapping"), nil
+}
+
+// importFormat определяет формат файла по типу содержимого, а если он неинформативен — по расширению
+func importFormat(contentType, filename string) (string, error) {
+	switch contentType {
+	case csvType, "application/csv":
+		return csvType, nil
+	case xlsxType:
+		return xlsxType, nil
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return csvType, nil
+	case ".xlsx":
+		return xlsxType, nil
+	}
+	return "", errors.New("unsupported file type, expected CSV (text/csv) or XLSX")
+}
+
+// readCSV разбирает CSV; разделитель — запятая или точка с запятой (как сохраняет Excel
+// в русской локали), выбирается по строке заголовка
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // BOM
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	table, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %w", err)
+	}
+	return table, nil
+}
+
+// readXLSX читает лист XLSX как таблицу строк; даты остаются серийными числами Excel
+func readXLSX(data []byte, sheet string) ([][]string, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data), excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("invalid xlsx: %w", err)
+	}
+	defer f.Close()
+
+	if sheet == "" {
+		sheet = f.GetSheetName(0)
+	}
+	table, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("invalid xlsx sheet %q: %w", sheet, err)
+	}
+	return table, nil
+}
+
+// mapColumns сопоставляет полям подписки номера колонок по заголовку файла
+func mapColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	columns := make(map[string]int)
+	for _, field := range importFields {
+		name, mapped := mapping[field]
+		if !mapped {
+			name = field
+		}
+		i, ok := index[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			if mapped {
+				return nil, fmt.Errorf("mapping: column %q for %s not found in header", name, field)
+			}
+			continue
+		}
+		columns[field] = i
+	}
+
+	if _, ok := columns["user_id"]; !ok {
+		return nil, errors.New("column user_id is required")
+	}
+	if _, ok := columns["start_date"]; !ok {
+		return nil, errors.New("column start_date is required")
+	}
+	_, hasName := columns["service_name"]
+	_, hasID := columns["service_id"]
+	if !hasName && !hasID {
+		return nil, errors.New("column service_name or service_id is required")
+	}
+	return columns, nil
+}
+
+// importColumnError — ошибка разбора значения в конкретной колонке
+type importColumnError struct {
+	column string
+	err    error
+}
+
+func (e *importColumnError) Error() string {
+	return e.column + ": " + e.err.Error()
+}
+
+// importInput собирает из строки файла то же тело, что принимает POST /subscriptions
+func importInput(record []string, columns map[string]int, excelDates bool) (subscriptionInput, error) {
+	value := func(field string) (string, bool) {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return "", false
+		}
+		v := strings.TrimSpace(record[i])
+		if v != "" && excelDates && dateFields[field] {
+			v = excelDate(v)
+		}
+		return v, v != ""
+	}
+
+	in := subscriptionInput{}
+	in.ServiceName, _ = value("service_name")
+	in.UserID, _ = value("user_id")
+	in.StartDate, _ = value("start_date")
+	in.BillingPeriod, _ = value("billing_period")
+	in.Currency, _ = value("currency")
+	in.Status, _ = value("status")
+	if v, ok := value("service_id"); ok {
+		in.ServiceID = &v
+	}
+	if v, ok := value("end_date"); ok {
+		in.EndDate = &v
+	}
+	if v, ok := value("trial_end"); ok {
+		in.TrialEnd = &v
+	}
+	if v, ok := value("category"); ok {
+		in.Category = &v
+	}
+	if v, ok := value("tags"); ok {
+		in.Tags = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ';' || r == '|' })
+	}
+	if v, ok := value("price"); ok {
+		price, err := strconv.Atoi(v)
+		if err != nil {
+			return in, &importColumnError{column: "price", err: errors.New("must be an integer")}
+		}
+		in.Price = price
+	}
+	if v, ok := value("trial_days"); ok {
+		days, err := strconv.Atoi(v)
+		if err != nil {
+			return in, &importColumnError{column: "trial_days", err: errors.New("must be an integer")}
+		}
+		in.TrialDays = &days
+	}
+	return in, nil
+}
+
+// excelDate переводит серийную дату Excel в YYYY-MM-DD; другие значения возвращает как есть
+func excelDate(v string) string {
+	serial, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	t, err := excelize.ExcelDateToTime(serial, false)
+	if err != nil {
+		return v
+	}
+	return t.Format("2006-01-02")
+}
+
+func isImportField(field string) bool {
+	for _, f := range importFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeImportErrors объединяет ошибки разбора и ошибки сервиса в порядке строк файла
+func mergeImportErrors(a, b []model.ImportError) []model.ImportError {
+	out := make([]model.ImportError, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if j >= len(b) || (i < len(a) && a[i].Line <= b[j].Line) {
+			out = append(out, a[i])
+			i++
+		} else {
+			out = append(out, b[j])
+			j++
+		}
+	}
+	return out
+}
@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+
+	"subscription-service/internal/model"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want [][]string
+	}{
+		{"comma", "service_name,price\nNetflix,400\n", [][]string{{"service_name", "price"}, {"Netflix", "400"}}},
+		// Excel в русской локали сохраняет с точкой с запятой и BOM
+		{"semicolon with BOM", "\xef\xbb\xbfservice_name;price;tags\nNetflix; 400;\"a,b\"\n", [][]string{{"service_name", "price", "tags"}, {"Netflix", "400", "a,b"}}},
+		{"ragged rows", "a,b\n1\n", [][]string{{"a", "b"}, {"1"}}},
+	}
+	for _, tt := range tests {
+		got, err := readCSV([]byte(tt.data))
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: readCSV = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := readCSV([]byte("a,b\n\"unterminated\n")); err == nil {
+		t.Error("broken quotes: want error")
+	}
+}
+
+func TestMapColumns(t *testing.T) {
+	header := []string{" Service ", "USER_ID", "Start_Date", "Стоимость"}
+	got, err := mapColumns(header, map[string]string{"service_name": "service", "price": "стоимость"})
+	want := map[string]int{"service_name": 0, "user_id": 1, "start_date": 2, "price": 3}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("mapColumns = %v, %v; want %v", got, err, want)
+	}
+
+	for name, tt := range map[string]struct {
+		header  []string
+		mapping map[string]string
+	}{
+		"no user_id":         {[]string{"service_name", "start_date"}, nil},
+		"no start_date":      {[]string{"service_name", "user_id"}, nil},
+		"no service":         {[]string{"user_id", "start_date"}, nil},
+		"mapped column lost": {[]string{"service_name", "user_id", "start_date"}, map[string]string{"price": "cost"}},
+	} {
+		if _, err := mapColumns(tt.header, tt.mapping); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+func TestExcelDate(t *testing.T) {
+	for in, want := range map[string]string{
+		"45658":      "2025-01-01",
+		"45658.5":    "2025-01-01",
+		"01-2025":    "01-2025",
+		"2025-01-01": "2025-01-01",
+	} {
+		if got := excelDate(in); got != want {
+			t.Errorf("excelDate(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMergeImportErrors(t *testing.T) {
+	a := []model.ImportError{{Line: 2}, {Line: 5}}
+	b := []model.ImportError{{Line: 3}, {Line: 5, Column: "service_id"}, {Line: 7}}
+	var lines []int
+	for _, e := range mergeImportErrors(a, b) {
+		lines = append(lines, e.Line)
+	}
+	if want := []int{2, 3, 5, 5, 7}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("merged lines %v, want %v", lines, want)
+	}
+}
+
+const importCSV = "service_name;price;user_id;start_date;end_date;billing_period;tags\n" +
+	"Netflix;400;" + testUser + ";01-2025;;;a|b\n" +
+	";;;;;;\n" +
+	"Spotify;1200;" + testUser + ";2025-01-15;12-2025;yearly;\n"
+
+func TestImportCSV(t *testing.T) {
+	api := newTestAPI(t)
+	importReport := func(path, body string, code int) model.ImportReport {
+		t.Helper()
+		w := api.send("POST", path, "text/csv; charset=utf-8", body)
+		if w.Code != code {
+			t.Fatalf("POST %s: %d %s, want %d", path, w.Code, w.Body, code)
+		}
+		var report model.ImportReport
+		decodeBody(t, w, &report)
+		return report
+	}
+	count := func() int {
+		t.Helper()
+		subs := api.list("/subscriptions")
+		return len(subs)
+	}
+
+	if r := importReport("/subscriptions/import?dry_run=true", importCSV, http.StatusOK); !r.DryRun || r.Rows != 2 || r.Imported != 0 || len(r.Errors) != 0 {
+		t.Errorf("dry run: %+v", r)
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("dry run wrote %d subscriptions", n)
+	}
+
+	// ошибка в одной строке: не пишется ничего
+	broken := importCSV + "Yandex;many;" + testUser + ";01-2025;;;\nKinopoisk;100;" + testUser + ";2025-13-01;;;\n"
+	r := importReport("/subscriptions/import", broken, http.StatusUnprocessableEntity)
+	if r.Imported != 0 || len(r.Errors) != 2 || r.Errors[0].Line != 5 || r.Errors[0].Column != "price" || r.Errors[1].Line != 6 {
+		t.Errorf("broken file: %+v", r)
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("failed import wrote %d subscriptions", n)
+	}
+
+	if r := importReport("/subscriptions/import", importCSV, http.StatusCreated); r.Imported != 2 {
+		t.Errorf("import: %+v", r)
+	}
+	// импортированные подписки видны агрегации вместе с историей цен
+	var got model.AggregateResponse
+	api.get("/subscriptions/aggregate?from=01-2025&to=03-2025", &got)
+	if got.Total != 3*400+1200 {
+		t.Errorf("aggregate after import: total %d, want 2400", got.Total)
+	}
+
+	mapped := "Сервис,Цена,Пользователь,Начало\nDropbox,300," + testUser + ",01-2025\n"
+	path := `/subscriptions/import?mapping={"service_name":"Сервис","price":"Цена","user_id":"Пользователь","start_date":"Начало"}`
+	if r := importReport(strings.ReplaceAll(path, `"`, "%22"), mapped, http.StatusCreated); r.Imported != 1 {
+		t.Errorf("import with mapping: %+v", r)
+	}
+	if n := count(); n != 3 {
+		t.Errorf("%d subscriptions after imports, want 3", n)
+	}
+
+	for _, bad := range []struct{ contentType, path string }{
+		{"application/pdf", "/subscriptions/import"},
+		{"text/csv", "/subscriptions/import?dry_run=maybe"},
+		{"text/csv", `/subscriptions/import?mapping={"cost":"price"}`},
+	} {
+		if w := api.send("POST", strings.ReplaceAll(bad.path, `"`, "%22"), bad.contentType, importCSV); w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: status %d, want 400", bad.contentType, bad.path, w.Code)
+		}
+	}
+}
+
+func TestImportXLSXMultipart(t *testing.T) {
+	api := newTestAPI(t)
+
+	file := xlsxFile(t, xlsxRows)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="file"; filename="subs.xlsx"`},
+		"Content-Type":        {"application/octet-stream"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(file)
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/subscriptions/import", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("xlsx import: %d %s", w.Code, w.Body)
+	}
+
+	subs := api.list("/subscriptions")
+	if len(subs) != 1 || subs[0].StartDate.Format("2006-01-02") != "2025-01-01" || subs[0].Price != 400 {
+		t.Errorf("imported from xlsx: %+v", subs)
+	}
+}
+
+// xlsxRows — лист XLSX, в котором дата начала записана серийным числом Excel (2025-01-01)
+var xlsxRows = [][]interface{}{
+	{"service_name", "price", "user_id", "start_date"},
+	{"Netflix", 400, testUser, 45658},
+}
+
+func xlsxFile(t *testing.T, rows [][]interface{}) []byte {
+	t.Helper()
+	f := excelize.NewFile()
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	table, err := readXLSX(xlsxFile(t, xlsxRows), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns, err := mapColumns(table[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := importInput(table[1], columns, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.ServiceName != "Netflix" || in.Price != 400 || in.UserID != testUser || in.StartDate != "2025-01-01" {
+		t.Errorf("importInput = %+v", in)
+	}
+
+	if _, err := readXLSX(xlsxFile(t, xlsxRows), "Missing"); err == nil {
+		t.Error("unknown sheet: want error")
+	}
+	if _, err := readXLSX([]byte("not a zip"), ""); err == nil {
+		t.Error("broken file: want error")
+	}
+}
+
+func TestImportResolvesCatalog(t *testing.T) {
+	api := newTestAPI(t)
+	w := api.do("POST", "/services", map[string]interface{}{
+		"name": "Yandex Plus", "aliases": []string{"Яндекс Плюс"}, "default_price": 299, "category": "music",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /services: %d %s", w.Code, w.Body)
+	}
+	var svc model.CatalogService
+	decodeBody(t, w, &svc)
+
+	header := "service_id,service_name,price,user_id,start_date\n"
+	unknown := header +
+		",яндекс плюс,299," + testUser + ",01-2025\n" +
+		"00000000-0000-0000-0000-000000000000,,100," + testUser + ",01-2025\n"
+	w = api.send("POST", "/subscriptions/import", "text/csv", unknown)
+	var report model.ImportReport
+	decodeBody(t, w, &report)
+	if w.Code != http.StatusUnprocessableEntity || len(report.Errors) != 1 || report.Errors[0].Line != 3 || report.Errors[0].Column != "service_id" {
+		t.Fatalf("unknown service_id: %d %+v", w.Code, report)
+	}
+
+	file := header +
+		",яндекс плюс,299," + testUser + ",01-2025\n" +
+		svc.ID + ",,299," + otherUser + ",01-2025\n" +
+		",Spotify,200," + testUser + ",01-2025\n"
+	if w := api.send("POST", "/subscriptions/import", "text/csv", file); w.Code != http.StatusCreated {
+		t.Fatalf("import: %d %s", w.Code, w.Body)
+	}
+	// строки по псевдониму и по service_id связаны с услугой и получают её категорию
+	linked := 0
+	for _, sub := range api.list("/subscriptions?service_id=" + svc.ID) {
+		if sub.ServiceName != "Yandex Plus" || sub.Category == nil || *sub.Category != "music" {
+			t.Errorf("imported subscription %+v is not linked to the catalog", sub)
+		}
+		linked++
+	}
+	if linked != 2 {
+		t.Errorf("%d subscriptions linked to the catalog service, want 2", linked)
+	}
+	if subs := api.list("/subscriptions?service_name=Spotify"); len(subs) != 1 || subs[0].ServiceID != nil {
+		t.Errorf("name outside of the catalog: %+v", subs)
+	}
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"subscription-service/internal/model"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) PauseSubscription(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, "pause", h.svc.Pause)
+}
+
+func (h *Handler) ResumeSubscription(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, "resume", h.svc.Resume)
+}
+
+func (h *Handler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, "cancel", h.svc.Cancel)
+}
+
+// changeStatus выполняет действие над подпиской и возвращает её в новом статусе
+func (h *Handler) changeStatus(w http.ResponseWriter, r *http.Request, action string,
+	do func(ctx context.Context, id string) (*model.Subscription, error)) {
+	id := mux.Vars(r)["id"]
+
+	sub, err := do(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err, action+" subscription failed")
+		return
+	}
+
+	w.Header().Set("ETag", etag(sub))
+	writeJSON(w, sub)
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"subscription-service/internal/model"
+)
+
+func TestSubscriptionLifecycle(t *testing.T) {
+	api := newTestAPI(t)
+	id := api.create(map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025", "status": "trial"})
+
+	steps := []struct {
+		action string
+		code   int
+		status model.Status
+	}{
+		{"resume", http.StatusConflict, ""},
+		{"pause", http.StatusOK, model.StatusPaused},
+		{"pause", http.StatusConflict, ""},
+		{"resume", http.StatusOK, model.StatusActive},
+		{"cancel", http.StatusOK, model.StatusCancelled},
+		{"resume", http.StatusConflict, ""},
+		{"cancel", http.StatusConflict, ""},
+	}
+	for _, st := range steps {
+		w := api.do("POST", "/subscriptions/"+id+"/"+st.action, nil)
+		if w.Code != st.code {
+			t.Fatalf("%s: status %d, want %d: %s", st.action, w.Code, st.code, w.Body)
+		}
+		if st.code != http.StatusOK {
+			continue
+		}
+		var sub model.Subscription
+		decodeBody(t, w, &sub)
+		if sub.Status != st.status {
+			t.Fatalf("%s: subscription status %q, want %q", st.action, sub.Status, st.status)
+		}
+	}
+
+	var sub model.Subscription
+	api.get("/subscriptions/"+id, &sub)
+	if sub.EndDate == nil {
+		t.Error("cancelled subscription has no end_date")
+	}
+
+	if w := api.do("POST", "/subscriptions/00000000-0000-0000-0000-000000000000/pause", nil); w.Code != http.StatusNotFound {
+		t.Errorf("pause unknown subscription: status %d, want 404", w.Code)
+	}
+	if w := api.do("POST", "/subscriptions", map[string]interface{}{
+		"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025", "status": "paused",
+	}); w.Code != http.StatusBadRequest {
+		t.Errorf("create paused subscription: status %d, want 400", w.Code)
+	}
+}
+
+func TestAggregateExcludesPauses(t *testing.T) {
+	api := newTestAPI(t)
+	id := api.create(map[string]interface{}{"service_name": "Netflix", "price": 310, "user_id": testUser, "start_date": "01-2025"})
+	// пауза с 11 по 20 января и весь февраль
+	api.exec(`INSERT INTO subscription_pauses (subscription_id, paused_at, resumed_at) VALUES
+		($1, '2025-01-11', '2025-01-21'), ($1, '2025-02-01', '2025-03-01')`, id)
+
+	tests := []struct {
+		query  string
+		months int
+		days   int
+		cost   int64
+	}{
+		{"from=01-2025&to=01-2025&proration=daily", 1, 21, 210},
+		// февраль целиком на паузе и не учитывается
+		{"from=01-2025&to=03-2025", 2, 52, 620},
+		{"from=01-2025&to=03-2025&proration=daily", 2, 52, 520},
+	}
+	for _, tt := range tests {
+		var got model.AggregateResponse
+		api.get("/subscriptions/aggregate?"+tt.query, &got)
+		if len(got.Subscriptions) != 1 {
+			t.Fatalf("%s: %d subscriptions, want 1", tt.query, len(got.Subscriptions))
+		}
+		s := got.Subscriptions[0]
+		if s.Months != tt.months || s.Days != tt.days || s.Cost != tt.cost || got.Total != tt.cost {
+			t.Errorf("%s: months %d, days %d, cost %d, total %d; want %d, %d, %d",
+				tt.query, s.Months, s.Days, s.Cost, got.Total, tt.months, tt.days, tt.cost)
+		}
+	}
+}
+
+func TestAggregateWeeklyExcludesPausedCharges(t *testing.T) {
+	api := newTestAPI(t)
+	// 2025-01-01 — среда: списания 1, 8, 15, 22 и 29 января
+	id := api.create(map[string]interface{}{"service_name": "Weekly", "price": 100, "user_id": testUser, "start_date": "01-2025", "billing_period": "weekly"})
+	// пауза с 10 по 20 января накрывает списание 15 января
+	api.exec(`INSERT INTO subscription_pauses (subscription_id, paused_at, resumed_at) VALUES ($1, '2025-01-10', '2025-01-21')`, id)
+
+	var got model.AggregateResponse
+	api.get("/subscriptions/aggregate?from=01-2025&to=01-2025", &got)
+	if len(got.Subscriptions) != 1 || got.Subscriptions[0].Cost != 400 || got.Total != 400 {
+		t.Errorf("subscriptions %+v, total %d; want cost 400", got.Subscriptions, got.Total)
+	}
+
+	// бессрочная пауза с 22 января снимает и 22, и 29 января
+	api.exec(`UPDATE subscription_pauses SET paused_at = '2025-01-22', resumed_at = NULL WHERE subscription_id = $1`, id)
+	var monthly model.MonthlyAggregateResponse
+	api.get("/subscriptions/aggregate?mode=monthly&from=01-2025&to=01-2025", &monthly)
+	if monthly.Total != 300 {
+		t.Errorf("monthly total = %d, want 300", monthly.Total)
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"subscription-service/internal/model"
+)
+
+func TestListCursorPagination(t *testing.T) {
+	api := newTestAPI(t)
+	// две подписки с одной датой начала: курсор различает их по id
+	for _, start := range []string{"01-2025", "03-2025", "02-2025", "02-2025", "04-2025"} {
+		api.create(map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": start})
+	}
+
+	seen := map[string]bool{}
+	var starts []string
+	path := "/subscriptions?limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination does not stop")
+		}
+		var page model.SubscriptionPage
+		api.get(path, &page)
+		if page.Total != 5 {
+			t.Errorf("page %d: total %d, want 5", pages, page.Total)
+		}
+		for _, sub := range page.Items {
+			if seen[sub.ID] {
+				t.Errorf("subscription %s returned twice", sub.ID)
+			}
+			seen[sub.ID] = true
+			starts = append(starts, sub.StartDate.Format("01-2006"))
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		path = "/subscriptions?limit=2&cursor=" + *page.NextCursor
+	}
+	if want := "[04-2025 03-2025 02-2025 02-2025 01-2025]"; len(seen) != 5 || fmt.Sprint(starts) != want {
+		t.Errorf("start dates %v, want %s", starts, want)
+	}
+
+	for _, query := range []string{"limit=0", "limit=1001", "offset=-1", "cursor=bogus", "cursor=" + (model.ListCursor{StartDate: date(2025, 1, 1), ID: "x"}).Encode() + "&offset=2"} {
+		if w := api.do("GET", "/subscriptions?"+query, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, w.Code)
+		}
+	}
+}
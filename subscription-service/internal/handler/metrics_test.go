@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	api := newTestAPI(t)
+	id := api.create(map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025"})
+	api.do("GET", "/subscriptions/"+id, nil)
+	api.do("GET", "/subscriptions/00000000-0000-0000-0000-000000000000", nil)
+	api.do("GET", "/no/such/route", nil)
+
+	w := api.do("GET", "/metrics", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics: %d %s", w.Code, w.Body)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		// маршрут — шаблоном, а не путём с идентификатором
+		`subscription_service_http_requests_total{method="GET",route="/subscriptions/{id}",status="200"} 1`,
+		`subscription_service_http_requests_total{method="GET",route="/subscriptions/{id}",status="404"} 1`,
+		`subscription_service_http_requests_total{method="POST",route="/subscriptions",status="201"} 1`,
+		`subscription_service_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		// «не найдено» — не сбой БД
+		`subscription_service_db_query_duration_seconds_count{outcome="ok",query="GetByID"}`,
+		`subscription_service_subscriptions{status="active"} 1`,
+		`subscription_service_monthly_spend{currency="RUB"} 400`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics has no %s", want)
+		}
+	}
+	// сбор бизнес-метрик идёт мимо инструментированного репозитория
+	if strings.Contains(body, `query="Stats"`) {
+		t.Error("/metrics scrape is counted as a repository call")
+	}
+	if strings.Contains(body, id) {
+		t.Errorf("/metrics contains subscription id %s", id)
+	}
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"subscription-service/internal/logger"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// Цепочка middleware вокруг роутера: requestIDMiddleware → accessLog → recoverPanic.
+// Она оборачивает роутер целиком, поэтому действует и для неизвестных путей.
+
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen — длиннее клиентский X-Request-ID не принимается и заменяется новым
+const maxRequestIDLen = 128
+
+type requestIDKey struct{}
+
+// chain собирает middleware вокруг h; первый в списке оказывается внешним
+func chain(h http.Handler, mws ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// requestIDMiddleware берёт X-Request-ID из запроса или создаёт новый, возвращает его
+// в ответе и кладёт в контекст вместе с логгером запроса, у которого есть поле request_id
+func requestIDMiddleware(log *zerolog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(requestIDHeader)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set(requestIDHeader, id)
+
+			l := log.With().Str("request_id", id).Logger()
+			ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+			next.ServeHTTP(w, r.WithContext(l.WithContext(ctx)))
+		})
+	}
+}
+
+// validRequestID пропускает только короткие идентификаторы из печатных ASCII-символов,
+// чтобы клиент не мог подсунуть в логи и заголовки переводы строк
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestID возвращает идентификатор запроса, назначенный requestIDMiddleware
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// accessLog пишет строку на каждый запрос: метод, путь, статус, длительность и размер
+// ответа. Пробы и сбор метрик пишутся на уровне debug, ответы 5xx — на уровне error.
+func accessLog(fallback *zerolog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			log := logger.FromContext(r.Context(), fallback)
+			var e *zerolog.Event
+			switch {
+			case status >= http.StatusInternalServerError:
+				e = log.Error()
+			case r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || r.URL.Path == "/metrics":
+				e = log.Debug()
+			default:
+				e = log.Info()
+			}
+			e.Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Int("status", status).
+				Dur("latency", time.Since(start)).
+				Int64("bytes", rw.bytes).
+				Str("remote_addr", r.RemoteAddr).
+				Msg("request handled")
+		})
+	}
+}
+
+// recoverPanic превращает панику обработчика в ответ 500 и пишет её в лог со стеком.
+// Если ответ уже начат, статус поменять нельзя — соединение просто закрывается.
+func recoverPanic(fallback *zerolog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &responseWriter{ResponseWriter: w}
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				// http.ErrAbortHandler — штатный способ прервать ответ, net/http обработает его сам
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				logger.FromContext(r.Context(), fallback).Error().Ctx(r.Context()).
+					Str("panic", fmt.Sprint(v)).
+					Bytes("stack", debug.Stack()).
+					Str("method", r.Method).
+					Str("path", r.URL.Path).
+					Msg("handler panicked")
+
+				if rw.status != 0 {
+					panic(http.ErrAbortHandler)
+				}
+				writeProblem(w, r, http.StatusInternalServerError, codeInternal, "internal error")
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// responseWriter запоминает код ответа и число записанных байт
+type responseWriter struct {
+	http.ResponseWriter
+	status int // 0 — заголовки ещё не отправлены
+	bytes  int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap нужен http.ResponseController: потоковый экспорт сбрасывает данные через Flush
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
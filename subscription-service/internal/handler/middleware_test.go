@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"subscription-service/internal/logger"
+
+	"github.com/rs/zerolog"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"60601fee-2bf1-4721-ae6f-7636e79a0cba", true},
+		{"req_42", true},
+		{"!~", true},
+		{strings.Repeat("a", maxRequestIDLen), true},
+		{strings.Repeat("a", maxRequestIDLen+1), false},
+		{"", false},
+		{"with space", false},
+		{"line\nbreak", false},
+		{"tab\t", false},
+		{"del\x7f", false},
+		{"кириллица", false},
+	}
+	for _, tt := range tests {
+		if got := validRequestID(tt.id); got != tt.want {
+			t.Errorf("validRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	log := zerolog.New(&buf)
+	var gotID string
+	h := requestIDMiddleware(&log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = requestID(r.Context())
+		logger.FromContext(r.Context(), nil).Info().Msg("handled")
+	}))
+
+	tests := []struct {
+		header string
+		keep   bool
+	}{
+		{"req-42", true},
+		{"", false},
+		{"evil\r\nSet-Cookie: x", false},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(requestIDHeader, tt.header)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if gotID == "" || w.Header().Get(requestIDHeader) != gotID {
+			t.Errorf("%q: context id %q, header %q", tt.header, gotID, w.Header().Get(requestIDHeader))
+		}
+		if (gotID == tt.header) != tt.keep {
+			t.Errorf("%q: got id %q, keep = %v", tt.header, gotID, tt.keep)
+		}
+		// логгер запроса пишет request_id в каждое событие
+		if !strings.Contains(buf.String(), `"request_id":"`+gotID+`"`) {
+			t.Errorf("%q: log has no request_id: %s", tt.header, buf.String())
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	log := zerolog.New(&buf).Level(zerolog.InfoLevel)
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			_, _ = w.Write([]byte("hello"))
+		}
+	}), requestIDMiddleware(&log), accessLog(&log))
+
+	tests := []struct {
+		path string
+		want string // пусто — строки в логе быть не должно
+	}{
+		{"/subscriptions", `"level":"info","request_id":"req-1","method":"GET","path":"/subscriptions","status":200`},
+		{"/fail", `"level":"error","request_id":"req-1","method":"GET","path":"/fail","status":502`},
+		{"/healthz", ""}, // пробы пишутся на уровне debug
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		r := httptest.NewRequest("GET", tt.path, nil)
+		r.Header.Set(requestIDHeader, "req-1")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if tt.want == "" {
+			if buf.Len() != 0 {
+				t.Errorf("%s: unexpected log %s", tt.path, buf.String())
+			}
+			continue
+		}
+		if !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("%s: log %s, want %s", tt.path, buf.String(), tt.want)
+		}
+	}
+	buf.Reset()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/subscriptions", nil))
+	if !strings.Contains(buf.String(), `"bytes":5`) {
+		t.Errorf("log has no response size: %s", buf.String())
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	var buf bytes.Buffer
+	log := zerolog.New(&buf)
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/streaming" {
+			_, _ = w.Write([]byte("partial"))
+		}
+		panic("boom")
+	}), requestIDMiddleware(&log), accessLog(&log), recoverPanic(&log))
+
+	r := httptest.NewRequest("GET", "/subscriptions", nil)
+	r.Header.Set(requestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var p problem
+	decodeBody(t, w, &p)
+	if w.Code != http.StatusInternalServerError || p.Code != codeInternal || p.RequestID != "req-1" {
+		t.Errorf("panic: %d %+v", w.Code, p)
+	}
+	logged := buf.String()
+	if !strings.Contains(logged, `"panic":"boom"`) || !strings.Contains(logged, `"stack":`) || !strings.Contains(logged, `"status":500`) {
+		t.Errorf("panic log: %s", logged)
+	}
+
+	// ответ уже начат: статус не поменять, соединение обрывается
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("panic after response started: recovered %v, want http.ErrAbortHandler", v)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/streaming", nil))
+}
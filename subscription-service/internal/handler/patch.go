@@ -0,0 +1,439 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"subscription-service/internal/model"
+
+	"github.com/gorilla/mux"
+)
+
+// Частичное обновление подписки: PATCH применяется к JSON-документу с текущими полями
+// подписки (в том же виде, что тело PUT), после чего результат проходит ту же проверку,
+// что и при создании. Поддерживаются JSON Merge Patch (RFC 7396) и JSON Patch (RFC 6902).
+
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// requiredPatchFields — поля, которые патч не может удалить: без них подписка не имеет
+// смысла, а отсутствующее поле молча стало бы нулевым значением
+var requiredPatchFields = []string{"service_name", "price", "user_id", "start_date"}
+
+// errPatchTestFailed — операция test из JSON Patch не совпала с текущим значением
+var errPatchTestFailed = errors.New("json patch test failed")
+
+// PatchSubscription меняет только переданные поля подписки. Тип патча задаётся
+// Content-Type: application/merge-patch+json (по умолчанию) или application/json-patch+json.
+// В merge patch отсутствующее поле не меняется, а null очищает его.
+func (h *Handler) PatchSubscription(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	contentType := mergePatchType
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			writeProblem(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "invalid Content-Type")
+			return
+		}
+		contentType = mt
+	}
+	if contentType != mergePatchType && contentType != jsonPatchType {
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		writeProblem(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "unsupported patch type, expected "+mergePatchType+" or "+jsonPatchType)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "failed to read body")
+		return
+	}
+
+	current, err := h.svc.GetByID(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err, "get subscription failed")
+		return
+	}
+
+	if !matchVersion(r, current.Version) {
+		w.Header().Set("ETag", etag(current))
+		writeProblem(w, r, http.StatusPreconditionFailed, codePreconditionFailed, "subscription was modified, ETag does not match")
+		return
+	}
+
+	orig := inputFromModel(current)
+	var doc interface{}
+	if err := decodeStrict(orig, &doc); err != nil {
+		h.writeError(w, r, err, "encode subscription for patch failed")
+		return
+	}
+
+	if contentType == jsonPatchType {
+		var ops []patchOp
+		if err := json.Unmarshal(body, &ops); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid json patch: "+err.Error())
+			return
+		}
+		doc, err = applyJSONPatch(doc, ops)
+		if errors.Is(err, errPatchTestFailed) {
+			writeProblem(w, r, http.StatusConflict, codePatchTestFailed, err.Error())
+			return
+		}
+		if err != nil {
+			writeProblem(w, r, http.StatusUnprocessableEntity, codeInvalidPatch, "invalid json patch: "+err.Error())
+			return
+		}
+	} else {
+		var patch interface{}
+		if err := json.Unmarshal(body, &patch); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid json: "+err.Error())
+			return
+		}
+		if _, ok := patch.(map[string]interface{}); !ok {
+			writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "merge patch must be a JSON object")
+			return
+		}
+		doc = mergePatch(doc, patch)
+	}
+	if err := checkRequiredFields(doc); err != nil {
+		writeInvalid(w, r, err)
+		return
+	}
+
+	var in subscriptionInput
+	if err := decodeStrict(doc, &in); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidPatch, "invalid patched subscription: "+err.Error())
+		return
+	}
+	if in.Status != "" {
+		writeInvalid(w, r, invalidField("status", "status can not be patched; use pause, resume and cancel actions"))
+		return
+	}
+	// новое название без нового service_id заново ищется в каталоге
+	if in.ServiceName != orig.ServiceName && reflect.DeepEqual(in.ServiceID, orig.ServiceID) {
+		in.ServiceID = nil
+	}
+	// trial_days задаёт конец пробного периода заново, прежний trial_end ему не мешает
+	if in.TrialDays != nil && reflect.DeepEqual(in.TrialEnd, orig.TrialEnd) {
+		in.TrialEnd = nil
+	}
+
+	sub, err := in.toModel()
+	if err != nil {
+		writeInvalid(w, r, err)
+		return
+	}
+	sub.ID = id
+	// патч применён к прочитанной версии: если её успели изменить, запись не пройдёт
+	sub.Version = current.Version
+
+	conflictStatus := http.StatusConflict
+	if _, any := ifMatch(r); !any {
+		conflictStatus = http.StatusPreconditionFailed
+	}
+	h.saveSubscription(w, r, sub, conflictStatus)
+}
+
+// inputFromModel представляет подписку в виде тела PUT — документа, к которому применяется патч
+func inputFromModel(sub *model.Subscription) subscriptionInput {
+	in := subscriptionInput{
+		ServiceID:     sub.ServiceID,
+		ServiceName:   sub.ServiceName,
+		Price:         sub.Price,
+		UserID:        sub.UserID,
+		StartDate:     sub.StartDate.Format("2006-01-02"),
+		BillingPeriod: string(sub.BillingPeriod),
+		Currency:      sub.Currency,
+		Category:      sub.Category,
+		Tags:          append([]string{}, sub.Tags...),
+	}
+	if sub.EndDate != nil {
+		end := sub.EndDate.Format("2006-01-02")
+		in.EndDate = &end
+	}
+	if sub.TrialEnd != nil {
+		trialEnd := sub.TrialEnd.Format("2006-01-02")
+		in.TrialEnd = &trialEnd
+	}
+	return in
+}
+
+// mergePatch применяет JSON Merge Patch: null удаляет поле, объекты сливаются рекурсивно,
+// остальные значения заменяются целиком; поля, которых нет в patch, не меняются
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// checkRequiredFields проверяет, что патч не удалил обязательные поля и не заменил их на null
+func checkRequiredFields(doc interface{}) error {
+	m, _ := doc.(map[string]interface{})
+	var errs validationErrors
+	for _, f := range requiredPatchFields {
+		if v, ok := m[f]; !ok || v == nil {
+			errs.add(f, f+" is required and can not be removed")
+		}
+	}
+	return errs.err()
+}
+
+// patchOp — операция JSON Patch; Value == nil — поле value не передано
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// applyJSONPatch последовательно применяет операции к документу; ошибка в любой
+// операции отменяет весь патч
+func applyJSONPatch(doc interface{}, ops []patchOp) (interface{}, error) {
+	for i, op := range ops {
+		var err error
+		doc, err = applyOp(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyOp(doc interface{}, op patchOp) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	value := func() (interface{}, error) {
+		if op.Value == nil {
+			return nil, errors.New("value is required")
+		}
+		var v interface{}
+		if err := json.Unmarshal(op.Value, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+
+	switch op.Op {
+	case "add":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return addAt(doc, path, v)
+	case "remove":
+		return removeAt(doc, path)
+	case "replace":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := getAt(doc, path); err != nil {
+			return nil, err
+		}
+		if doc, err = removeAt(doc, path); err != nil {
+			return nil, err
+		}
+		return addAt(doc, path, v)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := getAt(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, errors.New("cannot move a value into itself")
+			}
+			if doc, err = removeAt(doc, from); err != nil {
+				return nil, err
+			}
+		} else if v, err = deepCopy(v); err != nil {
+			return nil, err
+		}
+		return addAt(doc, path, v)
+	case "test":
+		want, err := value()
+		if err != nil {
+			return nil, err
+		}
+		got, err := getAt(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(got, want) {
+			return nil, errPatchTestFailed
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+}
+
+// parsePointer разбирает JSON Pointer (RFC 6901). Операции над документом целиком
+// не поддерживаются: подписку можно менять только по полям.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, errors.New("path must point to a field, not the whole document")
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("invalid path %q", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// arrayIndex разбирает индекс массива длины n; "-" (конец массива) допустим только при добавлении
+func arrayIndex(token string, n int, adding bool) (int, error) {
+	if token == "-" && adding {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > n || (i == n && !adding) {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func getAt(doc interface{}, path []string) (interface{}, error) {
+	node := doc
+	for _, t := range path {
+		switch c := node.(type) {
+		case map[string]interface{}:
+			v, ok := c[t]
+			if !ok {
+				return nil, fmt.Errorf("path not found: %s", t)
+			}
+			node = v
+		case []interface{}:
+			i, err := arrayIndex(t, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			node = c[i]
+		default:
+			return nil, fmt.Errorf("path not found: %s", t)
+		}
+	}
+	return node, nil
+}
+
+// updateAt заменяет контейнер, в котором лежит последний элемент пути, результатом fn
+func updateAt(doc interface{}, path []string, fn func(container interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+	child, err := getAt(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	child, err = updateAt(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		c[path[0]] = child
+	case []interface{}:
+		i, _ := arrayIndex(path[0], len(c), false)
+		c[i] = child
+	}
+	return doc, nil
+}
+
+func addAt(doc interface{}, path []string, v interface{}) (interface{}, error) {
+	return updateAt(doc, path, func(container interface{}, key string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[key] = v
+			return c, nil
+		case []interface{}:
+			i, err := arrayIndex(key, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = v
+			return c, nil
+		}
+		return nil, fmt.Errorf("path not found: %s", key)
+	})
+}
+
+func removeAt(doc interface{}, path []string) (interface{}, error) {
+	return updateAt(doc, path, func(container interface{}, key string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, ok := c[key]; !ok {
+				return nil, fmt.Errorf("path not found: %s", key)
+			}
+			delete(c, key)
+			return c, nil
+		case []interface{}:
+			i, err := arrayIndex(key, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(c[:i:i], c[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("path not found: %s", key)
+	})
+}
+
+func deepCopy(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+// decodeStrict переводит документ после патча в тело запроса; неизвестные поля —
+// ошибка, иначе опечатка в пути молча ничего бы не меняла
+func decodeStrict(doc interface{}, out interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(out)
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"subscription-service/internal/model"
+)
+
+func decodeDoc(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("decode %s: %v", s, err)
+	}
+	return v
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		patch  string
+		want   string
+	}{
+		{"replace value", `{"a":1,"b":2}`, `{"a":3}`, `{"a":3,"b":2}`},
+		{"add field", `{"a":1}`, `{"b":"x"}`, `{"a":1,"b":"x"}`},
+		{"null removes field", `{"a":1,"b":2}`, `{"a":null}`, `{"b":2}`},
+		{"null on missing field", `{"a":1}`, `{"b":null}`, `{"a":1}`},
+		{"nested merge", `{"a":{"x":1,"y":2}}`, `{"a":{"y":null,"z":3}}`, `{"a":{"x":1,"z":3}}`},
+		{"array replaced whole", `{"tags":["a","b"]}`, `{"tags":["c"]}`, `{"tags":["c"]}`},
+		{"object over scalar", `{"a":1}`, `{"a":{"b":2}}`, `{"a":{"b":2}}`},
+		{"empty patch", `{"a":1}`, `{}`, `{"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergePatch(decodeDoc(t, tt.target), decodeDoc(t, tt.patch))
+			if want := decodeDoc(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("mergePatch() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	const doc = `{"price":100,"tags":["a","b"],"end_date":"2025-12-31"}`
+	tests := []struct {
+		name    string
+		ops     string
+		want    string
+		wantErr error // nil — любая ошибка, если want пуст
+	}{
+		{"replace", `[{"op":"replace","path":"/price","value":200}]`, `{"price":200,"tags":["a","b"],"end_date":"2025-12-31"}`, nil},
+		{"remove field", `[{"op":"remove","path":"/end_date"}]`, `{"price":100,"tags":["a","b"]}`, nil},
+		{"add to array end", `[{"op":"add","path":"/tags/-","value":"c"}]`, `{"price":100,"tags":["a","b","c"],"end_date":"2025-12-31"}`, nil},
+		{"insert into array", `[{"op":"add","path":"/tags/0","value":"z"}]`, `{"price":100,"tags":["z","a","b"],"end_date":"2025-12-31"}`, nil},
+		{"remove from array", `[{"op":"remove","path":"/tags/1"}]`, `{"price":100,"tags":["a"],"end_date":"2025-12-31"}`, nil},
+		{"copy", `[{"op":"copy","from":"/tags/0","path":"/tags/-"}]`, `{"price":100,"tags":["a","b","a"],"end_date":"2025-12-31"}`, nil},
+		{"move", `[{"op":"move","from":"/end_date","path":"/start_date"}]`, `{"price":100,"tags":["a","b"],"start_date":"2025-12-31"}`, nil},
+		{"test passes", `[{"op":"test","path":"/price","value":100},{"op":"replace","path":"/price","value":1}]`, `{"price":1,"tags":["a","b"],"end_date":"2025-12-31"}`, nil},
+		{"test fails", `[{"op":"test","path":"/price","value":1}]`, "", errPatchTestFailed},
+		{"replace missing path", `[{"op":"replace","path":"/currency","value":"USD"}]`, "", nil},
+		{"remove missing path", `[{"op":"remove","path":"/currency"}]`, "", nil},
+		{"value required", `[{"op":"add","path":"/currency"}]`, "", nil},
+		{"whole document", `[{"op":"replace","path":"","value":{}}]`, "", nil},
+		{"path without slash", `[{"op":"remove","path":"price"}]`, "", nil},
+		{"unknown op", `[{"op":"merge","path":"/price"}]`, "", nil},
+		{"move into itself", `[{"op":"move","from":"/tags","path":"/tags/0"}]`, "", nil},
+		{"array index out of range", `[{"op":"remove","path":"/tags/2"}]`, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []patchOp
+			if err := json.Unmarshal([]byte(tt.ops), &ops); err != nil {
+				t.Fatalf("decode ops: %v", err)
+			}
+			got, err := applyJSONPatch(decodeDoc(t, doc), ops)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("applyJSONPatch() = %v, want error", got)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("applyJSONPatch() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyJSONPatch() error = %v", err)
+			}
+			if want := decodeDoc(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("applyJSONPatch() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestArrayIndex(t *testing.T) {
+	tests := []struct {
+		token   string
+		n       int
+		adding  bool
+		want    int
+		wantErr bool
+	}{
+		{"0", 2, false, 0, false},
+		{"1", 2, false, 1, false},
+		{"2", 2, false, 0, true},
+		{"2", 2, true, 2, false},
+		{"3", 2, true, 0, true},
+		{"-", 2, true, 2, false},
+		{"-", 2, false, 0, true},
+		{"01", 2, false, 0, true},
+		{"-1", 2, false, 0, true},
+		{"x", 2, false, 0, true},
+		{"", 2, false, 0, true},
+		{"0", 0, false, 0, true},
+		{"0", 0, true, 0, false},
+	}
+	for _, tt := range tests {
+		got, err := arrayIndex(tt.token, tt.n, tt.adding)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("arrayIndex(%q, %d, %v) error = %v, wantErr %v", tt.token, tt.n, tt.adding, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("arrayIndex(%q, %d, %v) = %d, want %d", tt.token, tt.n, tt.adding, got, tt.want)
+		}
+	}
+}
+
+func TestPatchSubscription(t *testing.T) {
+	api := newTestAPI(t)
+	id := api.create(map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025", "tags": []string{"a"}})
+	path := "/subscriptions/" + id
+
+	patched := func(contentType, body string) model.Subscription {
+		t.Helper()
+		w := api.send("PATCH", path, contentType, body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("PATCH %s: %d %s", body, w.Code, w.Body)
+		}
+		var sub model.Subscription
+		decodeBody(t, w, &sub)
+		return sub
+	}
+
+	sub := patched(mergePatchType, `{"price":500,"end_date":"12-2025","tags":null}`)
+	if sub.Price != 500 || sub.EndDate == nil || sub.EndDate.Format("2006-01-02") != "2025-12-31" || len(sub.Tags) != 0 {
+		t.Errorf("after merge patch: %+v", sub)
+	}
+	// остальные поля не меняются, null очищает end_date
+	sub = patched("", `{"end_date":null}`)
+	if sub.EndDate != nil || sub.ServiceName != "Netflix" || sub.Price != 500 {
+		t.Errorf("after end_date null: %+v", sub)
+	}
+
+	sub = patched(jsonPatchType, `[{"op":"test","path":"/price","value":500},{"op":"replace","path":"/price","value":600},{"op":"add","path":"/tags/-","value":"b"}]`)
+	if sub.Price != 600 || len(sub.Tags) != 1 || sub.Tags[0] != "b" {
+		t.Errorf("after json patch: %+v", sub)
+	}
+
+	errs := []struct {
+		contentType, body string
+		code              int
+	}{
+		{jsonPatchType, `[{"op":"test","path":"/price","value":1}]`, http.StatusConflict},
+		{jsonPatchType, `[{"op":"remove","path":"/currency_code"}]`, http.StatusUnprocessableEntity},
+		{mergePatchType, `[1]`, http.StatusBadRequest},
+		{mergePatchType, `{"status":"paused"}`, http.StatusBadRequest},
+		{mergePatchType, `{"price":-1}`, http.StatusBadRequest},
+		{mergePatchType, `{"unknown":1}`, http.StatusBadRequest},
+		{mergePatchType, `{"price":null}`, http.StatusBadRequest},
+		{jsonPatchType, `[{"op":"remove","path":"/service_name"}]`, http.StatusBadRequest},
+		{"text/plain", `price=1`, http.StatusUnsupportedMediaType},
+		{"application/json", `{"price":1}`, http.StatusUnsupportedMediaType},
+	}
+	for _, e := range errs {
+		if w := api.send("PATCH", path, e.contentType, e.body); w.Code != e.code {
+			t.Errorf("PATCH %s %s: status %d, want %d", e.contentType, e.body, w.Code, e.code)
+		}
+	}
+	if w := api.send("PATCH", "/subscriptions/00000000-0000-0000-0000-000000000000", mergePatchType, `{"price":1}`); w.Code != http.StatusNotFound {
+		t.Errorf("PATCH unknown subscription: status %d, want 404", w.Code)
+	}
+
+	var got model.Subscription
+	api.get(path, &got)
+	if got.Price != 600 {
+		t.Errorf("failed patches changed the subscription: price %d", got.Price)
+	}
+}
+
+func TestCheckRequiredFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		doc    string
+		fields []string
+	}{
+		{"all present", `{"service_name":"Netflix","price":0,"user_id":"u","start_date":"07-2025"}`, nil},
+		{"price removed", `{"service_name":"Netflix","user_id":"u","start_date":"07-2025"}`, []string{"price"}},
+		{"price null", `{"service_name":"Netflix","price":null,"user_id":"u","start_date":"07-2025"}`, []string{"price"}},
+		{"several missing", `{"price":1}`, []string{"service_name", "user_id", "start_date"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRequiredFields(decodeDoc(t, tt.doc))
+			var got []string
+			var fields validationErrors
+			if errors.As(err, &fields) {
+				for _, f := range fields {
+					got = append(got, f.Field)
+				}
+			} else if err != nil {
+				t.Fatalf("checkRequiredFields() error = %v, want validationErrors", err)
+			}
+			if !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("checkRequiredFields() fields = %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// SchedulePriceChange назначает новую цену подписки с указанного месяца (текущего или будущего)
+func (h *Handler) SchedulePriceChange(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var in struct {
+		Price         *int   `json:"price"`
+		EffectiveFrom string `json:"effective_from"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid json: "+err.Error())
+		return
+	}
+	if in.Price == nil || *in.Price < 0 {
+		writeInvalid(w, r, invalidField("price", "price is required and must be >= 0"))
+		return
+	}
+	month, _, err := parseDate(in.EffectiveFrom)
+	if err != nil {
+		writeInvalid(w, r, invalidField("effective_from", "invalid effective_from format, expected "+dateFormats))
+		return
+	}
+
+	history, err := h.svc.SchedulePriceChange(r.Context(), id, month, *in.Price)
+	if err != nil {
+		h.writeError(w, r, err, "schedule price change failed")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, history)
+}
+
+func (h *Handler) PriceHistory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	history, err := h.svc.PriceHistory(r.Context(), id)
+	if err != nil {
+		h.writeError(w, r, err, "price history failed")
+		return
+	}
+
+	writeJSON(w, history)
+}
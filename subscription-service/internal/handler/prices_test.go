@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+func TestPriceHistory(t *testing.T) {
+	api := newTestAPI(t)
+	sub := map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025"}
+	id := api.create(sub)
+	// повышение цены в прошлом задаём напрямую: через API можно назначать только будущие
+	api.exec(`INSERT INTO subscription_prices (subscription_id, effective_month, price) VALUES ($1, '2025-03-01', 500)`, id)
+
+	var got model.AggregateResponse
+	api.get("/subscriptions/aggregate?from=01-2025&to=04-2025", &got)
+	if got.Total != 400+400+500+500 || len(got.Subscriptions) != 1 || got.Subscriptions[0].Price != 500 {
+		t.Fatalf("before update: total %d, subscriptions %+v; want 1800 at price 500", got.Total, got.Subscriptions)
+	}
+
+	// изменение цены через PUT действует с текущего месяца и не переписывает прошлое
+	sub["price"] = 600
+	if w := api.do("PUT", "/subscriptions/"+id, sub); w.Code != http.StatusOK {
+		t.Fatalf("PUT: %d %s", w.Code, w.Body)
+	}
+	api.get("/subscriptions/aggregate?from=01-2025&to=04-2025", &got)
+	if got.Total != 1800 {
+		t.Errorf("after update: total %d, want 1800", got.Total)
+	}
+	var current model.Subscription
+	api.get("/subscriptions/"+id, &current)
+	if current.Price != 600 {
+		t.Errorf("current price %d, want 600", current.Price)
+	}
+
+	if w := api.do("POST", "/subscriptions/"+id+"/prices", map[string]interface{}{"price": 300, "effective_from": "01-2025"}); w.Code != http.StatusBadRequest {
+		t.Errorf("price change in the past: status %d, want 400", w.Code)
+	}
+	next := time.Now().AddDate(0, 1, 0).Format("01-2006")
+	w := api.do("POST", "/subscriptions/"+id+"/prices", map[string]interface{}{"price": 700, "effective_from": next})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("schedule price change: %d %s", w.Code, w.Body)
+	}
+
+	var history []model.PriceChange
+	api.get("/subscriptions/"+id+"/prices", &history)
+	var prices []int
+	for _, p := range history {
+		prices = append(prices, p.Price)
+	}
+	if len(prices) != 4 || prices[0] != 400 || prices[1] != 500 || prices[2] != 600 || prices[3] != 700 {
+		t.Errorf("history prices %v, want [400 500 600 700]", prices)
+	}
+
+	if w := api.do("GET", "/subscriptions/00000000-0000-0000-0000-000000000000/prices", nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown subscription: status %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"subscription-service/internal/service"
+)
+
+// Ошибки API отдаются в формате RFC 7807 (application/problem+json). Помимо полей RFC
+// в ответе есть машиночитаемый code, ошибки по полям запроса и идентификатор запроса.
+
+const problemContentType = "application/problem+json"
+
+// Коды ошибок API, общие для всех эндпоинтов; ошибки сервиса получают коды из domainErrors
+const (
+	codeInvalidJSON          = "invalid_json"
+	codeValidationFailed     = "validation_failed"
+	codeNotFound             = "not_found"
+	codeMethodNotAllowed     = "method_not_allowed"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codePreconditionFailed   = "precondition_failed"
+	codeVersionConflict      = "version_conflict"
+	codePatchTestFailed      = "patch_test_failed"
+	codeInvalidPatch         = "invalid_patch"
+	codeUnauthorized         = "unauthorized"
+	codeAdminDisabled        = "admin_disabled"
+	codeInternal             = "internal_error"
+)
+
+// problem — тело ответа с ошибкой
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
+}
+
+// fieldError — ошибка в значении одного поля тела или параметра запроса
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationErrors — все ошибки проверки входных данных; пустой список ошибкой не считается
+type validationErrors []fieldError
+
+func (e validationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, f := range e {
+		msgs[i] = f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *validationErrors) add(field, msg string) {
+	*e = append(*e, fieldError{Field: field, Message: msg})
+}
+
+// err возвращает nil, если ошибок нет: иначе пустой список стал бы ненулевым error
+func (e validationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// invalidField — ошибка проверки одного поля
+func invalidField(field, msg string) error {
+	return validationErrors{{Field: field, Message: msg}}
+}
+
+// domainErrors — ответы на ошибки сервиса. Проверяются по порядку через errors.Is,
+// поэтому обёрнутые ошибки распознаются так же, как исходные.
+var domainErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{service.ErrNotFound, http.StatusNotFound, "subscription_not_found"},
+	{service.ErrServiceNotFound, http.StatusNotFound, "service_not_found"},
+	{service.ErrVersionConflict, http.StatusPreconditionFailed, codeVersionConflict},
+	{service.ErrInvalidTransition, http.StatusConflict, "invalid_transition"},
+	{service.ErrServiceConflict, http.StatusConflict, "service_conflict"},
+	{service.ErrRateNotFound, http.StatusUnprocessableEntity, "exchange_rate_not_found"},
+	{service.ErrPastPriceChange, http.StatusBadRequest, "past_price_change"},
+	{service.ErrIdempotencyKeyReused, http.StatusConflict, "idempotency_key_reused"},
+	{service.ErrIdempotencyInProgress, http.StatusConflict, "idempotency_in_progress"},
+	{service.ErrBatchRolledBack, http.StatusFailedDependency, "batch_rolled_back"},
+}
+
+// domainError находит ответ на ошибку сервиса; ok = false — ошибка непредвиденная
+func domainError(err error) (status int, code string, ok bool) {
+	for _, d := range domainErrors {
+		if errors.Is(err, d.err) {
+			return d.status, d.code, true
+		}
+	}
+	return http.StatusInternalServerError, codeInternal, false
+}
+
+// writeError отвечает на ошибку сервиса по таблице domainErrors. Непредвиденная ошибка
+// пишется в лог с сообщением msg, а клиент получает 500 без подробностей.
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	status, code, ok := domainError(err)
+	if !ok {
+		h.logger(r.Context()).Error().Ctx(r.Context()).Err(err).Msg(msg)
+		writeProblem(w, r, status, code, "internal error")
+		return
+	}
+	writeProblem(w, r, status, code, err.Error())
+}
+
+// writeInvalid отвечает 400 на неверные входные данные: ошибки validationErrors
+// перечисляются по полям, остальные попадают в detail
+func writeInvalid(w http.ResponseWriter, r *http.Request, err error) {
+	p := newProblem(r, http.StatusBadRequest, codeValidationFailed, err.Error())
+	var fields validationErrors
+	if errors.As(err, &fields) {
+		p.Errors = fields
+	}
+	p.write(w)
+}
+
+// writeProblem отвечает ошибкой со статусом status
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	newProblem(r, status, code, detail).write(w)
+}
+
+func newProblem(r *http.Request, status int, code, detail string) *problem {
+	return &problem{
+		Type:      "/problems/" + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: requestID(r.Context()),
+	}
+}
+
+func (p *problem) write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// notFound и methodNotAllowed — ответы роутера на неизвестные пути и методы
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusNotFound, codeNotFound, "no route for "+r.URL.Path)
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed for "+r.URL.Path)
+}
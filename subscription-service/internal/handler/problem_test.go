@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"subscription-service/internal/service"
+)
+
+func TestDomainError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+		ok     bool
+	}{
+		{service.ErrNotFound, http.StatusNotFound, "subscription_not_found", true},
+		{fmt.Errorf("%w: version 3", service.ErrVersionConflict), http.StatusPreconditionFailed, codeVersionConflict, true},
+		{fmt.Errorf("%w: operation 1 failed", service.ErrBatchRolledBack), http.StatusFailedDependency, "batch_rolled_back", true},
+		{errors.New("connection refused"), http.StatusInternalServerError, codeInternal, false},
+	}
+	for _, tt := range tests {
+		status, code, ok := domainError(tt.err)
+		if status != tt.status || code != tt.code || ok != tt.ok {
+			t.Errorf("domainError(%v) = %d %s %v, want %d %s %v", tt.err, status, code, ok, tt.status, tt.code, tt.ok)
+		}
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	var errs validationErrors
+	if errs.err() != nil {
+		t.Error("empty validationErrors must not be an error")
+	}
+	errs.add("price", "price must be >= 0")
+	errs.add("user_id", "user_id must be a valid UUID")
+	err := fmt.Errorf("row 2: %w", errs.err())
+	if err.Error() != "row 2: price must be >= 0; user_id must be a valid UUID" {
+		t.Errorf("Error() = %q", err)
+	}
+
+	w := httptest.NewRecorder()
+	writeInvalid(w, httptest.NewRequest("POST", "/subscriptions", nil), err)
+	var p problem
+	decodeBody(t, w, &p)
+	if w.Code != http.StatusBadRequest || p.Code != codeValidationFailed || !reflect.DeepEqual(p.Errors, []fieldError(errs)) {
+		t.Errorf("writeInvalid: %d %+v", w.Code, p)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestProblemResponses(t *testing.T) {
+	api := newTestAPI(t)
+
+	r := httptest.NewRequest("POST", "/subscriptions", strings.NewReader(`{"service_name":"Netflix","price":-1,"user_id":"bob","start_date":"2025"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, r)
+	var p problem
+	decodeBody(t, w, &p)
+	var fields []string
+	for _, f := range p.Errors {
+		fields = append(fields, f.Field)
+	}
+	if w.Code != http.StatusBadRequest || p.Code != codeValidationFailed || p.RequestID != "req-1" || p.Instance != "/subscriptions" {
+		t.Errorf("invalid create: %d %+v", w.Code, p)
+	}
+	if want := []string{"price", "user_id", "start_date"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("field errors %v, want %v", fields, want)
+	}
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{"POST", "/subscriptions", `{"price":`, http.StatusBadRequest, codeInvalidJSON},
+		{"GET", "/subscriptions/00000000-0000-0000-0000-000000000000", "", http.StatusNotFound, "subscription_not_found"},
+		{"GET", "/services/00000000-0000-0000-0000-000000000000", "", http.StatusNotFound, "service_not_found"},
+		{"GET", "/no/such/route", "", http.StatusNotFound, codeNotFound},
+		{"PUT", "/subscriptions/aggregate", "", http.StatusMethodNotAllowed, codeMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		w := api.send(tt.method, tt.path, "application/json", tt.body)
+		var p problem
+		decodeBody(t, w, &p)
+		if w.Code != tt.status || p.Status != tt.status || p.Code != tt.code || p.Type != "/problems/"+tt.code {
+			t.Errorf("%s %s: %d %+v, want %d %s", tt.method, tt.path, w.Code, p, tt.status, tt.code)
+		}
+		if p.RequestID == "" || w.Header().Get("X-Request-ID") != p.RequestID {
+			t.Errorf("%s %s: request id %q, header %q", tt.method, tt.path, p.RequestID, w.Header().Get("X-Request-ID"))
+		}
+	}
+}
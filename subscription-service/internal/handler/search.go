@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"subscription-service/internal/model"
+)
+
+// SearchSubscriptions ищет подписки по названию услуги: с опечатками, кириллицей вместо
+// латиницы и по псевдонимам каталога. Фильтры — как у списка, сортировка — по близости.
+func (h *Handler) SearchSubscriptions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := strings.Join(strings.Fields(q.Get("q")), " ")
+	if query == "" {
+		writeInvalid(w, r, invalidField("q", "q is required"))
+		return
+	}
+	if q.Get("sort") != "" {
+		writeInvalid(w, r, invalidField("sort", "search results are ordered by relevance, sort is not supported"))
+		return
+	}
+
+	f, err := parseListFilter(q)
+	if err != nil {
+		writeInvalid(w, r, err)
+		return
+	}
+
+	f.Limit = model.DefaultListLimit
+	if l := q.Get("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil || v < 1 || v > model.MaxListLimit {
+			writeInvalid(w, r, invalidField("limit", fmt.Sprintf("limit must be an integer from 1 to %d", model.MaxListLimit)))
+			return
+		}
+		f.Limit = v
+	}
+
+	hits, err := h.svc.Search(r.Context(), query, f)
+	if err != nil {
+		h.writeError(w, r, err, "search subscriptions failed")
+		return
+	}
+
+	writeJSON(w, model.SearchResponse{Query: query, Items: hits})
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"subscription-service/internal/model"
+)
+
+func TestSearchSubscriptions(t *testing.T) {
+	api := newTestAPI(t)
+	if w := api.do("POST", "/services", map[string]interface{}{
+		"name": "Yandex Plus", "aliases": []string{"Яндекс Плюс"}, "default_price": 299,
+	}); w.Code != http.StatusCreated {
+		t.Fatalf("POST /services: %d %s", w.Code, w.Body)
+	}
+	netflix := api.create(map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025"})
+	api.create(map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": otherUser, "start_date": "01-2025"})
+	api.create(map[string]interface{}{"service_name": "Spotify", "price": 200, "user_id": testUser, "start_date": "01-2025"})
+	plus := api.create(map[string]interface{}{"service_name": "Yandex Plus", "user_id": testUser, "start_date": "01-2025"})
+
+	search := func(query string) []*model.SearchHit {
+		t.Helper()
+		var got model.SearchResponse
+		api.get("/subscriptions/search?"+query, &got)
+		return got.Items
+	}
+
+	tests := []struct {
+		name, q, want, matched string
+	}{
+		{"кириллица", "нетфликс", netflix, "Netflix"},
+		{"опечатка", "Netflx", netflix, "Netflix"},
+		{"псевдоним", "плюс", plus, "яндекс плюс"},
+	}
+	for _, tt := range tests {
+		hits := search("user_id=" + testUser + "&q=" + url.QueryEscape(tt.q))
+		if len(hits) != 1 || hits[0].ID != tt.want || hits[0].Matched != tt.matched {
+			t.Errorf("%s: q=%q got %d hits %+v, want %s matched %q", tt.name, tt.q, len(hits), hits, tt.want, tt.matched)
+			continue
+		}
+		if hits[0].Score <= 0 || hits[0].Score > 1 {
+			t.Errorf("%s: score %v out of (0, 1]", tt.name, hits[0].Score)
+		}
+	}
+
+	// точное совпадение выше частичного, фильтры списка сохраняются
+	hits := search("q=netflix")
+	if len(hits) != 2 || hits[0].Score != 1 {
+		t.Errorf("q=netflix: got %+v, want both Netflix subscriptions with score 1", hits)
+	}
+	if hits := search("q=netflix&limit=1"); len(hits) != 1 {
+		t.Errorf("limit=1: got %d hits", len(hits))
+	}
+	if hits := search("q=dropbox"); len(hits) != 0 {
+		t.Errorf("q=dropbox: got %+v, want none", hits)
+	}
+
+	for _, query := range []string{"", "q=%20", "q=netflix&sort=price", "q=netflix&limit=0", "q=netflix&user_id=bob"} {
+		if w := api.do("GET", "/subscriptions/search?"+query, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", query, w.Code)
+		}
+	}
+}
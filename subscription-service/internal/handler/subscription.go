@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,33 +10,87 @@ import (
 	"strings"
 	"time"
 
+	"subscription-service/internal/logger"
+	"subscription-service/internal/metrics"
 	"subscription-service/internal/model"
 	"subscription-service/internal/service"
+	"subscription-service/internal/tracing"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 )
 
 type Handler struct {
-	svc service.SubscriptionService
-	log *zerolog.Logger
+	svc        service.SubscriptionService
+	log        *zerolog.Logger
+	adminToken string // токен /admin/*; пустой — административные эндпоинты отключены
 }
 
-func NewHandler(svc service.SubscriptionService, log *zerolog.Logger) *Handler {
-	return &Handler{svc: svc, log: log}
+// Option настраивает обработчики API
+type Option func(*Handler)
+
+// WithAdminToken задаёт токен, который административные эндпоинты ждут в Authorization: Bearer
+func WithAdminToken(token string) Option {
+	return func(h *Handler) {
+		h.adminToken = token
+	}
+}
+
+func NewHandler(svc service.SubscriptionService, log *zerolog.Logger, opts ...Option) *Handler {
+	h := &Handler{svc: svc, log: log}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
-func NewRouter(h *Handler, log *zerolog.Logger) http.Handler {
+// logger возвращает логгер запроса с его request_id
+func (h *Handler) logger(ctx context.Context) *zerolog.Logger {
+	return logger.FromContext(ctx, h.log)
+}
+
+func NewRouter(h *Handler, health *Health, m *metrics.Metrics, log *zerolog.Logger) http.Handler {
 	r := mux.NewRouter()
-	r.HandleFunc("/subscriptions", h.CreateSubscription).Methods("POST")
+	// спан запроса продолжает трассу из входящего traceparent и называется по шаблону маршрута;
+	// частые пробы и сбор метрик не трассируются
+	r.Use(otelmux.Middleware(tracing.ServiceName, otelmux.WithFilter(func(r *http.Request) bool {
+		switch r.URL.Path {
+		case "/healthz", "/readyz", "/metrics":
+			return false
+		}
+		return true
+	})), m.Middleware)
+	// обработчики ошибок роутера вызываются в обход r.Use
+	r.NotFoundHandler = m.Middleware(http.HandlerFunc(notFound))
+	r.MethodNotAllowedHandler = m.Middleware(http.HandlerFunc(methodNotAllowed))
+	r.HandleFunc("/healthz", health.Live).Methods("GET")
+	r.HandleFunc("/readyz", health.Ready).Methods("GET")
+	r.Handle("/metrics", m.Handler()).Methods("GET")
+	r.HandleFunc("/subscriptions", h.idempotent(h.CreateSubscription)).Methods("POST")
 	r.HandleFunc("/subscriptions", h.ListSubscriptions).Methods("GET")
 	r.HandleFunc("/subscriptions/aggregate", h.Aggregate).Methods("GET")
+	r.HandleFunc("/subscriptions/search", h.SearchSubscriptions).Methods("GET")
+	r.HandleFunc("/subscriptions/batch", h.idempotent(h.BatchSubscriptions)).Methods("POST")
+	r.HandleFunc("/subscriptions/import", h.ImportSubscriptions).Methods("POST")
 	r.HandleFunc("/subscriptions/{id}", h.GetSubscription).Methods("GET")
 	r.HandleFunc("/subscriptions/{id}", h.UpdateSubscription).Methods("PUT")
+	r.HandleFunc("/subscriptions/{id}", h.PatchSubscription).Methods("PATCH")
 	r.HandleFunc("/subscriptions/{id}", h.DeleteSubscription).Methods("DELETE")
-
-	return r
+	r.HandleFunc("/subscriptions/{id}/pause", h.PauseSubscription).Methods("POST")
+	r.HandleFunc("/subscriptions/{id}/resume", h.ResumeSubscription).Methods("POST")
+	r.HandleFunc("/subscriptions/{id}/cancel", h.CancelSubscription).Methods("POST")
+	r.HandleFunc("/subscriptions/{id}/prices", h.PriceHistory).Methods("GET")
+	r.HandleFunc("/subscriptions/{id}/prices", h.SchedulePriceChange).Methods("POST")
+	r.HandleFunc("/services", h.CreateService).Methods("POST")
+	r.HandleFunc("/services", h.ListServices).Methods("GET")
+	r.HandleFunc("/services/{id}", h.GetService).Methods("GET")
+	r.HandleFunc("/services/{id}", h.UpdateService).Methods("PUT")
+	r.HandleFunc("/services/{id}", h.DeleteService).Methods("DELETE")
+	r.HandleFunc("/admin/exchange-rates", h.requireAdmin(h.LoadExchangeRates)).Methods("PUT")
+
+	return chain(r, requestIDMiddleware(log), accessLog(log), recoverPanic(log))
 }
 
 func parseMonthYear(param string) (time.Time, error) {
@@ -46,74 +101,214 @@ func parseMonthYear(param string) (time.Time, error) {
 	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
 }
 
+// dateFormats — поддерживаемые форматы дат в запросах
+const dateFormats = "MM-YYYY, YYYY-MM-DD or RFC 3339"
+
+// parseDate разбирает дату в формате YYYY-MM-DD, RFC 3339 или устаревшем MM-YYYY.
+// Для MM-YYYY возвращает первый день месяца и monthOnly = true, чтобы вызывающий код
+// мог трактовать конец периода как последний день месяца.
+func parseDate(param string) (t time.Time, monthOnly bool, err error) {
+	if t, err := parseMonthYear(param); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse("2006-01-02", param); err == nil {
+		return t, false, nil
+	}
+	t, err = time.Parse(time.RFC3339, param)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	// время суток не храним: берём календарную дату в часовом поясе из строки
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), false, nil
+}
+
+// parseEndDate разбирает дату окончания периода: MM-YYYY означает «по конец месяца включительно»
+func parseEndDate(param string) (time.Time, error) {
+	t, monthOnly, err := parseDate(param)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if monthOnly {
+		t = t.AddDate(0, 1, -1)
+	}
+	return t, nil
+}
+
 func writeJSON(w http.ResponseWriter, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(v)
 }
 
-func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
-	var in struct {
-		ServiceName string  `json:"service_name"`
-		Price       int     `json:"price"`
-		UserID      string  `json:"user_id"`
-		StartDate   string  `json:"start_date"`
-		EndDate     *string `json:"end_date,omitempty"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
-		return
-	}
+// subscriptionInput — тело запроса на создание и обновление подписки
+type subscriptionInput struct {
+	ServiceID     *string `json:"service_id,omitempty"` // услуга каталога; заменяет service_name
+	ServiceName   string  `json:"service_name"`
+	Price         int     `json:"price"`
+	UserID        string  `json:"user_id"`
+	StartDate     string  `json:"start_date"`
+	EndDate       *string `json:"end_date,omitempty"`
+	BillingPeriod string  `json:"billing_period,omitempty"`
+	Currency      string  `json:"currency,omitempty"`
+	Status        string  `json:"status,omitempty"` // только при создании: trial или active
+	// пробный период: дата последнего бесплатного дня или его длительность в днях
+	TrialEnd  *string `json:"trial_end,omitempty"`
+	TrialDays *int    `json:"trial_days,omitempty"`
+	// категория по умолчанию берётся из каталога услуг
+	Category *string  `json:"category,omitempty"`
+	Tags     []string `json:"tags"`
+}
 
-	// basic validation
-	if strings.TrimSpace(in.ServiceName) == "" {
-		http.Error(w, "service_name is required", http.StatusBadRequest)
-		return
+// toModel валидирует входные данные и собирает из них подписку. Проверяются все поля
+// сразу: ошибка — validationErrors со списком ошибок по полям.
+func (in subscriptionInput) toModel() (*model.Subscription, error) {
+	var errs validationErrors
+	if in.ServiceID != nil {
+		if _, err := uuid.Parse(*in.ServiceID); err != nil {
+			errs.add("service_id", "service_id must be a valid UUID")
+		}
+	} else if strings.TrimSpace(in.ServiceName) == "" {
+		errs.add("service_name", "service_name or service_id is required")
 	}
 	if in.Price < 0 {
-		http.Error(w, "price must be >= 0", http.StatusBadRequest)
-		return
+		errs.add("price", "price must be >= 0")
 	}
 	if _, err := uuid.Parse(in.UserID); err != nil {
-		http.Error(w, "user_id must be a valid UUID", http.StatusBadRequest)
-		return
+		errs.add("user_id", "user_id must be a valid UUID")
 	}
 
-	start, err := parseMonthYear(in.StartDate)
-	if err != nil {
-		http.Error(w, "invalid start_date format, expected MM-YYYY", http.StatusBadRequest)
-		return
+	period := model.BillingMonthly
+	if in.BillingPeriod != "" {
+		period = model.BillingPeriod(strings.ToLower(in.BillingPeriod))
+		if !period.Valid() {
+			errs.add("billing_period", "billing_period must be one of weekly, monthly, quarterly, yearly")
+		}
+	}
+
+	status := model.Status(strings.ToLower(in.Status))
+	if status != "" && status != model.StatusTrial && status != model.StatusActive {
+		errs.add("status", "status must be trial or active; use pause, resume and cancel actions to change it")
+	}
+
+	currency := model.DefaultCurrency
+	if in.Currency != "" {
+		currency = strings.ToUpper(in.Currency)
+		if !model.ValidCurrency(currency) {
+			errs.add("currency", "currency must be an ISO 4217 code")
+		}
+	}
+
+	// сравнения дат проверяются, только если сами даты разобраны
+	start, _, err := parseDate(in.StartDate)
+	startOK := err == nil
+	if !startOK {
+		errs.add("start_date", "invalid start_date format, expected "+dateFormats)
 	}
 
 	var end *time.Time
 	if in.EndDate != nil && *in.EndDate != "" {
-		t, err := parseMonthYear(*in.EndDate)
+		t, err := parseEndDate(*in.EndDate)
+		switch {
+		case err != nil:
+			errs.add("end_date", "invalid end_date format, expected "+dateFormats)
+		case startOK && t.Before(start):
+			errs.add("end_date", "end_date must be the same or after start_date")
+		default:
+			end = &t
+		}
+	}
+
+	var trialEnd *time.Time
+	trialOK := true
+	if in.TrialEnd != nil && *in.TrialEnd != "" && in.TrialDays != nil {
+		errs.add("trial_days", "trial_end and trial_days are mutually exclusive")
+		trialOK = false
+	}
+	if trialOK && in.TrialEnd != nil && *in.TrialEnd != "" {
+		t, err := parseEndDate(*in.TrialEnd)
 		if err != nil {
-			http.Error(w, "invalid end_date format", http.StatusBadRequest)
-			return
+			errs.add("trial_end", "invalid trial_end format, expected "+dateFormats)
+		} else {
+			trialEnd = &t
 		}
-		if t.Before(start) {
-			http.Error(w, "end_date must be the same or after start_date", http.StatusBadRequest)
-			return
+	}
+	if trialOK && in.TrialDays != nil {
+		if *in.TrialDays <= 0 {
+			errs.add("trial_days", "trial_days must be > 0")
+		} else if startOK {
+			t := start.AddDate(0, 0, *in.TrialDays-1)
+			trialEnd = &t
+		}
+	}
+	if trialEnd != nil && startOK {
+		if trialEnd.Before(start) {
+			errs.add("trial_end", "trial_end must be the same or after start_date")
+		}
+		if end != nil && trialEnd.After(*end) {
+			errs.add("trial_end", "trial_end must be the same or before end_date")
 		}
-		end = &t
 	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	var category *string
+	if in.Category != nil && strings.TrimSpace(*in.Category) != "" {
+		c := strings.TrimSpace(*in.Category)
+		category = &c
+	}
+
+	return &model.Subscription{
+		ServiceID:     in.ServiceID,
+		ServiceName:   strings.TrimSpace(in.ServiceName),
+		Price:         in.Price,
+		UserID:        in.UserID,
+		StartDate:     start,
+		EndDate:       end,
+		BillingPeriod: period,
+		Currency:      currency,
+		Status:        status,
+		TrialEnd:      trialEnd,
+		Category:      category,
+		Tags:          normalizeTags(in.Tags),
+	}, nil
+}
+
+// normalizeTags убирает пустые метки, лишние пробелы и повторы, сохраняя порядок
+func normalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	return out
+}
 
-	sub := &model.Subscription{
-		ServiceName: in.ServiceName,
-		Price:       in.Price,
-		UserID:      in.UserID,
-		StartDate:   start,
-		EndDate:     end,
+func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var in subscriptionInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid json: "+err.Error())
+		return
+	}
+
+	sub, err := in.toModel()
+	if err != nil {
+		writeInvalid(w, r, err)
+		return
 	}
 
 	if err := h.svc.Create(r.Context(), sub); err != nil {
-		h.log.Error().Err(err).Msg("create subscription failed")
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		h.subscriptionError(w, r, err, "create subscription failed")
 		return
 	}
 
 	// set Location and return 201
 	w.Header().Set("Location", fmt.Sprintf("/subscriptions/%s", sub.ID))
+	w.Header().Set("ETag", etag(sub))
 	w.WriteHeader(http.StatusCreated)
 	writeJSON(w, sub)
 }
@@ -123,136 +318,144 @@ func (h *Handler) GetSubscription(w http.ResponseWriter, r *http.Request) {
 
 	sub, err := h.svc.GetByID(r.Context(), id)
 	if err != nil {
-		if errors.Is(err, service.ErrNotFound) {
-			http.Error(w, "not found", http.StatusNotFound)
-			return
-		}
-		h.log.Error().Err(err).Msg("get subscription failed")
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		h.writeError(w, r, err, "get subscription failed")
 		return
 	}
 
+	w.Header().Set("ETag", etag(sub))
 	writeJSON(w, sub)
 }
 
 func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Vary", "Accept")
 	q := r.URL.Query()
-	userID := q.Get("user_id")
-	serviceName := q.Get("service_name")
+	format, err := exportFormat(r)
+	if err != nil {
+		writeInvalid(w, r, err)
+		return
+	}
+	f, err := parseListFilter(q)
+	if err != nil {
+		writeInvalid(w, r, err)
+		return
+	}
 
-	limit := 50
+	limit := model.DefaultListLimit
 	if l := q.Get("limit"); l != "" {
-		if v, err := strconv.Atoi(l); err == nil {
-			limit = v
+		v, err := strconv.Atoi(l)
+		if err != nil || v < 1 || v > model.MaxListLimit {
+			writeInvalid(w, r, invalidField("limit", fmt.Sprintf("limit must be an integer from 1 to %d", model.MaxListLimit)))
+			return
 		}
+		limit = v
 	}
 
 	offset := 0
 	if o := q.Get("offset"); o != "" {
-		if v, err := strconv.Atoi(o); err == nil {
-			offset = v
+		v, err := strconv.Atoi(o)
+		if err != nil || v < 0 {
+			writeInvalid(w, r, invalidField("offset", "offset must be a non-negative integer"))
+			return
 		}
+		offset = v
 	}
 
-	subs, err := h.svc.List(r.Context(), userID, serviceName, limit, offset)
+	if c := q.Get("cursor"); c != "" {
+		if offset > 0 {
+			writeInvalid(w, r, invalidField("cursor", "cursor and offset cannot be used together"))
+			return
+		}
+		if len(f.Sort) > 0 {
+			writeInvalid(w, r, invalidField("cursor", "cursor is supported only with the default sort, use offset"))
+			return
+		}
+		cursor, err := model.DecodeListCursor(c)
+		if err != nil {
+			writeInvalid(w, r, invalidField("cursor", err.Error()))
+			return
+		}
+		f.Cursor = cursor
+	}
+
+	f.Limit = limit
+	f.Offset = offset
+
+	if format != formatJSON {
+		// выгрузка по умолчанию целиком: limit учитывается, только если задан явно
+		if q.Get("limit") == "" {
+			f.Limit = 0
+		}
+		h.exportSubscriptions(w, r, f, format)
+		return
+	}
+
+	page, err := h.svc.List(r.Context(), f)
 	if err != nil {
-		h.log.Error().Err(err).Msg("list subscriptions failed")
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		h.writeError(w, r, err, "list subscriptions failed")
 		return
 	}
 
-	writeJSON(w, subs)
+	writeJSON(w, page)
 }
 
 func (h *Handler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	var in struct {
-		ServiceName string  `json:"service_name"`
-		Price       int     `json:"price"`
-		UserID      string  `json:"user_id"`
-		StartDate   string  `json:"start_date"`
-		EndDate     *string `json:"end_date,omitempty"`
-	}
+	var in subscriptionInput
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeInvalidJSON, "invalid json: "+err.Error())
 		return
 	}
 
-	// basic validation
-	if strings.TrimSpace(in.ServiceName) == "" {
-		http.Error(w, "service_name is required", http.StatusBadRequest)
-		return
-	}
-	if in.Price < 0 {
-		http.Error(w, "price must be >= 0", http.StatusBadRequest)
-		return
-	}
-	if _, err := uuid.Parse(in.UserID); err != nil {
-		http.Error(w, "user_id must be a valid UUID", http.StatusBadRequest)
-		return
-	}
-
-	start, err := parseMonthYear(in.StartDate)
+	sub, err := in.toModel()
 	if err != nil {
-		http.Error(w, "invalid start_date format, expected MM-YYYY", http.StatusBadRequest)
+		writeInvalid(w, r, err)
 		return
 	}
+	sub.ID = id
 
-	var end *time.Time
-	if in.EndDate != nil && *in.EndDate != "" {
-		t, err := parseMonthYear(*in.EndDate)
-		if err != nil {
-			http.Error(w, "invalid end_date format", http.StatusBadRequest)
-			return
-		}
-		if t.Before(start) {
-			http.Error(w, "end_date must be the same or after start_date", http.StatusBadRequest)
-			return
-		}
-		end = &t
+	version, ok := h.expectedVersion(w, r, id)
+	if !ok {
+		return
 	}
+	sub.Version = version
 
-	sub := &model.Subscription{
-		ID:          id,
-		ServiceName: in.ServiceName,
-		Price:       in.Price,
-		UserID:      in.UserID,
-		StartDate:   start,
-		EndDate:     end,
-	}
+	h.saveSubscription(w, r, sub, http.StatusPreconditionFailed)
+}
 
+// saveSubscription сохраняет изменения подписки и отвечает её свежей версией из БД.
+// conflictStatus — ответ, если подписку успели изменить после чтения (sub.Version устарела).
+func (h *Handler) saveSubscription(w http.ResponseWriter, r *http.Request, sub *model.Subscription, conflictStatus int) {
 	if err := h.svc.Update(r.Context(), sub); err != nil {
-		if errors.Is(err, service.ErrNotFound) {
-			http.Error(w, "not found", http.StatusNotFound)
+		if errors.Is(err, service.ErrVersionConflict) {
+			writeProblem(w, r, conflictStatus, codeVersionConflict, "subscription was modified concurrently")
 			return
 		}
-		h.log.Error().Err(err).Msg("update subscription failed")
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		h.subscriptionError(w, r, err, "update subscription failed")
 		return
 	}
 
 	// return the fresh record from DB (with timestamps)
-	updated, err := h.svc.GetByID(r.Context(), id)
+	updated, err := h.svc.GetByID(r.Context(), sub.ID)
 	if err != nil {
-		h.log.Error().Err(err).Msg("fetch after update failed")
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		h.writeError(w, r, err, "fetch after update failed")
 		return
 	}
 
+	w.Header().Set("ETag", etag(updated))
 	writeJSON(w, updated)
 }
 
 func (h *Handler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	if err := h.svc.Delete(r.Context(), id); err != nil {
-		if errors.Is(err, service.ErrNotFound) {
-			http.Error(w, "not found", http.StatusNotFound)
-			return
-		}
-		h.log.Error().Err(err).Msg("delete subscription failed")
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	version, ok := h.expectedVersion(w, r, id)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), id, version); err != nil {
+		h.writeError(w, r, err, "delete subscription failed")
 		return
 	}
 
@@ -260,58 +463,173 @@ func (h *Handler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) Aggregate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Vary", "Accept")
 	q := r.URL.Query()
+	format, err := exportFormat(r)
+	if err != nil {
+		writeInvalid(w, r, err)
+		return
+	}
 	from := q.Get("from")
 	to := q.Get("to")
 	if from == "" || to == "" {
-		http.Error(w, "from and to are required (MM-YYYY)", http.StatusBadRequest)
+		writeInvalid(w, r, invalidField("from", "from and to are required ("+dateFormats+")"))
 		return
 	}
 
 	// validate format
-	fromDate, err := parseMonthYear(from)
+	fromDate, _, err := parseDate(from)
 	if err != nil {
-		http.Error(w, "invalid from format, expected MM-YYYY", http.StatusBadRequest)
+		writeInvalid(w, r, invalidField("from", "invalid from format, expected "+dateFormats))
 		return
 	}
-	toDate, err := parseMonthYear(to)
+	toDate, err := parseEndDate(to)
 	if err != nil {
-		http.Error(w, "invalid to format, expected MM-YYYY", http.StatusBadRequest)
+		writeInvalid(w, r, invalidField("to", "invalid to format, expected "+dateFormats))
 		return
 	}
 
 	// проверка from <= to
 	if fromDate.After(toDate) {
-		http.Error(w, "`from` must be less than or equal to `to`", http.StatusBadRequest)
+		writeInvalid(w, r, invalidField("from", "`from` must be less than or equal to `to`"))
 		return
 	}
 
-	var userID *string
+	f := model.AggregateFilter{
+		From:      fromDate,
+		To:        toDate,
+		Currency:  model.DefaultCurrency,
+		Proration: model.ProrationMonthly,
+	}
 	if v := q.Get("user_id"); v != "" {
-		userID = &v
+		f.UserID = &v
+	}
+	if v := q.Get("service_id"); v != "" {
+		if _, err := uuid.Parse(v); err != nil {
+			writeInvalid(w, r, invalidField("service_id", "service_id must be a valid UUID"))
+			return
+		}
+		f.ServiceID = &v
 	}
-	var serviceName *string
 	if v := q.Get("service_name"); v != "" {
-		serviceName = &v
+		f.ServiceName = &v
+	}
+	if v := q.Get("currency"); v != "" {
+		f.Currency = strings.ToUpper(v)
+		if !model.ValidCurrency(f.Currency) {
+			writeInvalid(w, r, invalidField("currency", "currency must be an ISO 4217 code"))
+			return
+		}
 	}
 
-	subs, total, err := h.svc.AggregateWithDetails(r.Context(), from, to, userID, serviceName)
+	if v := q.Get("proration"); v != "" {
+		f.Proration = model.Proration(v)
+		if !f.Proration.Valid() {
+			writeInvalid(w, r, invalidField("proration", "invalid proration, expected monthly or daily"))
+			return
+		}
+	}
+
+	groupBy := model.GroupBy(q.Get("group_by"))
+	if groupBy != "" && !groupBy.Valid() {
+		writeInvalid(w, r, invalidField("group_by", "invalid group_by, expected category, tag, service or user"))
+		return
+	}
+
+	switch mode := q.Get("mode"); mode {
+	case "", "summary":
+	case "monthly":
+		if groupBy != "" {
+			writeInvalid(w, r, invalidField("group_by", "group_by is supported only in summary mode"))
+			return
+		}
+		h.aggregateMonthly(w, r, from, to, f, format)
+		return
+	default:
+		writeInvalid(w, r, invalidField("mode", "invalid mode, expected summary or monthly"))
+		return
+	}
+
+	if format != formatJSON {
+		if groupBy != "" {
+			writeInvalid(w, r, invalidField("group_by", "group_by is supported only for JSON"))
+			return
+		}
+		h.exportAggregate(w, r, f, format)
+		return
+	}
+
+	subs, total, err := h.svc.AggregateWithDetails(r.Context(), f)
+	if err != nil {
+		h.writeError(w, r, err, "aggregate failed")
+		return
+	}
+	// независимая SQL-агрегация для сверки с суммой деталей
+	aggregateTotal, err := h.svc.Aggregate(r.Context(), f)
 	if err != nil {
-		h.log.Error().Err(err).Msg("aggregate failed")
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		h.writeError(w, r, err, "aggregate failed")
 		return
 	}
+	if aggregateTotal != total {
+		h.logger(r.Context()).Warn().Ctx(r.Context()).
+			Int64("total", total).Int64("aggregate_total", aggregateTotal).
+			Msg("aggregate total differs from details")
+	}
 
 	response := model.AggregateResponse{
-		From:          from,
-		To:            to,
-		Total:         total,
-		Subscriptions: subs,
+		From:           from,
+		To:             to,
+		Currency:       f.Currency,
+		Proration:      f.Proration,
+		Total:          total,
+		AggregateTotal: aggregateTotal,
+		Subscriptions:  subs,
+	}
+	if groupBy != "" {
+		response.GroupBy = groupBy
+		response.Groups = model.GroupTotals(subs, groupBy)
+	}
+
+	if f.UserID != nil {
+		response.UserID = *f.UserID
+	}
+
+	writeJSON(w, response)
+}
+
+// aggregateMonthly отдаёт помесячный ряд: итог и вклад каждого сервиса за каждый месяц окна
+func (h *Handler) aggregateMonthly(w http.ResponseWriter, r *http.Request, from, to string, f model.AggregateFilter, format string) {
+	months, total, err := h.svc.AggregateMonthly(r.Context(), f)
+	if err != nil {
+		h.writeError(w, r, err, "aggregate failed")
+		return
+	}
+	if format != formatJSON {
+		h.exportMonthly(w, r, months, format)
+		return
+	}
+
+	response := model.MonthlyAggregateResponse{
+		From:     from,
+		To:       to,
+		Currency: f.Currency,
+		Total:    total,
+		Months:   months,
 	}
 
-	if userID != nil {
-		response.UserID = *userID
+	if f.UserID != nil {
+		response.UserID = *f.UserID
 	}
 
 	writeJSON(w, response)
 }
+
+// subscriptionError отвечает на ошибку записи подписки: service_id, которого нет
+// в каталоге, — ошибка во входных данных, а не отсутствующий ресурс
+func (h *Handler) subscriptionError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	if errors.Is(err, service.ErrServiceNotFound) {
+		writeInvalid(w, r, invalidField("service_id", "unknown service_id"))
+		return
+	}
+	h.writeError(w, r, err, msg)
+}
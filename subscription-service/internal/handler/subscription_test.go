@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      time.Time
+		monthOnly bool
+		end       time.Time // результат parseEndDate
+	}{
+		{"02-2024", date(2024, 2, 1), true, date(2024, 2, 29)},
+		{"2024-02-10", date(2024, 2, 10), false, date(2024, 2, 10)},
+		{"2024-02-10T23:30:00+03:00", date(2024, 2, 10), false, date(2024, 2, 10)},
+	}
+	for _, tt := range tests {
+		got, monthOnly, err := parseDate(tt.in)
+		if err != nil || !got.Equal(tt.want) || monthOnly != tt.monthOnly {
+			t.Errorf("parseDate(%q) = %v, %v, %v; want %v, %v", tt.in, got, monthOnly, err, tt.want, tt.monthOnly)
+		}
+		end, err := parseEndDate(tt.in)
+		if err != nil || !end.Equal(tt.end) {
+			t.Errorf("parseEndDate(%q) = %v, %v; want %v", tt.in, end, err, tt.end)
+		}
+	}
+
+	for _, in := range []string{"", "13-2024", "2024-02-30", "10.02.2024"} {
+		if _, _, err := parseDate(in); err == nil {
+			t.Errorf("parseDate(%q): want error", in)
+		}
+	}
+}
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
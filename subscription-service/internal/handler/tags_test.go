@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"subscription-service/internal/model"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	got := normalizeTags([]string{" team-a", "", "infra", "team-a ", "  "})
+	if want := []string{"team-a", "infra"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeTags = %v, want %v", got, want)
+	}
+}
+
+func TestCategoriesAndTags(t *testing.T) {
+	api := newTestAPI(t)
+	if w := api.do("POST", "/services", map[string]interface{}{"name": "Netflix", "category": "streaming"}); w.Code != http.StatusCreated {
+		t.Fatalf("POST /services: %d %s", w.Code, w.Body)
+	}
+	// категория подписки Netflix берётся из каталога
+	api.create(map[string]interface{}{"service_name": "netflix", "price": 400, "user_id": testUser, "start_date": "01-2025"})
+	api.create(map[string]interface{}{"service_name": "Dropbox", "price": 300, "user_id": testUser, "start_date": "01-2025", "category": "cloud", "tags": []string{"team-a", "infra"}})
+	api.create(map[string]interface{}{"service_name": "Slack", "price": 200, "user_id": testUser, "start_date": "01-2025", "tags": []string{"team-a"}})
+
+	lists := map[string][]string{
+		"category=streaming":     {"Netflix"},
+		"tag=team-a":             {"Dropbox", "Slack"},
+		"tag=team-a&tag=infra":   {"Dropbox"},
+		"category=cloud&tag=ops": nil,
+	}
+	for query, want := range lists {
+		subs := api.list("/subscriptions?" + query)
+		var names []string
+		for _, s := range subs {
+			names = append(names, s.ServiceName)
+		}
+		if len(names) != len(want) {
+			t.Errorf("%s: got %v, want %v", query, names, want)
+			continue
+		}
+		for _, name := range want {
+			found := false
+			for _, n := range names {
+				found = found || n == name
+			}
+			if !found {
+				t.Errorf("%s: got %v, want %v", query, names, want)
+			}
+		}
+	}
+
+	str := func(s string) *string { return &s }
+	groups := map[model.GroupBy][]model.GroupTotal{
+		model.GroupByCategory: {{Key: str("streaming"), Subscriptions: 1, Total: 400}, {Key: str("cloud"), Subscriptions: 1, Total: 300}, {Key: nil, Subscriptions: 1, Total: 200}},
+		model.GroupByTag:      {{Key: str("team-a"), Subscriptions: 2, Total: 500}, {Key: nil, Subscriptions: 1, Total: 400}, {Key: str("infra"), Subscriptions: 1, Total: 300}},
+	}
+	for by, want := range groups {
+		var got model.AggregateResponse
+		api.get("/subscriptions/aggregate?from=01-2025&to=01-2025&group_by="+string(by), &got)
+		if got.GroupBy != by || !reflect.DeepEqual(got.Groups, want) {
+			t.Errorf("group_by=%s: %+v", by, got.Groups)
+		}
+		if got.Total != 900 {
+			t.Errorf("group_by=%s: total %d, want 900", by, got.Total)
+		}
+	}
+
+	for _, query := range []string{"group_by=day", "mode=monthly&group_by=tag"} {
+		if w := api.do("GET", "/subscriptions/aggregate?from=01-2025&to=01-2025&"+query, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRequestTracing(t *testing.T) {
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	api := newTestAPI(t)
+	id := api.create(map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025"})
+	sr.Reset()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	r := httptest.NewRequest("GET", "/subscriptions/"+id, nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	api.router.ServeHTTP(httptest.NewRecorder(), r)
+	api.do("GET", "/healthz", nil)
+	api.do("GET", "/metrics", nil)
+
+	// запрос продолжает входящую трассу, спаны сервиса и репозитория вложены в него
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range sr.Ended() {
+		if s.SpanContext().TraceID().String() != traceID {
+			t.Errorf("span %s is outside of the incoming trace", s.Name())
+		}
+		byName[s.Name()] = s
+	}
+	req, svc, repo := byName["/subscriptions/{id}"], byName["SubscriptionService.GetByID"], byName["db.GetByID"]
+	if req == nil || svc == nil || repo == nil {
+		t.Fatalf("missing spans, got %v", byName)
+	}
+	if svc.Parent().SpanID() != req.SpanContext().SpanID() || repo.Parent().SpanID() != svc.SpanContext().SpanID() {
+		t.Error("service and repository spans are not nested in the request span")
+	}
+	for _, name := range []string{"/healthz", "/metrics", "SubscriptionService.Stats", "db.Stats"} {
+		if _, ok := byName[name]; ok {
+			t.Errorf("%s is traced", name)
+		}
+	}
+}
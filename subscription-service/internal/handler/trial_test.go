@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+func TestAggregateSkipsTrialDays(t *testing.T) {
+	api := newTestAPI(t)
+	api.create(map[string]interface{}{"service_name": "Netflix", "price": 310, "user_id": testUser, "start_date": "2025-01-01", "trial_days": 14})
+	api.create(map[string]interface{}{"service_name": "Spotify", "price": 200, "user_id": testUser, "start_date": "2025-01-01", "trial_end": "01-2025"})
+	// списания 15, 22 и 29 января
+	api.create(map[string]interface{}{"service_name": "Weekly", "price": 100, "user_id": testUser, "start_date": "2025-01-01", "trial_end": "2025-01-14", "billing_period": "weekly"})
+
+	tests := []struct {
+		query string
+		costs map[string]int64
+	}{
+		// месяц с оплачиваемыми днями списывается целиком, месяц целиком в пробном периоде бесплатен
+		{"from=01-2025&to=01-2025", map[string]int64{"Netflix": 310, "Spotify": 0, "Weekly": 300}},
+		{"from=01-2025&to=02-2025", map[string]int64{"Netflix": 620, "Spotify": 200, "Weekly": 700}},
+		// 310 × 17/31 и 433.33 × 17/31
+		{"from=01-2025&to=01-2025&proration=daily", map[string]int64{"Netflix": 170, "Spotify": 0, "Weekly": 238}},
+	}
+	for _, tt := range tests {
+		var got model.AggregateResponse
+		api.get("/subscriptions/aggregate?"+tt.query, &got)
+		for _, s := range got.Subscriptions {
+			if want := tt.costs[s.ServiceName]; s.Cost != want {
+				t.Errorf("%s: %s cost = %d, want %d", tt.query, s.ServiceName, s.Cost, want)
+			}
+		}
+	}
+}
+
+func TestListTrialEndsWithin(t *testing.T) {
+	api := newTestAPI(t)
+	today := time.Now().Format("2006-01-02")
+	soon := api.create(map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": today, "trial_days": 3})
+	api.create(map[string]interface{}{"service_name": "Spotify", "price": 200, "user_id": testUser, "start_date": today, "trial_days": 30})
+	api.create(map[string]interface{}{"service_name": "Yandex", "price": 300, "user_id": testUser, "start_date": today})
+
+	subs := api.list("/subscriptions?trial_ends_within=7")
+	if len(subs) != 1 || subs[0].ID != soon {
+		t.Errorf("trial_ends_within=7: got %+v, want only %s", subs, soon)
+	}
+	if w := api.do("GET", "/subscriptions?trial_ends_within=-1", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("trial_ends_within=-1: status %d, want 400", w.Code)
+	}
+}
+
+func TestCreateInvalidTrial(t *testing.T) {
+	api := newTestAPI(t)
+	for _, trial := range []map[string]interface{}{
+		{"trial_end": "2025-01-10", "trial_days": 10},
+		{"trial_days": 0},
+		{"trial_end": "2024-12-31"},
+		{"trial_end": "2025-03-01", "end_date": "02-2025"},
+	} {
+		body := map[string]interface{}{"service_name": "Netflix", "price": 400, "user_id": testUser, "start_date": "01-2025"}
+		for k, v := range trial {
+			body[k] = v
+		}
+		if w := api.do("POST", "/subscriptions", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%v: status %d, want 400", trial, w.Code)
+		}
+	}
+}
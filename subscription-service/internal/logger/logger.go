@@ -1,17 +1,19 @@
 package logger
 
 import (
+	"context"
 	"io"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func New(level string) *zerolog.Logger {
 	out := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
-	l := zerolog.New(out).With().Timestamp().Logger()
+	l := zerolog.New(out).With().Timestamp().Logger().Hook(traceHook{})
 
 	switch strings.ToLower(level) {
 	case "debug":
@@ -34,7 +36,7 @@ func New(level string) *zerolog.Logger {
 // For tests or redirecting logs one can use NewWithWriter
 func NewWithWriter(level string, w io.Writer) *zerolog.Logger {
 	out := zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339}
-	l := zerolog.New(out).With().Timestamp().Logger()
+	l := zerolog.New(out).With().Timestamp().Logger().Hook(traceHook{})
 	switch strings.ToLower(level) {
 	case "debug":
 		l = l.Level(zerolog.DebugLevel)
@@ -50,3 +52,24 @@ func NewWithWriter(level string, w io.Writer) *zerolog.Logger {
 	logger := l
 	return &logger
 }
+
+// FromContext возвращает логгер, привязанный к ctx через zerolog.Logger.WithContext
+// (например, логгер HTTP-запроса с его request_id), или fallback, если его нет
+func FromContext(ctx context.Context, fallback *zerolog.Logger) *zerolog.Logger {
+	if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+	return fallback
+}
+
+// traceHook добавляет trace_id и span_id в события, у которых задан контекст (Event.Ctx)
+// с активным спаном OpenTelemetry, чтобы по строке лога можно было найти трассу
+type traceHook struct{}
+
+func (traceHook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	sc := trace.SpanContextFromContext(e.GetCtx())
+	if !sc.IsValid() {
+		return
+	}
+	e.Str("trace_id", sc.TraceID().String()).Str("span_id", sc.SpanID().String())
+}
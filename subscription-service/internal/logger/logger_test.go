@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceHook(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewWithWriter("info", &buf)
+
+	_, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	ctx := context.Background()
+	log.Info().Ctx(ctx).Msg("without span")
+	log.Info().Ctx(trace.ContextWithSpan(ctx, span)).Msg("with span")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines:\n%s", len(lines), buf.String())
+	}
+	if strings.Contains(lines[0], "trace_id") {
+		t.Errorf("event without span has trace_id: %s", lines[0])
+	}
+	sc := span.SpanContext()
+	if !strings.Contains(lines[1], sc.TraceID().String()) || !strings.Contains(lines[1], sc.SpanID().String()) {
+		t.Errorf("event with span has no trace ids: %s", lines[1])
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	fallback := zerolog.Nop()
+	if got := FromContext(context.Background(), &fallback); got != &fallback {
+		t.Error("context without logger: want fallback")
+	}
+
+	var buf bytes.Buffer
+	l := zerolog.New(&buf).With().Str("request_id", "req-1").Logger()
+	FromContext(l.WithContext(context.Background()), &fallback).Info().Msg("handled")
+	if !strings.Contains(buf.String(), `"request_id":"req-1"`) {
+		t.Errorf("logger from context is not used: %q", buf.String())
+	}
+}
@@ -0,0 +1,211 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"subscription-service/internal/model"
+
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+)
+
+const namespace = "subscription_service"
+
+// statsTimeout ограничивает запрос бизнес-метрик при сборе: медленная БД не должна
+// подвешивать /metrics дольше таймаута Prometheus
+const statsTimeout = 5 * time.Second
+
+// statsCacheTTL — сколько переиспользуется посчитанная сводка: несколько Prometheus
+// или частый сбор не должны каждый раз сканировать таблицу подписок
+const statsCacheTTL = 15 * time.Second
+
+// Metrics — метрики сервиса в собственном реестре Prometheus
+type Metrics struct {
+	registry     *prometheus.Registry
+	log          *zerolog.Logger
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	dbDuration   *prometheus.HistogramVec
+}
+
+func New(log *zerolog.Logger) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		log:      log,
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route template, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route template and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Repository call latency by method and outcome.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"query", "outcome"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.dbDuration,
+	)
+	return m
+}
+
+// Handler отдаёт метрики в текстовом формате Prometheus. Ошибка одного сборщика
+// не мешает отдать остальные метрики.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorLog:      promLogger{m.log},
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// Middleware считает запросы и их длительность по шаблону маршрута (/subscriptions/{id}),
+// а не по пути, чтобы идентификаторы не плодили временные ряды. Запросы мимо маршрутов
+// попадают в route="unmatched".
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		route := "unmatched"
+		if cur := mux.CurrentRoute(r); cur != nil {
+			if tpl, err := cur.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		m.httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(sw.status)).Inc()
+		m.httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// QueryHook измеряет длительность методов репозитория. sql.ErrNoRows — обычный
+// ответ «не найдено», а не сбой БД, поэтому считается успешным.
+func (m *Metrics) QueryHook(ctx context.Context, query string) (context.Context, func(err error)) {
+	start := time.Now()
+	return ctx, func(err error) {
+		outcome := "ok"
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			outcome = "error"
+		}
+		m.dbDuration.WithLabelValues(query, outcome).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDB добавляет статистику пула соединений: открытые, занятые, ожидания
+func (m *Metrics) RegisterDB(conn *sqlx.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(conn.DB, "subscriptions"))
+}
+
+// RegisterStats добавляет бизнес-метрики, которые считаются через stats не чаще раза
+// в statsCacheTTL. stats не должен идти через инструментированный репозиторий, иначе
+// каждый сбор попадёт в метрики запросов к БД и в трассы.
+func (m *Metrics) RegisterStats(stats func(ctx context.Context) (*model.Stats, error)) {
+	m.registry.MustRegister(&statsCollector{
+		stats: stats,
+		subscriptions: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "subscriptions"),
+			"Subscriptions by effective status.",
+			[]string{"status"}, nil,
+		),
+		spend: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "monthly_spend"),
+			"Monthly cost of subscriptions active today, by currency.",
+			[]string{"currency"}, nil,
+		),
+	})
+}
+
+type statsCollector struct {
+	stats         func(ctx context.Context) (*model.Stats, error)
+	subscriptions *prometheus.Desc
+	spend         *prometheus.Desc
+
+	mu       sync.Mutex
+	cached   *model.Stats
+	cachedAt time.Time
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.subscriptions
+	ch <- c.spend
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := c.load()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.subscriptions, err)
+		return
+	}
+	for _, s := range stats.ByStatus {
+		ch <- prometheus.MustNewConstMetric(c.subscriptions, prometheus.GaugeValue, float64(s.Count), string(s.Status))
+	}
+	for _, s := range stats.MonthlySpend {
+		ch <- prometheus.MustNewConstMetric(c.spend, prometheus.GaugeValue, float64(s.Monthly), s.Currency)
+	}
+}
+
+// load возвращает сводку из кэша или считает её заново; ошибка не кэшируется
+func (c *statsCollector) load() (*model.Stats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cached != nil && time.Since(c.cachedAt) < statsCacheTTL {
+		return c.cached, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+	stats, err := c.stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.cached, c.cachedAt = stats, time.Now()
+	return stats, nil
+}
+
+// statusWriter запоминает код ответа для метрик
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap даёт http.ResponseController доступ к Flush исходного writer: экспорт
+// отдаёт данные потоком
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// promLogger пишет ошибки сбора метрик в zerolog
+type promLogger struct {
+	log *zerolog.Logger
+}
+
+func (l promLogger) Println(v ...interface{}) {
+	l.log.Error().Msg(fmt.Sprint(v...))
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"subscription-service/internal/model"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("scrape: %d %s", w.Code, w.Body)
+	}
+	return w.Body.String()
+}
+
+func assertMetrics(t *testing.T, body string, want ...string) {
+	t.Helper()
+	for _, line := range want {
+		if !strings.Contains(body, line) {
+			t.Errorf("metrics have no %s", line)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	log := zerolog.Nop()
+	m := New(&log)
+	r := mux.NewRouter()
+	r.Use(m.Middleware)
+	r.HandleFunc("/subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+	r.NotFoundHandler = m.Middleware(http.NotFoundHandler())
+
+	for _, path := range []string{"/subscriptions/1", "/subscriptions/2", "/healthz", "/other"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	body := scrape(t, m)
+	assertMetrics(t, body,
+		`subscription_service_http_requests_total{method="GET",route="/subscriptions/{id}",status="404"} 2`,
+		`subscription_service_http_requests_total{method="GET",route="/healthz",status="200"} 1`,
+		`subscription_service_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`subscription_service_http_request_duration_seconds_count{method="GET",route="/subscriptions/{id}"} 2`,
+	)
+}
+
+func TestQueryHook(t *testing.T) {
+	log := zerolog.Nop()
+	m := New(&log)
+	for _, err := range []error{nil, sql.ErrNoRows, errors.New("connection reset")} {
+		_, done := m.QueryHook(context.Background(), "GetByID")
+		done(err)
+	}
+	assertMetrics(t, scrape(t, m),
+		`subscription_service_db_query_duration_seconds_count{outcome="ok",query="GetByID"} 2`,
+		`subscription_service_db_query_duration_seconds_count{outcome="error",query="GetByID"} 1`,
+	)
+}
+
+func TestRegisterStats(t *testing.T) {
+	log := zerolog.Nop()
+	m := New(&log)
+	calls := 0
+	statsErr := errors.New("db is down")
+	m.RegisterStats(func(ctx context.Context) (*model.Stats, error) {
+		calls++
+		if statsErr != nil {
+			return nil, statsErr
+		}
+		return &model.Stats{
+			ByStatus:     []model.StatusCount{{Status: model.StatusActive, Count: 3}, {Status: model.StatusPaused, Count: 1}},
+			MonthlySpend: []model.CurrencySpend{{Currency: "RUB", Monthly: 1500}},
+		}, nil
+	})
+
+	// ошибка бизнес-метрик не мешает отдать остальные и не кэшируется
+	body := scrape(t, m)
+	if strings.Contains(body, "subscription_service_subscriptions{") {
+		t.Error("stats are exported after stats error")
+	}
+	assertMetrics(t, body, "go_goroutines")
+
+	statsErr = nil
+	assertMetrics(t, scrape(t, m),
+		`subscription_service_subscriptions{status="active"} 3`,
+		`subscription_service_subscriptions{status="paused"} 1`,
+		`subscription_service_monthly_spend{currency="RUB"} 1500`,
+	)
+	// повторный сбор в пределах statsCacheTTL берёт сводку из кэша
+	assertMetrics(t, scrape(t, m), `subscription_service_subscriptions{status="active"} 3`)
+	if calls != 2 {
+		t.Errorf("stats called %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// CatalogService — услуга из каталога. Подписки ссылаются на неё, а название подписки
+// всегда совпадает с каноническим названием услуги.
+type CatalogService struct {
+	ID           string         `db:"id" json:"id"`
+	Name         string         `db:"name" json:"name"`
+	Aliases      pq.StringArray `db:"aliases" json:"aliases"` // другие написания названия
+	Category     *string        `db:"category" json:"category,omitempty"`
+	DefaultPrice *int           `db:"default_price" json:"default_price,omitempty"`
+	Currency     string         `db:"currency" json:"currency"` // валюта цены по умолчанию
+}
+
+// NormalizeAlias приводит название к виду, в котором хранятся и ищутся псевдонимы
+func NormalizeAlias(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
@@ -0,0 +1,17 @@
+package model
+
+import "testing"
+
+func TestNormalizeAlias(t *testing.T) {
+	tests := map[string]string{
+		"Netflix":           "netflix",
+		"  Yandex   Plus\t": "yandex plus",
+		"ЯНДЕКС Плюс":       "яндекс плюс",
+		"":                  "",
+	}
+	for in, want := range tests {
+		if got := NormalizeAlias(in); got != want {
+			t.Errorf("NormalizeAlias(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package model
+
+// Статусы проверок здоровья
+const (
+	HealthOK   = "ok"
+	HealthFail = "fail"
+)
+
+// HealthCheck — результат проверки одной зависимости
+type HealthCheck struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthResponse — ответ /healthz и /readyz; Checks пуст у проверки живости
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
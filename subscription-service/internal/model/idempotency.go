@@ -0,0 +1,42 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// IdempotencyRecord — запрос с Idempotency-Key и сохранённый ответ на него.
+// StatusCode == nil — запрос ещё выполняется.
+type IdempotencyRecord struct {
+	Scope       string          `db:"scope"` // метод и путь запроса
+	Key         string          `db:"key"`
+	RequestHash string          `db:"request_hash"` // отпечаток тела: повтор должен совпадать с оригиналом
+	StatusCode  *int            `db:"status_code"`
+	Headers     ResponseHeaders `db:"response_headers"`
+	Body        []byte          `db:"response_body"`
+}
+
+// ResponseHeaders — заголовки сохранённого ответа, хранятся в JSONB
+type ResponseHeaders map[string]string
+
+func (h ResponseHeaders) Value() (driver.Value, error) {
+	if h == nil {
+		return nil, nil
+	}
+	return json.Marshal(h)
+}
+
+func (h *ResponseHeaders) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*h = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, h)
+	case string:
+		return json.Unmarshal([]byte(v), h)
+	default:
+		return fmt.Errorf("unsupported type %T for ResponseHeaders", src)
+	}
+}
@@ -0,0 +1,16 @@
+package model
+
+// SearchHit — подписка, найденная поиском по названию услуги
+type SearchHit struct {
+	Subscription
+	// Matched — название или псевдоним услуги из каталога, совпавший с запросом
+	Matched string `db:"matched" json:"matched"`
+	// Score — близость к запросу от 0 до 1; выдача упорядочена по её убыванию
+	Score float64 `db:"score" json:"score"`
+}
+
+// SearchResponse — результат поиска подписок
+type SearchResponse struct {
+	Query string       `json:"query"`
+	Items []*SearchHit `json:"items"`
+}
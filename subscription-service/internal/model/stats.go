@@ -0,0 +1,19 @@
+package model
+
+// StatusCount — число подписок в статусе (с учётом дат, как в EffectiveStatus)
+type StatusCount struct {
+	Status Status `db:"status"`
+	Count  int64  `db:"count"`
+}
+
+// CurrencySpend — сумма стоимостей в месяц действующих сегодня подписок в одной валюте
+type CurrencySpend struct {
+	Currency string `db:"currency"`
+	Monthly  int64  `db:"monthly"`
+}
+
+// Stats — сводка по всем подпискам на текущую дату для метрик
+type Stats struct {
+	ByStatus     []StatusCount
+	MonthlySpend []CurrencySpend
+}
@@ -1,14 +1,323 @@
 package model
 
-import "time"
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultCurrency — валюта подписок и итогов, если она не указана явно
+const DefaultCurrency = "RUB"
+
+var currencyRe = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidCurrency проверяет, что код похож на код валюты ISO 4217 (три заглавные латинские буквы)
+func ValidCurrency(code string) bool {
+	return currencyRe.MatchString(code)
+}
+
+// BillingPeriod — периодичность списания цены подписки
+type BillingPeriod string
+
+const (
+	BillingWeekly    BillingPeriod = "weekly"
+	BillingMonthly   BillingPeriod = "monthly"
+	BillingQuarterly BillingPeriod = "quarterly"
+	BillingYearly    BillingPeriod = "yearly"
+)
+
+// periodsPerYear — сколько раз в год списывается цена
+var periodsPerYear = map[BillingPeriod]int{
+	BillingWeekly:    52,
+	BillingMonthly:   12,
+	BillingQuarterly: 4,
+	BillingYearly:    1,
+}
+
+func (p BillingPeriod) Valid() bool {
+	_, ok := periodsPerYear[p]
+	return ok
+}
+
+// MonthlyEquivalent приводит цену за период к стоимости в месяц (с округлением)
+func (p BillingPeriod) MonthlyEquivalent(price int) int {
+	n, ok := periodsPerYear[p]
+	if !ok {
+		return price
+	}
+	return int(math.Round(float64(price) * float64(n) / 12))
+}
+
+// Status — этап жизненного цикла подписки
+type Status string
+
+const (
+	StatusTrial     Status = "trial"
+	StatusActive    Status = "active"
+	StatusPaused    Status = "paused"
+	StatusCancelled Status = "cancelled"
+	StatusExpired   Status = "expired"
+)
+
+// transitions — из каких статусов допустим переход в целевой
+var transitions = map[Status][]Status{
+	StatusPaused:    {StatusTrial, StatusActive},
+	StatusActive:    {StatusPaused},
+	StatusCancelled: {StatusTrial, StatusActive, StatusPaused},
+}
+
+// CanTransition сообщает, можно ли перевести подписку из статуса from в статус to
+func CanTransition(from, to Status) bool {
+	for _, s := range transitions[to] {
+		if s == from {
+			return true
+		}
+	}
+	return false
+}
 
 type Subscription struct {
-	ID          string     `db:"id" json:"id"`
-	ServiceName string     `db:"service_name" json:"service_name"`
-	Price       int        `db:"price" json:"price"`
-	UserID      string     `db:"user_id" json:"user_id"`
-	StartDate   time.Time  `db:"start_date" json:"start_date"`
-	EndDate     *time.Time `db:"end_date" json:"end_date,omitempty"`
+	ID            string         `db:"id" json:"id"`
+	ServiceID     *string        `db:"service_id" json:"service_id,omitempty"` // услуга из каталога
+	ServiceName   string         `db:"service_name" json:"service_name"`
+	Price         int            `db:"price" json:"price"`
+	UserID        string         `db:"user_id" json:"user_id"`
+	StartDate     time.Time      `db:"start_date" json:"start_date"`
+	EndDate       *time.Time     `db:"end_date" json:"end_date,omitempty"`
+	BillingPeriod BillingPeriod  `db:"billing_period" json:"billing_period"`
+	Currency      string         `db:"currency" json:"currency"`
+	Status        Status         `db:"status" json:"status"`
+	TrialEnd      *time.Time     `db:"trial_end" json:"trial_end,omitempty"` // последний бесплатный день
+	Category      *string        `db:"category" json:"category,omitempty"`   // streaming, cloud, productivity...
+	Tags          pq.StringArray `db:"tags" json:"tags"`                     // произвольные метки: команда, центр затрат
+	MonthlyCost   int            `db:"-" json:"monthly_cost"`                // цена, приведённая к месяцу
+	// Version растёт при каждом изменении; при обновлении — ожидаемая версия (0 — любая)
+	Version   int       `db:"version" json:"version"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// EffectiveStatus возвращает статус с учётом дат: подписка, закончившаяся раньше today
+// и не отменённая явно, считается истёкшей, а пробная — активной после конца пробного периода
+func (s *Subscription) EffectiveStatus(today time.Time) Status {
+	if s.Status != StatusCancelled && s.EndDate != nil && s.EndDate.Before(today) {
+		return StatusExpired
+	}
+	if s.Status == StatusTrial && s.TrialEnd != nil && s.TrialEnd.Before(today) {
+		return StatusActive
+	}
+	return s.Status
+}
+
+// PriceChange — цена подписки, действующая с месяца EffectiveMonth
+type PriceChange struct {
+	SubscriptionID string    `db:"subscription_id" json:"subscription_id"`
+	EffectiveMonth time.Time `db:"effective_month" json:"effective_month"`
+	Price          int       `db:"price" json:"price"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+}
+
+// Pause — интервал приостановки подписки: с PausedAt включительно до ResumedAt
+type Pause struct {
+	ID             string     `db:"id" json:"id"`
+	SubscriptionID string     `db:"subscription_id" json:"subscription_id"`
+	PausedAt       time.Time  `db:"paused_at" json:"paused_at"`
+	ResumedAt      *time.Time `db:"resumed_at" json:"resumed_at,omitempty"`
+}
+
+// ListFilter — параметры выборки списка подписок
+type ListFilter struct {
+	UserIDs     []string // подписки любого из пользователей
+	ServiceID   string   // услуга каталога; ServiceName, найденное в каталоге, превращается в неё
+	ServiceName string
+	Category    string
+	Tags        []string // подписка должна иметь все перечисленные метки
+	// TrialEndsWithin — только подписки, чей пробный период закончится в ближайшие N дней
+	TrialEndsWithin *int
+	// PriceMin и PriceMax — границы текущей цены включительно
+	PriceMin *int
+	PriceMax *int
+	// Диапазоны дат начала и окончания включительно; подписка без даты окончания
+	// в диапазон EndFrom–EndTo не попадает
+	StartFrom *time.Time
+	StartTo   *time.Time
+	EndFrom   *time.Time
+	EndTo     *time.Time
+	// ActiveAt — подписка началась не позже этой даты и не закончилась раньше неё
+	ActiveAt *time.Time
+	// NoEndDate — true: только бессрочные подписки, false: только с датой окончания
+	NoEndDate *bool
+	// Sort — порядок выдачи; пустой — по (start_date, id) по убыванию
+	Sort   []SortField
+	Limit  int
+	Offset int
+	// Cursor — продолжить выдачу после этой подписки (вместо Offset); только с порядком по умолчанию
+	Cursor *ListCursor
+}
+
+// SortFields — поля, по которым можно сортировать список подписок
+var SortFields = []string{"start_date", "end_date", "price", "service_name", "user_id", "status", "category", "updated_at", "id"}
+
+// SortField — поле сортировки и направление
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort разбирает параметр сортировки вида "price,-start_date": поля через запятую,
+// минус перед полем — по убыванию. Допустимы только поля из SortFields, каждое один раз.
+func ParseSort(s string) ([]SortField, error) {
+	var fields []SortField
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		f := SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			f = SortField{Field: part[1:], Desc: true}
+		} else if strings.HasPrefix(part, "+") {
+			f.Field = part[1:]
+		}
+		if !isSortField(f.Field) {
+			return nil, fmt.Errorf("invalid sort field %q, expected one of %s", f.Field, strings.Join(SortFields, ", "))
+		}
+		if seen[f.Field] {
+			return nil, fmt.Errorf("duplicate sort field %q", f.Field)
+		}
+		seen[f.Field] = true
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func isSortField(field string) bool {
+	for _, f := range SortFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Размер страницы списка подписок: по умолчанию и наибольший допустимый
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 1000
+)
+
+// ListCursor — позиция в списке подписок: список упорядочен по (start_date, id) по убыванию,
+// и следующая страница начинается строго после этой пары
+type ListCursor struct {
+	StartDate time.Time `json:"s"`
+	ID        string    `json:"id"`
+}
+
+// ErrInvalidCursor — курсор не выдан этим сервисом или повреждён
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorAfter возвращает курсор, указывающий на подписку sub
+func CursorAfter(sub *Subscription) *ListCursor {
+	return &ListCursor{StartDate: sub.StartDate, ID: sub.ID}
+}
+
+// Encode превращает курсор в непрозрачную строку для клиента
+func (c ListCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeListCursor разбирает строку, полученную из Encode
+func DecodeListCursor(s string) (*ListCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c ListCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" || c.StartDate.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// SubscriptionPage — страница списка подписок
+type SubscriptionPage struct {
+	Items []*Subscription `json:"items"`
+	// NextCursor — курсор следующей страницы; nil, если это последняя
+	NextCursor *string `json:"next_cursor"`
+	Total      int64   `json:"total"` // всего подписок по фильтру
+}
+
+// Proration — как считать месяцы, в которые подписка активна не целиком
+type Proration string
+
+const (
+	// ProrationMonthly — месяц с хотя бы одним активным днём учитывается целиком,
+	// цена списывается по периоду оплаты
+	ProrationMonthly Proration = "monthly"
+	// ProrationDaily — цена, приведённая к месяцу, делится пропорционально активным дням
+	ProrationDaily Proration = "daily"
+)
+
+func (p Proration) Valid() bool {
+	return p == ProrationMonthly || p == ProrationDaily
+}
+
+// AggregateFilter — параметры агрегации стоимости подписок
+type AggregateFilter struct {
+	From        time.Time // первый день окна
+	To          time.Time // последний день окна, включительно
+	UserID      *string
+	ServiceID   *string // услуга каталога; ServiceName, найденное в каталоге, превращается в неё
+	ServiceName *string
+	Currency    string // валюта итогов
+	Proration   Proration
+}
+
+// ExchangeRate — курс валюты на месяц: 1 Base = Rate Quote
+type ExchangeRate struct {
+	Base  string    `db:"base_currency" json:"base"`
+	Quote string    `db:"quote_currency" json:"quote"`
+	Month time.Time `db:"month" json:"month"`
+	Rate  float64   `db:"rate" json:"rate"`
+}
+
+// MonthRate — курс, по которому пересчитан один месяц подписки
+type MonthRate struct {
+	Month string  `json:"month"` // MM-YYYY
+	Rate  float64 `json:"rate"`
+}
+
+// MonthRates читается из json-агрегата в SQL
+type MonthRates []MonthRate
+
+func (r *MonthRates) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("unsupported type %T for MonthRates", src)
+	}
+}
+
+// SubscriptionUsage — подписка вместе с её активностью внутри окна агрегации
+type SubscriptionUsage struct {
+	Subscription
+	Months        int        `db:"months"`         // число активных месяцев в окне
+	Days          int        `db:"days"`           // число активных дней в окне
+	Cost          int64      `db:"cost"`           // стоимость списаний внутри окна в валюте подписки
+	ConvertedCost int64      `db:"converted_cost"` // та же стоимость в валюте итогов
+	Rates         MonthRates `db:"rates"`          // курсы по месяцам, если валюты различаются
 }
 
 type AggregateResponse struct {
@@ -16,12 +325,175 @@ type AggregateResponse struct {
 	Subscriptions []SubscriptionInfo `json:"subscriptions"`
 	From          string             `json:"from"`
 	To            string             `json:"to"`
+	Currency      string             `json:"currency"`
+	Proration     Proration          `json:"proration"`
 	Total         int64              `json:"total"`
+	// AggregateTotal — тот же итог, посчитанный отдельной SQL-агрегацией, для сверки с Total;
+	// расхождение логируется
+	AggregateTotal int64 `json:"aggregate_total"`
+	// Groups — подытоги по группам, если задан group_by
+	GroupBy GroupBy      `json:"group_by,omitempty"`
+	Groups  []GroupTotal `json:"groups,omitempty"`
 }
 
 type SubscriptionInfo struct {
-	Number      int    `json:"number"` // Номер подписки в списке
+	Number        int           `json:"number"` // Номер подписки в списке
+	ServiceName   string        `json:"service_name"`
+	Price         int           `json:"price"`
+	UserID        string        `json:"user_id"`
+	Category      *string       `json:"category,omitempty"`
+	Tags          []string      `json:"tags"`
+	BillingPeriod BillingPeriod `json:"billing_period"`
+	MonthlyCost   int           `json:"monthly_cost"` // Цена, приведённая к месяцу
+	Currency      string        `json:"currency"`
+	Months        int           `json:"months"`          // Число активных месяцев в окне
+	Days          int           `json:"days"`            // Число активных дней в окне
+	Cost          int64         `json:"cost"`            // Стоимость за окно: цена × число списаний
+	ConvertedCost int64         `json:"converted_cost"`  // Стоимость за окно в валюте итогов
+	Rates         []MonthRate   `json:"rates,omitempty"` // Курсы пересчёта по месяцам
+}
+
+// GroupBy — признак, по которому агрегация подводит подытоги
+type GroupBy string
+
+const (
+	GroupByCategory GroupBy = "category"
+	GroupByTag      GroupBy = "tag"
+	GroupByService  GroupBy = "service"
+	GroupByUser     GroupBy = "user"
+)
+
+func (g GroupBy) Valid() bool {
+	switch g {
+	case GroupByCategory, GroupByTag, GroupByService, GroupByUser:
+		return true
+	}
+	return false
+}
+
+// GroupTotal — подытог группы подписок; Key = nil — подписки без категории или без меток
+type GroupTotal struct {
+	Key           *string `json:"key"`
+	Subscriptions int     `json:"subscriptions"` // число подписок в группе
+	Total         int64   `json:"total"`         // стоимость в валюте итогов
+}
+
+// GroupTotals подводит подытоги по деталям агрегации. Подписка с несколькими метками
+// входит в группу каждой из них, поэтому сумма по меткам может превышать общий итог.
+// Группы отсортированы по убыванию стоимости.
+func GroupTotals(subs []SubscriptionInfo, by GroupBy) []GroupTotal {
+	groups := make([]GroupTotal, 0)
+	index := make(map[string]int)
+	add := func(key *string, cost int64) {
+		k := "\x00" // ключ группы без значения, не совпадающий ни с одной строкой
+		if key != nil {
+			k = *key
+		}
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, GroupTotal{Key: key})
+		}
+		groups[i].Subscriptions++
+		groups[i].Total += cost
+	}
+
+	for _, sub := range subs {
+		switch by {
+		case GroupByCategory:
+			add(sub.Category, sub.ConvertedCost)
+		case GroupByTag:
+			if len(sub.Tags) == 0 {
+				add(nil, sub.ConvertedCost)
+			}
+			for i := range sub.Tags {
+				add(&sub.Tags[i], sub.ConvertedCost)
+			}
+		case GroupByService:
+			name := sub.ServiceName
+			add(&name, sub.ConvertedCost)
+		case GroupByUser:
+			user := sub.UserID
+			add(&user, sub.ConvertedCost)
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Total > groups[j].Total
+	})
+	return groups
+}
+
+// MonthlyAggregateResponse — помесячная разбивка стоимости подписок за период
+type MonthlyAggregateResponse struct {
+	UserID   string      `json:"user_id,omitempty"`
+	From     string      `json:"from"`
+	To       string      `json:"to"`
+	Currency string      `json:"currency"`
+	Total    int64       `json:"total"`
+	Months   []MonthCost `json:"months"`
+}
+
+type MonthCost struct {
+	Month    string        `json:"month"` // MM-YYYY
+	Total    int64         `json:"total"`
+	Services []ServiceCost `json:"services"`
+}
+
+type ServiceCost struct {
 	ServiceName string `json:"service_name"`
-	Price       int    `json:"price"`
-	UserID      string `json:"user_id"`
+	Total       int64  `json:"total"`
+}
+
+// MonthServiceTotal — строка выборки: сумма по сервису за один месяц
+type MonthServiceTotal struct {
+	Month       time.Time `db:"month"`
+	ServiceName string    `db:"service_name"`
+	Total       int64     `db:"total"`
+}
+
+// BatchOp — операция пакетного изменения подписок
+type BatchOp string
+
+const (
+	BatchCreate BatchOp = "create"
+	BatchUpdate BatchOp = "update"
+	BatchDelete BatchOp = "delete"
+)
+
+// BatchOperation — одна операция пакета. Для update и delete Version — ожидаемая
+// версия подписки (0 — любая), как в If-Match.
+type BatchOperation struct {
+	Op           BatchOp
+	ID           string
+	Version      int
+	Subscription *Subscription // для create и update
+}
+
+// BatchResult — итог операции пакета: подписка после create/update или ошибка
+type BatchResult struct {
+	Subscription *Subscription
+	Err          error
+}
+
+// ImportRow — подписка из строки файла импорта; Line — номер строки в файле (с 1, с заголовком)
+type ImportRow struct {
+	Line         int
+	Subscription *Subscription
+}
+
+// ImportError — ошибка в строке файла импорта
+type ImportError struct {
+	Line   int    `json:"line"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+// ImportReport — итог импорта или его пробного прогона
+type ImportReport struct {
+	DryRun   bool          `json:"dry_run"`
+	Rows     int           `json:"rows"`     // строк с данными в файле
+	Imported int64         `json:"imported"` // записано подписок; при dry_run и ошибках — 0
+	Errors   []ImportError `json:"errors"`
 }
@@ -0,0 +1,197 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBillingPeriodMonthlyEquivalent(t *testing.T) {
+	tests := []struct {
+		period BillingPeriod
+		price  int
+		want   int
+	}{
+		{BillingMonthly, 499, 499},
+		{BillingWeekly, 100, 433},    // 100 × 52 / 12 = 433.3
+		{BillingQuarterly, 900, 300}, // 900 × 4 / 12
+		{BillingYearly, 1000, 83},    // 1000 / 12 = 83.3
+		{BillingYearly, 1002, 84},    // 83.5 округляется вверх
+		{BillingPeriod("daily"), 50, 50},
+	}
+	for _, tt := range tests {
+		if got := tt.period.MonthlyEquivalent(tt.price); got != tt.want {
+			t.Errorf("%s.MonthlyEquivalent(%d) = %d, want %d", tt.period, tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestBillingPeriodValid(t *testing.T) {
+	for _, p := range []BillingPeriod{BillingWeekly, BillingMonthly, BillingQuarterly, BillingYearly} {
+		if !p.Valid() {
+			t.Errorf("%s.Valid() = false", p)
+		}
+	}
+	for _, p := range []BillingPeriod{"", "daily", "Monthly"} {
+		if p.Valid() {
+			t.Errorf("%q.Valid() = true", p)
+		}
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		want     bool
+	}{
+		{StatusTrial, StatusPaused, true},
+		{StatusActive, StatusPaused, true},
+		{StatusPaused, StatusPaused, false},
+		{StatusPaused, StatusActive, true},
+		{StatusTrial, StatusActive, false},
+		{StatusPaused, StatusCancelled, true},
+		{StatusCancelled, StatusActive, false},
+		{StatusExpired, StatusCancelled, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestEffectiveStatus(t *testing.T) {
+	today := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	past := today.AddDate(0, 0, -1)
+	tests := []struct {
+		status   Status
+		end      *time.Time
+		trialEnd *time.Time
+		want     Status
+	}{
+		{StatusActive, nil, nil, StatusActive},
+		{StatusActive, &today, nil, StatusActive},
+		{StatusActive, &past, nil, StatusExpired},
+		{StatusPaused, &past, nil, StatusExpired},
+		{StatusCancelled, &past, nil, StatusCancelled},
+		{StatusTrial, nil, &today, StatusTrial},
+		{StatusTrial, nil, &past, StatusActive},
+		{StatusTrial, &past, &past, StatusExpired},
+	}
+	for _, tt := range tests {
+		s := Subscription{Status: tt.status, EndDate: tt.end, TrialEnd: tt.trialEnd}
+		if got := s.EffectiveStatus(today); got != tt.want {
+			t.Errorf("%s until %v, trial until %v: EffectiveStatus = %s, want %s", tt.status, tt.end, tt.trialEnd, got, tt.want)
+		}
+	}
+}
+
+func TestGroupTotals(t *testing.T) {
+	str := func(s string) *string { return &s }
+	subs := []SubscriptionInfo{
+		{ServiceName: "Netflix", UserID: "u1", Category: str("streaming"), ConvertedCost: 400},
+		{ServiceName: "Dropbox", UserID: "u2", Category: str("cloud"), Tags: []string{"team-a", "infra"}, ConvertedCost: 300},
+		{ServiceName: "Netflix", UserID: "u2", Category: str("streaming"), Tags: []string{"team-a"}, ConvertedCost: 100},
+	}
+
+	tests := []struct {
+		by   GroupBy
+		want []GroupTotal
+	}{
+		{GroupByCategory, []GroupTotal{{str("streaming"), 2, 500}, {str("cloud"), 1, 300}}},
+		// подписка с двумя метками входит в обе группы; при равных суммах сохраняется порядок появления
+		{GroupByTag, []GroupTotal{{nil, 1, 400}, {str("team-a"), 2, 400}, {str("infra"), 1, 300}}},
+		{GroupByService, []GroupTotal{{str("Netflix"), 2, 500}, {str("Dropbox"), 1, 300}}},
+		{GroupByUser, []GroupTotal{{str("u1"), 1, 400}, {str("u2"), 2, 400}}},
+	}
+	for _, tt := range tests {
+		got := GroupTotals(subs, tt.by)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d groups, want %d", tt.by, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			g, w := got[i], tt.want[i]
+			if (g.Key == nil) != (w.Key == nil) || (g.Key != nil && *g.Key != *w.Key) ||
+				g.Subscriptions != w.Subscriptions || g.Total != w.Total {
+				t.Errorf("%s: group %d = %v %+v, want %v %+v", tt.by, i, deref(g.Key), g, deref(w.Key), w)
+			}
+		}
+	}
+
+	if got := GroupTotals(nil, GroupByTag); got == nil || len(got) != 0 {
+		t.Errorf("GroupTotals(nil) = %#v, want empty slice", got)
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
+
+func TestListCursorRoundTrip(t *testing.T) {
+	tests := []ListCursor{
+		{StartDate: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), ID: "60601fee-2bf1-4721-ae6f-7636e79a0cba"},
+		{StartDate: time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC), ID: "x"},
+	}
+	for _, c := range tests {
+		got, err := DecodeListCursor(c.Encode())
+		if err != nil {
+			t.Fatalf("DecodeListCursor(%+v) error = %v", c, err)
+		}
+		if !got.StartDate.Equal(c.StartDate) || got.ID != c.ID {
+			t.Errorf("DecodeListCursor(Encode(%+v)) = %+v", c, got)
+		}
+	}
+}
+
+func TestDecodeListCursorInvalid(t *testing.T) {
+	enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := map[string]string{
+		"empty":         "",
+		"not base64":    "!!!",
+		"padded base64": base64.URLEncoding.EncodeToString([]byte(`{"s":"2025-07-01T00:00:00Z","id":"a"}`)),
+		"not json":      enc("cursor"),
+		"missing id":    enc(`{"s":"2025-07-01T00:00:00Z"}`),
+		"missing date":  enc(`{"id":"a"}`),
+		"bad date":      enc(`{"s":"07-2025","id":"a"}`),
+	}
+	for name, s := range tests {
+		if _, err := DecodeListCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: DecodeListCursor(%q) error = %v, want ErrInvalidCursor", name, s, err)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []SortField
+		wantErr bool
+	}{
+		{"price", []SortField{{Field: "price"}}, false},
+		{"-start_date", []SortField{{Field: "start_date", Desc: true}}, false},
+		{"+price", []SortField{{Field: "price"}}, false},
+		{"price, -start_date,id", []SortField{{Field: "price"}, {Field: "start_date", Desc: true}, {Field: "id"}}, false},
+		{"", nil, true},
+		{"price,", nil, true},
+		{"-", nil, true},
+		{"monthly_cost", nil, true},
+		{"start_date;drop table", nil, true},
+		{"price,-price", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSort(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSort(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSort(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
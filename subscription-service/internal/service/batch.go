@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"subscription-service/internal/db"
+	"subscription-service/internal/model"
+)
+
+// ErrBatchRolledBack — операция атомарного пакета не применена: другая операция пакета
+// завершилась ошибкой, и транзакция откачена
+var ErrBatchRolledBack = errors.New("batch rolled back")
+
+// errBatchFailed прерывает транзакцию атомарного пакета
+var errBatchFailed = errors.New("batch operation failed")
+
+// Batch выполняет операции по порядку. В атомарном режиме все операции идут в одной
+// транзакции и при первой ошибке откатываются (committed = false); в режиме best effort
+// каждая операция применяется независимо. Результаты возвращаются в порядке операций.
+func (s *subscriptionService) Batch(ctx context.Context, ops []model.BatchOperation, atomic bool) (results []model.BatchResult, committed bool, err error) {
+	s.logger(ctx).Info().Ctx(ctx).Int("count", len(ops)).Bool("atomic", atomic).Msg("Applying subscription batch")
+
+	results = make([]model.BatchResult, len(ops))
+	if !atomic {
+		for i, op := range ops {
+			results[i] = s.applyOp(ctx, op)
+		}
+		s.logger(ctx).Debug().Ctx(ctx).Int("count", len(ops)).Msg("Subscription batch applied")
+		return results, true, nil
+	}
+
+	failed := -1
+	err = s.repo.WithTx(ctx, func(repo db.Repository) error {
+		tx := s.withRepo(repo)
+		for i, op := range ops {
+			results[i] = tx.applyOp(ctx, op)
+			if results[i].Err != nil {
+				failed = i
+				return errBatchFailed
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchFailed) {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("batch transaction failed")
+		return nil, false, err
+	}
+	if failed < 0 {
+		s.logger(ctx).Debug().Ctx(ctx).Int("count", len(ops)).Msg("Subscription batch committed")
+		return results, true, nil
+	}
+
+	for i := range results {
+		if i != failed {
+			results[i] = model.BatchResult{Err: fmt.Errorf("%w: operation %d failed", ErrBatchRolledBack, failed)}
+		}
+	}
+	s.logger(ctx).Warn().Ctx(ctx).Int("failed", failed).Err(results[failed].Err).Msg("Subscription batch rolled back")
+	return results, false, nil
+}
+
+// withRepo возвращает копию сервиса, работающую через repo (например, внутри транзакции)
+func (s *subscriptionService) withRepo(repo db.Repository) *subscriptionService {
+	c := *s
+	c.repo = repo
+	return &c
+}
+
+func (s *subscriptionService) applyOp(ctx context.Context, op model.BatchOperation) model.BatchResult {
+	switch op.Op {
+	case model.BatchCreate:
+		if err := s.Create(ctx, op.Subscription); err != nil {
+			return model.BatchResult{Err: err}
+		}
+		return model.BatchResult{Subscription: op.Subscription}
+	case model.BatchUpdate:
+		op.Subscription.ID = op.ID
+		op.Subscription.Version = op.Version
+		if err := s.Update(ctx, op.Subscription); err != nil {
+			return model.BatchResult{Err: err}
+		}
+		sub, err := s.GetByID(ctx, op.ID)
+		return model.BatchResult{Subscription: sub, Err: err}
+	case model.BatchDelete:
+		return model.BatchResult{Err: s.Delete(ctx, op.ID, op.Version)}
+	default:
+		return model.BatchResult{Err: fmt.Errorf("unknown batch op %q", op.Op)}
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"subscription-service/internal/db"
+	"subscription-service/internal/model"
+)
+
+// ErrServiceNotFound — услуги с таким id нет в каталоге
+var ErrServiceNotFound = errors.New("service not found")
+
+// ErrServiceConflict — название или псевдоним уже занят другой услугой каталога
+var ErrServiceConflict = db.ErrDuplicate
+
+func (s *subscriptionService) CreateService(ctx context.Context, svc *model.CatalogService) error {
+	s.logger(ctx).Info().Ctx(ctx).Str("name", svc.Name).Msg("Creating catalog service")
+
+	if err := s.checkCatalogNames(ctx, svc); err != nil {
+		return err
+	}
+	if err := s.repo.CreateService(ctx, svc); err != nil {
+		if errors.Is(err, ErrServiceConflict) {
+			s.logger(ctx).Warn().Ctx(ctx).Str("name", svc.Name).Msg("Catalog service name taken")
+			return err
+		}
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo create service failed")
+		return err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Str("id", svc.ID).Msg("Catalog service created successfully")
+	return nil
+}
+
+func (s *subscriptionService) GetService(ctx context.Context, id string) (*model.CatalogService, error) {
+	s.logger(ctx).Info().Ctx(ctx).Str("id", id).Msg("Fetching catalog service")
+
+	svc, err := s.repo.GetService(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.logger(ctx).Warn().Ctx(ctx).Str("id", id).Msg("Catalog service not found")
+			return nil, ErrServiceNotFound
+		}
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Str("id", id).Msg("repo get service failed")
+		return nil, err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Str("id", id).Msg("Catalog service fetched successfully")
+	return svc, nil
+}
+
+func (s *subscriptionService) ListServices(ctx context.Context) ([]*model.CatalogService, error) {
+	s.logger(ctx).Info().Ctx(ctx).Msg("Listing catalog services")
+
+	services, err := s.repo.ListServices(ctx)
+	if err != nil {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo list services failed")
+		return nil, err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Int("count", len(services)).Msg("Catalog services listed successfully")
+	return services, nil
+}
+
+func (s *subscriptionService) UpdateService(ctx context.Context, svc *model.CatalogService) error {
+	s.logger(ctx).Info().Ctx(ctx).Str("id", svc.ID).Str("name", svc.Name).Msg("Updating catalog service")
+
+	if err := s.checkCatalogNames(ctx, svc); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateService(ctx, svc); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.logger(ctx).Warn().Ctx(ctx).Str("id", svc.ID).Msg("Catalog service not found")
+			return ErrServiceNotFound
+		}
+		if errors.Is(err, ErrServiceConflict) {
+			s.logger(ctx).Warn().Ctx(ctx).Str("name", svc.Name).Msg("Catalog service name taken")
+			return err
+		}
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Str("id", svc.ID).Msg("repo update service failed")
+		return err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Str("id", svc.ID).Msg("Catalog service updated successfully")
+	return nil
+}
+
+func (s *subscriptionService) DeleteService(ctx context.Context, id string) error {
+	s.logger(ctx).Info().Ctx(ctx).Str("id", id).Msg("Deleting catalog service")
+
+	if err := s.repo.DeleteService(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.logger(ctx).Warn().Ctx(ctx).Str("id", id).Msg("Catalog service not found")
+			return ErrServiceNotFound
+		}
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Str("id", id).Msg("repo delete service failed")
+		return err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Str("id", id).Msg("Catalog service deleted successfully")
+	return nil
+}
+
+// checkCatalogNames нормализует название и псевдонимы услуги и проверяет, что ни одно
+// из них не указывает на другую услугу: иначе разрешение названий стало бы неоднозначным
+func (s *subscriptionService) checkCatalogNames(ctx context.Context, svc *model.CatalogService) error {
+	svc.Name = strings.Join(strings.Fields(svc.Name), " ")
+
+	seen := map[string]bool{model.NormalizeAlias(svc.Name): true}
+	aliases := make([]string, 0, len(svc.Aliases))
+	for _, alias := range svc.Aliases {
+		alias = model.NormalizeAlias(alias)
+		if alias == "" || seen[alias] {
+			continue
+		}
+		seen[alias] = true
+		aliases = append(aliases, alias)
+	}
+	svc.Aliases = aliases
+
+	for name := range seen {
+		other, err := s.repo.ResolveService(ctx, name)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo resolve service failed")
+			return err
+		}
+		if other.ID != svc.ID {
+			s.logger(ctx).Warn().Ctx(ctx).Str("name", name).Str("taken_by", other.ID).Msg("Catalog service name taken")
+			return fmt.Errorf("%w: %q is used by %s", ErrServiceConflict, name, other.Name)
+		}
+	}
+	return nil
+}
+
+// applyCatalog связывает подписку с услугой каталога: по service_id, а без него — по
+// названию или псевдониму. Название подписки заменяется каноническим. Подписки с
+// названием, которого нет в каталоге, сохраняются как есть; возвращается nil.
+func (s *subscriptionService) applyCatalog(ctx context.Context, sub *model.Subscription) (*model.CatalogService, error) {
+	var (
+		svc *model.CatalogService
+		err error
+	)
+	if sub.ServiceID != nil {
+		svc, err = s.GetService(ctx, *sub.ServiceID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		svc, err = s.repo.ResolveService(ctx, sub.ServiceName)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo resolve service failed")
+			return nil, err
+		}
+	}
+
+	linkService(sub, svc)
+	return svc, nil
+}
+
+// linkService связывает подписку с услугой каталога под её каноническим названием
+func linkService(sub *model.Subscription, svc *model.CatalogService) {
+	sub.ServiceID = &svc.ID
+	sub.ServiceName = svc.Name
+}
+
+// catalogServiceID ищет в каталоге услугу с таким названием или псевдонимом;
+// пустая строка — такой услуги нет
+func (s *subscriptionService) catalogServiceID(ctx context.Context, name string) (string, error) {
+	svc, err := s.repo.ResolveService(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo resolve service failed")
+		return "", err
+	}
+	return svc.ID, nil
+}
+
+// resolveAggregateFilter заменяет поиск по подстроке названия точным фильтром по услуге
+// каталога, если такое название или псевдоним в каталоге есть
+func (s *subscriptionService) resolveAggregateFilter(ctx context.Context, f *model.AggregateFilter) error {
+	if f.ServiceID != nil || f.ServiceName == nil || *f.ServiceName == "" {
+		return nil
+	}
+	id, err := s.catalogServiceID(ctx, *f.ServiceName)
+	if err != nil || id == "" {
+		return err
+	}
+	f.ServiceID, f.ServiceName = &id, nil
+	return nil
+}
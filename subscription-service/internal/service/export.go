@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"subscription-service/internal/model"
+)
+
+// ExportSubscriptions передаёт fn подписки по фильтру по одной, прямо из курсора БД,
+// чтобы выгрузка любого размера не собиралась в памяти. Фильтр тот же, что у List.
+func (s *subscriptionService) ExportSubscriptions(ctx context.Context, f model.ListFilter, fn func(*model.Subscription) error) error {
+	s.logger(ctx).Info().Ctx(ctx).
+		Strs("user_ids", f.UserIDs).
+		Str("service_name", f.ServiceName).
+		Str("category", f.Category).
+		Strs("tags", f.Tags).
+		Msg("Exporting subscriptions")
+
+	if f.ServiceID == "" && f.ServiceName != "" {
+		id, err := s.catalogServiceID(ctx, f.ServiceName)
+		if err != nil {
+			return err
+		}
+		if id != "" {
+			f.ServiceID, f.ServiceName = id, ""
+		}
+	}
+
+	count := 0
+	err := s.repo.StreamSubscriptions(ctx, f, func(sub *model.Subscription) error {
+		s.fill(sub)
+		count++
+		return fn(sub)
+	})
+	if err != nil {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Int("exported", count).Msg("export subscriptions failed")
+		return err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Int("count", count).Msg("Subscriptions exported successfully")
+	return nil
+}
+
+// ExportAggregate — потоковый вариант AggregateWithDetails: детали передаются fn по мере
+// чтения из БД, итог возвращается в конце. Если для окна нет курса, ErrRateNotFound
+// возвращается до первого вызова fn.
+func (s *subscriptionService) ExportAggregate(ctx context.Context, f model.AggregateFilter, fn func(model.SubscriptionInfo) error) (int64, error) {
+	s.logger(ctx).Info().Ctx(ctx).
+		Time("from", f.From).
+		Time("to", f.To).
+		Str("user_id", deref(f.UserID)).
+		Str("service_name", deref(f.ServiceName)).
+		Str("currency", f.Currency).
+		Str("proration", string(f.Proration)).
+		Msg("Exporting aggregate details")
+
+	if err := s.resolveAggregateFilter(ctx, &f); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	n := 0
+	err := s.repo.StreamSubscriptionsOverlapping(ctx, f, func(sub *model.SubscriptionUsage) error {
+		n++
+		total += sub.ConvertedCost
+		return fn(subscriptionInfo(n, sub))
+	})
+	if err != nil {
+		if errors.Is(err, ErrRateNotFound) {
+			s.logger(ctx).Warn().Ctx(ctx).Err(err).Msg("Exchange rate missing")
+			return 0, err
+		}
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Int("exported", n).Msg("export aggregate failed")
+		return 0, err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Int64("total", total).Int("count", n).Msg("Aggregate exported successfully")
+	return total, nil
+}
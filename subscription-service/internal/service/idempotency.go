@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+const (
+	defaultIdempotencyTTL        = 24 * time.Hour
+	defaultIdempotencyStaleAfter = time.Minute
+)
+
+// ErrIdempotencyKeyReused — ключ уже использован для запроса с другим телом
+var ErrIdempotencyKeyReused = errors.New("idempotency key was used with a different request")
+
+// ErrIdempotencyInProgress — запрос с этим ключом ещё выполняется
+var ErrIdempotencyInProgress = errors.New("request with this idempotency key is in progress")
+
+// BeginIdempotent занимает ключ под запрос scope с телом body. Если запрос с этим ключом
+// уже выполнен, возвращает сохранённый ответ, который нужно повторить. Если ключ занят
+// успешно, возвращает запись без ответа: после выполнения её нужно передать в FinishIdempotent.
+func (s *subscriptionService) BeginIdempotent(ctx context.Context, scope, key string, body []byte) (*model.IdempotencyRecord, error) {
+	sum := sha256.Sum256(body)
+	rec := &model.IdempotencyRecord{Scope: scope, Key: key, RequestHash: hex.EncodeToString(sum[:])}
+
+	s.logger(ctx).Info().Ctx(ctx).Str("scope", scope).Str("key", key).Msg("Claiming idempotency key")
+
+	existing, err := s.repo.ClaimIdempotencyKey(ctx, rec, s.idempotencyTTL, s.idempotencyStaleAfter)
+	if err != nil {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Str("key", key).Msg("repo claim idempotency key failed")
+		return nil, err
+	}
+	if existing == nil {
+		return rec, nil
+	}
+	if existing.RequestHash != rec.RequestHash {
+		s.logger(ctx).Warn().Ctx(ctx).Str("scope", scope).Str("key", key).Msg("Idempotency key reused with a different request")
+		return nil, ErrIdempotencyKeyReused
+	}
+	if existing.StatusCode == nil {
+		s.logger(ctx).Warn().Ctx(ctx).Str("scope", scope).Str("key", key).Msg("Idempotent request in progress")
+		return nil, ErrIdempotencyInProgress
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Str("scope", scope).Str("key", key).Int("status", *existing.StatusCode).Msg("Replaying idempotent response")
+	return existing, nil
+}
+
+// FinishIdempotent сохраняет ответ на запрос. Ответ с ошибкой сервера не сохраняется:
+// ключ освобождается, и клиент может повторить запрос.
+func (s *subscriptionService) FinishIdempotent(ctx context.Context, rec *model.IdempotencyRecord) error {
+	if rec.StatusCode == nil || *rec.StatusCode >= 500 {
+		if err := s.repo.ReleaseIdempotencyKey(ctx, rec); err != nil {
+			s.logger(ctx).Error().Ctx(ctx).Err(err).Str("key", rec.Key).Msg("repo release idempotency key failed")
+			return err
+		}
+		return nil
+	}
+
+	if err := s.repo.CompleteIdempotencyKey(ctx, rec); err != nil {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Str("key", rec.Key).Msg("repo complete idempotency key failed")
+		return err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Str("scope", rec.Scope).Str("key", rec.Key).Msg("Idempotent response saved")
+	return nil
+}
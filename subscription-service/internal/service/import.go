@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+
+	"subscription-service/internal/model"
+)
+
+// Import готовит подписки из файла так же, как при создании по одной, и записывает их
+// одним пакетом. Услуги каталога для всех строк ищутся заранее, а не по строке. Если хоть
+// одна строка с ошибкой или dryRun, ничего не записывается: возвращаются только ошибки по строкам.
+func (s *subscriptionService) Import(ctx context.Context, rows []model.ImportRow, dryRun bool) (int64, []model.ImportError, error) {
+	s.logger(ctx).Info().Ctx(ctx).Int("rows", len(rows)).Bool("dry_run", dryRun).Msg("Importing subscriptions")
+
+	byID, byName, err := s.importCatalog(ctx, rows)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rowErrors := make([]model.ImportError, 0)
+	subs := make([]*model.Subscription, 0, len(rows))
+	for _, row := range rows {
+		sub := row.Subscription
+		var svc *model.CatalogService
+		if sub.ServiceID != nil {
+			if svc = byID[*sub.ServiceID]; svc == nil {
+				rowErrors = append(rowErrors, model.ImportError{Line: row.Line, Column: "service_id", Error: ErrServiceNotFound.Error()})
+				continue
+			}
+		} else {
+			svc = byName[model.NormalizeAlias(sub.ServiceName)]
+		}
+		if svc != nil {
+			linkService(sub, svc)
+		}
+		s.applyDefaults(sub, svc)
+		subs = append(subs, sub)
+	}
+
+	if dryRun || len(rowErrors) > 0 {
+		s.logger(ctx).Debug().Ctx(ctx).Int("errors", len(rowErrors)).Msg("Import validated without writing")
+		return 0, rowErrors, nil
+	}
+
+	n, err := s.repo.ImportSubscriptions(ctx, subs)
+	if err != nil {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo import failed")
+		return 0, nil, err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Int64("imported", n).Msg("Subscriptions imported successfully")
+	return n, rowErrors, nil
+}
+
+// importCatalog находит услуги каталога для всех строк импорта сразу: по service_id
+// и по названиям без него, по запросу на каждый вид, а не на каждую строку
+func (s *subscriptionService) importCatalog(ctx context.Context, rows []model.ImportRow) (byID, byName map[string]*model.CatalogService, err error) {
+	var ids, names []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if id := row.Subscription.ServiceID; id != nil {
+			if !seen["id:"+*id] {
+				seen["id:"+*id] = true
+				ids = append(ids, *id)
+			}
+		} else if name := model.NormalizeAlias(row.Subscription.ServiceName); !seen["name:"+name] {
+			seen["name:"+name] = true
+			names = append(names, name)
+		}
+	}
+
+	if len(ids) > 0 {
+		if byID, err = s.repo.GetServices(ctx, ids); err != nil {
+			s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo get services failed")
+			return nil, nil, err
+		}
+	}
+	if len(names) > 0 {
+		if byName, err = s.repo.ResolveServices(ctx, names); err != nil {
+			s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo resolve services failed")
+			return nil, nil, err
+		}
+	}
+	return byID, byName, nil
+}
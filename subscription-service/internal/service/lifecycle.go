@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+func (s *subscriptionService) Pause(ctx context.Context, id string) (*model.Subscription, error) {
+	return s.transition(ctx, id, model.StatusPaused, "pause", func(sub *model.Subscription, at time.Time) error {
+		return s.repo.Pause(ctx, id, sub.Status, at)
+	})
+}
+
+func (s *subscriptionService) Resume(ctx context.Context, id string) (*model.Subscription, error) {
+	return s.transition(ctx, id, model.StatusActive, "resume", func(_ *model.Subscription, at time.Time) error {
+		return s.repo.Resume(ctx, id, at)
+	})
+}
+
+func (s *subscriptionService) Cancel(ctx context.Context, id string) (*model.Subscription, error) {
+	return s.transition(ctx, id, model.StatusCancelled, "cancel", func(sub *model.Subscription, at time.Time) error {
+		return s.repo.Cancel(ctx, id, sub.Status, at)
+	})
+}
+
+// transition проверяет, что действие action допустимо в текущем статусе подписки, и выполняет
+// его через apply. apply получает подписку с сохранённым в БД статусом; если статус успел
+// измениться параллельным запросом, репозиторий возвращает sql.ErrNoRows.
+func (s *subscriptionService) transition(ctx context.Context, id string, to model.Status, action string,
+	apply func(sub *model.Subscription, at time.Time) error) (*model.Subscription, error) {
+	s.logger(ctx).Info().Ctx(ctx).Str("id", id).Str("action", action).Msg("Changing subscription status")
+
+	sub, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.logger(ctx).Warn().Ctx(ctx).Str("id", id).Msg("Subscription not found")
+			return nil, ErrNotFound
+		}
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Str("id", id).Msg("repo get failed")
+		return nil, err
+	}
+
+	today := s.today()
+	current := sub.EffectiveStatus(today)
+	if !model.CanTransition(current, to) {
+		s.logger(ctx).Warn().Ctx(ctx).Str("id", id).Str("status", string(current)).Str("action", action).Msg("Invalid status transition")
+		return nil, fmt.Errorf("%w: cannot %s a subscription in status %s", ErrInvalidTransition, action, current)
+	}
+
+	if err := apply(sub, today); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.logger(ctx).Warn().Ctx(ctx).Str("id", id).Str("action", action).Msg("Subscription status changed concurrently")
+			return nil, fmt.Errorf("%w: subscription status changed concurrently", ErrInvalidTransition)
+		}
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Str("id", id).Str("action", action).Msg("repo status change failed")
+		return nil, err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Str("id", id).Str("status", string(to)).Msg("Subscription status changed successfully")
+	return s.GetByID(ctx, id)
+}
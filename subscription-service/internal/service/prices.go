@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"subscription-service/internal/model"
+)
+
+// ErrPastPriceChange — цену нельзя менять задним числом: это переписало бы уже посчитанные месяцы
+var ErrPastPriceChange = errors.New("price change must not take effect in the past")
+
+func (s *subscriptionService) SchedulePriceChange(ctx context.Context, id string, month time.Time, price int) ([]model.PriceChange, error) {
+	s.logger(ctx).Info().Ctx(ctx).
+		Str("id", id).
+		Str("effective_month", month.Format(monthLayout)).
+		Int("price", price).
+		Msg("Scheduling price change")
+
+	month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	today := s.today()
+	current := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if month.Before(current) {
+		s.logger(ctx).Warn().Ctx(ctx).Str("id", id).Str("effective_month", month.Format(monthLayout)).Msg("Price change in the past rejected")
+		return nil, fmt.Errorf("%w: %s is before %s", ErrPastPriceChange, month.Format(monthLayout), current.Format(monthLayout))
+	}
+
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetPrice(ctx, id, month, price); err != nil {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Str("id", id).Msg("repo set price failed")
+		return nil, err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Str("id", id).Msg("Price change scheduled successfully")
+	return s.PriceHistory(ctx, id)
+}
+
+func (s *subscriptionService) PriceHistory(ctx context.Context, id string) ([]model.PriceChange, error) {
+	s.logger(ctx).Info().Ctx(ctx).Str("id", id).Msg("Fetching price history")
+
+	history, err := s.repo.PriceHistory(ctx, id)
+	if err != nil {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Str("id", id).Msg("repo price history failed")
+		return nil, err
+	}
+	if len(history) == 0 {
+		// у каждой подписки есть хотя бы начальная цена, пустая история — подписки нет
+		if _, err := s.repo.GetByID(ctx, id); errors.Is(err, sql.ErrNoRows) {
+			s.logger(ctx).Warn().Ctx(ctx).Str("id", id).Msg("Subscription not found")
+			return nil, ErrNotFound
+		}
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Str("id", id).Int("count", len(history)).Msg("Price history fetched successfully")
+	return history, nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+
+	"subscription-service/internal/model"
+)
+
+// Search ищет подписки по названию услуги с учётом опечаток, транслитерации и псевдонимов
+// каталога. Выдача упорядочена по близости к запросу; остальные условия фильтра — как у List.
+func (s *subscriptionService) Search(ctx context.Context, query string, f model.ListFilter) ([]*model.SearchHit, error) {
+	s.logger(ctx).Info().Ctx(ctx).
+		Str("query", query).
+		Strs("user_ids", f.UserIDs).
+		Str("category", f.Category).
+		Strs("tags", f.Tags).
+		Int("limit", f.Limit).
+		Msg("Searching subscriptions")
+
+	if f.ServiceID == "" && f.ServiceName != "" {
+		id, err := s.catalogServiceID(ctx, f.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+		if id != "" {
+			f.ServiceID, f.ServiceName = id, ""
+		}
+	}
+	if f.Limit <= 0 {
+		f.Limit = model.DefaultListLimit
+	}
+
+	hits, err := s.repo.Search(ctx, query, f)
+	if err != nil {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo search failed")
+		return nil, err
+	}
+	for _, hit := range hits {
+		s.fill(&hit.Subscription)
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Int("count", len(hits)).Msg("Subscriptions searched successfully")
+	return hits, nil
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+
+	"subscription-service/internal/model"
+)
+
+// Stats возвращает сводку по подпискам на сегодня. Вызывается при каждом сборе метрик,
+// поэтому пишет в лог только на уровне debug.
+func (s *subscriptionService) Stats(ctx context.Context) (*model.Stats, error) {
+	stats, err := s.repo.Stats(ctx, s.today())
+	if err != nil {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo stats failed")
+		return nil, err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Int("statuses", len(stats.ByStatus)).Msg("Stats collected successfully")
+	return stats, nil
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
+
 	"subscription-service/internal/db"
+	"subscription-service/internal/logger"
 	"subscription-service/internal/model"
 
 	"github.com/rs/zerolog"
@@ -12,23 +15,93 @@ import (
 
 var ErrNotFound = errors.New("subscription not found")
 
+// ErrInvalidTransition — действие недопустимо в текущем статусе подписки
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// ErrRateNotFound — для пересчёта в валюту итогов не хватает курса
+var ErrRateNotFound = db.ErrNoExchangeRate
+
+// ErrVersionConflict — подписку изменили после того, как клиент её прочитал
+var ErrVersionConflict = db.ErrVersionConflict
+
+// monthLayout — формат месяца в помесячной разбивке (MM-YYYY)
+const monthLayout = "01-2006"
+
 type SubscriptionService interface {
 	Create(ctx context.Context, sub *model.Subscription) error
 	GetByID(ctx context.Context, id string) (*model.Subscription, error)
-	List(ctx context.Context, userID, serviceName string, limit, offset int) ([]*model.Subscription, error)
+	List(ctx context.Context, f model.ListFilter) (*model.SubscriptionPage, error)
 	Update(ctx context.Context, sub *model.Subscription) error
-	Delete(ctx context.Context, id string) error
-	Aggregate(ctx context.Context, from, to string, userID, serviceName *string) (int64, error)
-	AggregateWithDetails(ctx context.Context, from, to string, userID, serviceName *string) ([]model.SubscriptionInfo, int64, error)
+	Delete(ctx context.Context, id string, version int) error
+	Aggregate(ctx context.Context, f model.AggregateFilter) (int64, error)
+	AggregateWithDetails(ctx context.Context, f model.AggregateFilter) ([]model.SubscriptionInfo, int64, error)
+	AggregateMonthly(ctx context.Context, f model.AggregateFilter) ([]model.MonthCost, int64, error)
+	LoadExchangeRates(ctx context.Context, rates []model.ExchangeRate) error
+	Pause(ctx context.Context, id string) (*model.Subscription, error)
+	Resume(ctx context.Context, id string) (*model.Subscription, error)
+	Cancel(ctx context.Context, id string) (*model.Subscription, error)
+	SchedulePriceChange(ctx context.Context, id string, month time.Time, price int) ([]model.PriceChange, error)
+	PriceHistory(ctx context.Context, id string) ([]model.PriceChange, error)
+	CreateService(ctx context.Context, svc *model.CatalogService) error
+	GetService(ctx context.Context, id string) (*model.CatalogService, error)
+	ListServices(ctx context.Context) ([]*model.CatalogService, error)
+	UpdateService(ctx context.Context, svc *model.CatalogService) error
+	DeleteService(ctx context.Context, id string) error
+	BeginIdempotent(ctx context.Context, scope, key string, body []byte) (*model.IdempotencyRecord, error)
+	FinishIdempotent(ctx context.Context, rec *model.IdempotencyRecord) error
+	Batch(ctx context.Context, ops []model.BatchOperation, atomic bool) ([]model.BatchResult, bool, error)
+	Import(ctx context.Context, rows []model.ImportRow, dryRun bool) (int64, []model.ImportError, error)
+	ExportSubscriptions(ctx context.Context, f model.ListFilter, fn func(*model.Subscription) error) error
+	ExportAggregate(ctx context.Context, f model.AggregateFilter, fn func(model.SubscriptionInfo) error) (int64, error)
+	Search(ctx context.Context, query string, f model.ListFilter) ([]*model.SearchHit, error)
+	Stats(ctx context.Context) (*model.Stats, error)
 }
 
 type subscriptionService struct {
 	repo db.Repository
 	log  *zerolog.Logger
+	now  func() time.Time
+
+	idempotencyTTL        time.Duration
+	idempotencyStaleAfter time.Duration
+}
+
+// Option настраивает сервис подписок
+type Option func(*subscriptionService)
+
+// WithIdempotencyTTL задаёт, сколько хранится ответ на запрос с Idempotency-Key
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(s *subscriptionService) {
+		s.idempotencyTTL = ttl
+	}
+}
+
+// WithIdempotencyStaleAfter задаёт, через сколько незавершённый запрос считается брошенным
+// (процесс упал, не сохранив ответ), и его ключ можно занять снова. Окно не должно быть
+// короче самого долгого запроса, иначе повтор перехватит ключ у ещё выполняющегося.
+func WithIdempotencyStaleAfter(d time.Duration) Option {
+	return func(s *subscriptionService) {
+		s.idempotencyStaleAfter = d
+	}
+}
+
+func New(repo db.Repository, log *zerolog.Logger, opts ...Option) SubscriptionService {
+	s := &subscriptionService{
+		repo:                  repo,
+		log:                   log,
+		now:                   time.Now,
+		idempotencyTTL:        defaultIdempotencyTTL,
+		idempotencyStaleAfter: defaultIdempotencyStaleAfter,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func New(repo db.Repository, log *zerolog.Logger) SubscriptionService {
-	return &subscriptionService{repo: repo, log: log}
+// logger возвращает логгер запроса из ctx с его request_id, а вне HTTP-запроса — общий
+func (s *subscriptionService) logger(ctx context.Context) *zerolog.Logger {
+	return logger.FromContext(ctx, s.log)
 }
 
 // helper для указателей
@@ -39,19 +112,39 @@ func deref(s *string) string {
 	return *s
 }
 
+// today — текущая дата без времени; даты подписок хранятся с точностью до дня
+func (s *subscriptionService) today() time.Time {
+	now := s.now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// fill заполняет вычисляемые поля подписки: стоимость в месяц и текущий статус
+func (s *subscriptionService) fill(sub *model.Subscription) {
+	if sub.BillingPeriod == "" {
+		sub.BillingPeriod = model.BillingMonthly
+	}
+	sub.MonthlyCost = sub.BillingPeriod.MonthlyEquivalent(sub.Price)
+	sub.Status = sub.EffectiveStatus(s.today())
+}
+
 func (s *subscriptionService) Create(ctx context.Context, sub *model.Subscription) error {
-	s.log.Info().
+	s.logger(ctx).Info().Ctx(ctx).
 		Str("user_id", sub.UserID).
 		Str("service_name", sub.ServiceName).
 		Int("price", sub.Price).
 		Msg("Creating subscription")
 
+	if err := s.prepareCreate(ctx, sub); err != nil {
+		return err
+	}
+
 	if err := s.repo.Create(ctx, sub); err != nil {
-		s.log.Error().Err(err).Msg("repo create failed")
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo create failed")
 		return err
 	}
+	s.fill(sub)
 
-	s.log.Debug().
+	s.logger(ctx).Debug().Ctx(ctx).
 		Str("id", sub.ID).
 		Str("user_id", sub.UserID).
 		Msg("Subscription created successfully")
@@ -59,112 +152,221 @@ func (s *subscriptionService) Create(ctx context.Context, sub *model.Subscriptio
 	return nil
 }
 
+// prepareCreate дополняет новую подписку перед записью: связывает с каталогом, берёт из
+// него цену и категорию по умолчанию и выставляет начальный статус
+func (s *subscriptionService) prepareCreate(ctx context.Context, sub *model.Subscription) error {
+	svc, err := s.applyCatalog(ctx, sub)
+	if err != nil {
+		return err
+	}
+	s.applyDefaults(sub, svc)
+	return nil
+}
+
+// applyDefaults берёт из услуги каталога svc цену и категорию по умолчанию и выставляет
+// начальный статус; svc == nil — подписка не связана с каталогом
+func (s *subscriptionService) applyDefaults(sub *model.Subscription, svc *model.CatalogService) {
+	// цена не указана — берём цену услуги по умолчанию вместе с её валютой
+	if svc != nil && sub.Price == 0 && svc.DefaultPrice != nil {
+		sub.Price = *svc.DefaultPrice
+		sub.Currency = svc.Currency
+	}
+	if svc != nil && sub.Category == nil {
+		sub.Category = svc.Category
+	}
+
+	if sub.Status == "" {
+		sub.Status = model.StatusActive
+		if sub.TrialEnd != nil && !sub.TrialEnd.Before(s.today()) {
+			sub.Status = model.StatusTrial
+		}
+	}
+}
+
 func (s *subscriptionService) GetByID(ctx context.Context, id string) (*model.Subscription, error) {
-	s.log.Info().Str("id", id).Msg("Fetching subscription by ID")
+	s.logger(ctx).Info().Ctx(ctx).Str("id", id).Msg("Fetching subscription by ID")
 
 	sub, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			s.log.Warn().Str("id", id).Msg("Subscription not found")
+			s.logger(ctx).Warn().Ctx(ctx).Str("id", id).Msg("Subscription not found")
 			return nil, ErrNotFound
 		}
-		s.log.Error().Err(err).Str("id", id).Msg("repo get failed")
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Str("id", id).Msg("repo get failed")
 		return nil, err
 	}
 
-	s.log.Debug().Str("id", sub.ID).Msg("Subscription fetched successfully")
+	s.fill(sub)
+
+	s.logger(ctx).Debug().Ctx(ctx).Str("id", sub.ID).Msg("Subscription fetched successfully")
 	return sub, nil
 }
 
-func (s *subscriptionService) List(ctx context.Context, userID, serviceName string, limit, offset int) ([]*model.Subscription, error) {
-	s.log.Info().
-		Str("user_id", userID).
-		Str("service_name", serviceName).
-		Int("limit", limit).
-		Int("offset", offset).
-		Msg("Listing subscriptions")
+// List возвращает страницу подписок: не больше f.Limit штук, общее число по фильтру
+// и курсор следующей страницы, если она есть
+func (s *subscriptionService) List(ctx context.Context, f model.ListFilter) (*model.SubscriptionPage, error) {
+	ev := s.logger(ctx).Info().Ctx(ctx).
+		Strs("user_ids", f.UserIDs).
+		Str("service_name", f.ServiceName).
+		Str("category", f.Category).
+		Strs("tags", f.Tags).
+		Int("limit", f.Limit).
+		Int("offset", f.Offset)
+	if f.TrialEndsWithin != nil {
+		ev = ev.Int("trial_ends_within", *f.TrialEndsWithin)
+	}
+	if f.Cursor != nil {
+		ev = ev.Str("cursor_id", f.Cursor.ID)
+	}
+	ev.Msg("Listing subscriptions")
+
+	if f.ServiceID == "" && f.ServiceName != "" {
+		id, err := s.catalogServiceID(ctx, f.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+		if id != "" {
+			f.ServiceID, f.ServiceName = id, ""
+		}
+	}
 
-	subs, err := s.repo.List(ctx, userID, serviceName, limit, offset)
+	if f.Limit <= 0 {
+		f.Limit = model.DefaultListLimit
+	}
+	// на одну строку больше, чтобы узнать, есть ли следующая страница
+	limit := f.Limit
+	f.Limit++
+	subs, err := s.repo.List(ctx, f)
 	if err != nil {
-		s.log.Error().Err(err).Msg("repo list failed")
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo list failed")
 		return nil, err
 	}
+	total, err := s.repo.Count(ctx, f)
+	if err != nil {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo count failed")
+		return nil, err
+	}
+
+	page := &model.SubscriptionPage{Items: subs, Total: total}
+	if len(subs) > limit {
+		page.Items = subs[:limit]
+		// курсор задаёт позицию в порядке по умолчанию; при другой сортировке листают offset
+		if len(f.Sort) == 0 {
+			next := model.CursorAfter(page.Items[limit-1]).Encode()
+			page.NextCursor = &next
+		}
+	}
+	for _, sub := range page.Items {
+		s.fill(sub)
+	}
 
-	s.log.Debug().Int("count", len(subs)).Msg("Subscriptions listed successfully")
-	return subs, nil
+	s.logger(ctx).Debug().Ctx(ctx).Int("count", len(page.Items)).Int64("total", total).Msg("Subscriptions listed successfully")
+	return page, nil
 }
 
 func (s *subscriptionService) Update(ctx context.Context, sub *model.Subscription) error {
-	s.log.Info().
+	s.logger(ctx).Info().Ctx(ctx).
 		Str("id", sub.ID).
 		Str("user_id", sub.UserID).
 		Str("service_name", sub.ServiceName).
 		Int("price", sub.Price).
 		Msg("Updating subscription")
 
+	if _, err := s.applyCatalog(ctx, sub); err != nil {
+		return err
+	}
 	if err := s.repo.Update(ctx, sub); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			s.log.Warn().Str("id", sub.ID).Msg("Subscription not found")
+			s.logger(ctx).Warn().Ctx(ctx).Str("id", sub.ID).Msg("Subscription not found")
 			return ErrNotFound
 		}
-		s.log.Error().Err(err).Str("id", sub.ID).Msg("repo update failed")
+		if errors.Is(err, ErrVersionConflict) {
+			s.logger(ctx).Warn().Ctx(ctx).Str("id", sub.ID).Int("version", sub.Version).Msg("Subscription version conflict")
+			return err
+		}
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Str("id", sub.ID).Msg("repo update failed")
 		return err
 	}
+	s.fill(sub)
 
-	s.log.Debug().Str("id", sub.ID).Msg("Subscription updated successfully")
+	s.logger(ctx).Debug().Ctx(ctx).Str("id", sub.ID).Msg("Subscription updated successfully")
 	return nil
 }
 
-func (s *subscriptionService) Delete(ctx context.Context, id string) error {
-	s.log.Info().Str("id", id).Msg("Deleting subscription")
+func (s *subscriptionService) Delete(ctx context.Context, id string, version int) error {
+	s.logger(ctx).Info().Ctx(ctx).Str("id", id).Int("version", version).Msg("Deleting subscription")
 
-	if err := s.repo.Delete(ctx, id); err != nil {
+	if err := s.repo.Delete(ctx, id, version); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			s.log.Warn().Str("id", id).Msg("Subscription not found")
+			s.logger(ctx).Warn().Ctx(ctx).Str("id", id).Msg("Subscription not found")
 			return ErrNotFound
 		}
-		s.log.Error().Err(err).Str("id", id).Msg("repo delete failed")
+		if errors.Is(err, ErrVersionConflict) {
+			s.logger(ctx).Warn().Ctx(ctx).Str("id", id).Int("version", version).Msg("Subscription version conflict")
+			return err
+		}
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Str("id", id).Msg("repo delete failed")
 		return err
 	}
 
-	s.log.Debug().Str("id", id).Msg("Subscription deleted successfully")
+	s.logger(ctx).Debug().Ctx(ctx).Str("id", id).Msg("Subscription deleted successfully")
 	return nil
 }
 
-func (s *subscriptionService) Aggregate(ctx context.Context, from, to string, userID, serviceName *string) (int64, error) {
-	s.log.Info().
-		Str("from", from).
-		Str("to", to).
-		Str("user_id", deref(userID)).
-		Str("service_name", deref(serviceName)).
+func (s *subscriptionService) Aggregate(ctx context.Context, f model.AggregateFilter) (int64, error) {
+	s.logger(ctx).Info().Ctx(ctx).
+		Time("from", f.From).
+		Time("to", f.To).
+		Str("user_id", deref(f.UserID)).
+		Str("service_name", deref(f.ServiceName)).
+		Str("currency", f.Currency).
+		Str("proration", string(f.Proration)).
 		Msg("Aggregating subscriptions")
 
-	total, err := s.repo.AggregateTotal(ctx, from, to, userID, serviceName)
+	if err := s.resolveAggregateFilter(ctx, &f); err != nil {
+		return 0, err
+	}
+
+	total, err := s.repo.AggregateTotal(ctx, f)
 	if err != nil {
-		s.log.Error().Err(err).Msg("repo aggregate failed")
+		if errors.Is(err, ErrRateNotFound) {
+			s.logger(ctx).Warn().Ctx(ctx).Err(err).Msg("Exchange rate missing")
+			return 0, err
+		}
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo aggregate failed")
 		return 0, err
 	}
 
-	s.log.Debug().
-		Str("from", from).
-		Str("to", to).
+	s.logger(ctx).Debug().Ctx(ctx).
+		Time("from", f.From).
+		Time("to", f.To).
 		Int64("total", int64(total)).
 		Msg("Subscriptions aggregated successfully")
 
 	return int64(total), nil
 }
 
-func (s *subscriptionService) AggregateWithDetails(ctx context.Context, from, to string, userID, serviceName *string) ([]model.SubscriptionInfo, int64, error) {
-	s.log.Info().
-		Str("from", from).
-		Str("to", to).
-		Str("user_id", deref(userID)).
-		Str("service_name", deref(serviceName)).
+func (s *subscriptionService) AggregateWithDetails(ctx context.Context, f model.AggregateFilter) ([]model.SubscriptionInfo, int64, error) {
+	s.logger(ctx).Info().Ctx(ctx).
+		Time("from", f.From).
+		Time("to", f.To).
+		Str("user_id", deref(f.UserID)).
+		Str("service_name", deref(f.ServiceName)).
+		Str("currency", f.Currency).
+		Str("proration", string(f.Proration)).
 		Msg("Aggregating subscriptions with details")
 
-	subs, err := s.repo.FindSubscriptionsOverlapping(ctx, from, to, userID, serviceName)
+	if err := s.resolveAggregateFilter(ctx, &f); err != nil {
+		return nil, 0, err
+	}
+
+	subs, err := s.repo.FindSubscriptionsOverlapping(ctx, f)
 	if err != nil {
-		s.log.Error().Err(err).Msg("repo aggregate with details failed")
+		if errors.Is(err, ErrRateNotFound) {
+			s.logger(ctx).Warn().Ctx(ctx).Err(err).Msg("Exchange rate missing")
+			return nil, 0, err
+		}
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo aggregate with details failed")
 		return nil, 0, err
 	}
 
@@ -173,19 +375,102 @@ func (s *subscriptionService) AggregateWithDetails(ctx context.Context, from, to
 
 	// Обработка подписок с нумерацией
 	for i, subscription := range subs {
-		total += int64(subscription.Price)
-		details = append(details, model.SubscriptionInfo{
-			Number:      i + 1, // Добавляем нумерацию начиная с 1
-			ServiceName: subscription.ServiceName,
-			Price:       subscription.Price,
-			UserID:      subscription.UserID,
-		})
+		total += subscription.ConvertedCost
+		details = append(details, subscriptionInfo(i+1, subscription)) // нумерация с 1
 	}
 
-	s.log.Debug().
+	s.logger(ctx).Debug().Ctx(ctx).
 		Int64("total", total).
 		Int("details_count", len(details)).
 		Msg("Subscriptions aggregated with details successfully")
 
 	return details, total, nil
 }
+
+// subscriptionInfo превращает строку агрегации в деталь ответа с номером n
+func subscriptionInfo(n int, sub *model.SubscriptionUsage) model.SubscriptionInfo {
+	return model.SubscriptionInfo{
+		Number:        n,
+		ServiceName:   sub.ServiceName,
+		Price:         sub.Price,
+		UserID:        sub.UserID,
+		Category:      sub.Category,
+		Tags:          sub.Tags,
+		BillingPeriod: sub.BillingPeriod,
+		MonthlyCost:   sub.BillingPeriod.MonthlyEquivalent(sub.Price),
+		Currency:      sub.Currency,
+		Months:        sub.Months,
+		Days:          sub.Days,
+		Cost:          sub.Cost,
+		ConvertedCost: sub.ConvertedCost,
+		Rates:         sub.Rates,
+	}
+}
+
+func (s *subscriptionService) AggregateMonthly(ctx context.Context, f model.AggregateFilter) ([]model.MonthCost, int64, error) {
+	s.logger(ctx).Info().Ctx(ctx).
+		Time("from", f.From).
+		Time("to", f.To).
+		Str("user_id", deref(f.UserID)).
+		Str("service_name", deref(f.ServiceName)).
+		Str("currency", f.Currency).
+		Str("proration", string(f.Proration)).
+		Msg("Aggregating subscriptions by month")
+
+	if err := s.resolveAggregateFilter(ctx, &f); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.repo.AggregateMonthly(ctx, f)
+	if err != nil {
+		if errors.Is(err, ErrRateNotFound) {
+			s.logger(ctx).Warn().Ctx(ctx).Err(err).Msg("Exchange rate missing")
+			return nil, 0, err
+		}
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo aggregate monthly failed")
+		return nil, 0, err
+	}
+
+	// каждый месяц окна присутствует в ответе, даже если трат в нём не было
+	months := make([]model.MonthCost, 0)
+	index := make(map[string]int)
+	first := time.Date(f.From.Year(), f.From.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for m := first; !m.After(f.To); m = m.AddDate(0, 1, 0) {
+		key := m.Format(monthLayout)
+		index[key] = len(months)
+		months = append(months, model.MonthCost{Month: key, Services: []model.ServiceCost{}})
+	}
+
+	var total int64
+	for _, row := range rows {
+		i, ok := index[row.Month.Format(monthLayout)]
+		if !ok {
+			continue
+		}
+		months[i].Total += row.Total
+		months[i].Services = append(months[i].Services, model.ServiceCost{
+			ServiceName: row.ServiceName,
+			Total:       row.Total,
+		})
+		total += row.Total
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).
+		Int64("total", total).
+		Int("months", len(months)).
+		Msg("Subscriptions aggregated by month successfully")
+
+	return months, total, nil
+}
+
+func (s *subscriptionService) LoadExchangeRates(ctx context.Context, rates []model.ExchangeRate) error {
+	s.logger(ctx).Info().Ctx(ctx).Int("count", len(rates)).Msg("Loading exchange rates")
+
+	if err := s.repo.UpsertExchangeRates(ctx, rates); err != nil {
+		s.logger(ctx).Error().Ctx(ctx).Err(err).Msg("repo upsert exchange rates failed")
+		return err
+	}
+
+	s.logger(ctx).Debug().Ctx(ctx).Int("count", len(rates)).Msg("Exchange rates loaded successfully")
+	return nil
+}
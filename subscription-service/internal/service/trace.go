@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"subscription-service/internal/model"
+	"subscription-service/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Trace оборачивает сервис так, что каждый его метод открывает спан OpenTelemetry
+// с ключевыми параметрами вызова. Спаны репозитория становятся его дочерними.
+// Ошибки сервиса, в том числе ожидаемые вроде ErrNotFound, отмечаются в спане.
+func Trace(next SubscriptionService) SubscriptionService {
+	return &traced{next: next, tracer: otel.Tracer("subscription-service/service")}
+}
+
+type traced struct {
+	next   SubscriptionService
+	tracer trace.Tracer
+}
+
+func (t *traced) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "SubscriptionService."+method, trace.WithAttributes(attrs...))
+}
+
+func idAttr(id string) attribute.KeyValue {
+	return attribute.String("subscription.id", id)
+}
+
+func listAttrs(f model.ListFilter) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.StringSlice("filter.user_ids", f.UserIDs),
+		attribute.String("filter.service_id", f.ServiceID),
+		attribute.String("filter.service_name", f.ServiceName),
+		attribute.String("filter.category", f.Category),
+		attribute.Int("filter.limit", f.Limit),
+	}
+}
+
+func aggregateAttrs(f model.AggregateFilter) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("aggregate.from", f.From.Format(time.DateOnly)),
+		attribute.String("aggregate.to", f.To.Format(time.DateOnly)),
+		attribute.String("aggregate.currency", f.Currency),
+		attribute.String("aggregate.proration", string(f.Proration)),
+	}
+	if f.UserID != nil {
+		attrs = append(attrs, attribute.String("filter.user_id", *f.UserID))
+	}
+	if f.ServiceID != nil {
+		attrs = append(attrs, attribute.String("filter.service_id", *f.ServiceID))
+	}
+	if f.ServiceName != nil {
+		attrs = append(attrs, attribute.String("filter.service_name", *f.ServiceName))
+	}
+	return attrs
+}
+
+func (t *traced) Create(ctx context.Context, sub *model.Subscription) (err error) {
+	ctx, span := t.start(ctx, "Create", attribute.String("subscription.user_id", sub.UserID))
+	defer func() { tracing.End(span, err) }()
+	err = t.next.Create(ctx, sub)
+	span.SetAttributes(idAttr(sub.ID))
+	return err
+}
+
+func (t *traced) GetByID(ctx context.Context, id string) (_ *model.Subscription, err error) {
+	ctx, span := t.start(ctx, "GetByID", idAttr(id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.GetByID(ctx, id)
+}
+
+func (t *traced) List(ctx context.Context, f model.ListFilter) (_ *model.SubscriptionPage, err error) {
+	ctx, span := t.start(ctx, "List", listAttrs(f)...)
+	defer func() { tracing.End(span, err) }()
+	return t.next.List(ctx, f)
+}
+
+func (t *traced) Update(ctx context.Context, sub *model.Subscription) (err error) {
+	ctx, span := t.start(ctx, "Update", idAttr(sub.ID))
+	defer func() { tracing.End(span, err) }()
+	return t.next.Update(ctx, sub)
+}
+
+func (t *traced) Delete(ctx context.Context, id string, version int) (err error) {
+	ctx, span := t.start(ctx, "Delete", idAttr(id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.Delete(ctx, id, version)
+}
+
+func (t *traced) Aggregate(ctx context.Context, f model.AggregateFilter) (_ int64, err error) {
+	ctx, span := t.start(ctx, "Aggregate", aggregateAttrs(f)...)
+	defer func() { tracing.End(span, err) }()
+	return t.next.Aggregate(ctx, f)
+}
+
+func (t *traced) AggregateWithDetails(ctx context.Context, f model.AggregateFilter) (_ []model.SubscriptionInfo, _ int64, err error) {
+	ctx, span := t.start(ctx, "AggregateWithDetails", aggregateAttrs(f)...)
+	defer func() { tracing.End(span, err) }()
+	return t.next.AggregateWithDetails(ctx, f)
+}
+
+func (t *traced) AggregateMonthly(ctx context.Context, f model.AggregateFilter) (_ []model.MonthCost, _ int64, err error) {
+	ctx, span := t.start(ctx, "AggregateMonthly", aggregateAttrs(f)...)
+	defer func() { tracing.End(span, err) }()
+	return t.next.AggregateMonthly(ctx, f)
+}
+
+func (t *traced) LoadExchangeRates(ctx context.Context, rates []model.ExchangeRate) (err error) {
+	ctx, span := t.start(ctx, "LoadExchangeRates", attribute.Int("rates.count", len(rates)))
+	defer func() { tracing.End(span, err) }()
+	return t.next.LoadExchangeRates(ctx, rates)
+}
+
+func (t *traced) Pause(ctx context.Context, id string) (_ *model.Subscription, err error) {
+	ctx, span := t.start(ctx, "Pause", idAttr(id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.Pause(ctx, id)
+}
+
+func (t *traced) Resume(ctx context.Context, id string) (_ *model.Subscription, err error) {
+	ctx, span := t.start(ctx, "Resume", idAttr(id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.Resume(ctx, id)
+}
+
+func (t *traced) Cancel(ctx context.Context, id string) (_ *model.Subscription, err error) {
+	ctx, span := t.start(ctx, "Cancel", idAttr(id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.Cancel(ctx, id)
+}
+
+func (t *traced) SchedulePriceChange(ctx context.Context, id string, month time.Time, price int) (_ []model.PriceChange, err error) {
+	ctx, span := t.start(ctx, "SchedulePriceChange", idAttr(id), attribute.String("price.month", month.Format(time.DateOnly)))
+	defer func() { tracing.End(span, err) }()
+	return t.next.SchedulePriceChange(ctx, id, month, price)
+}
+
+func (t *traced) PriceHistory(ctx context.Context, id string) (_ []model.PriceChange, err error) {
+	ctx, span := t.start(ctx, "PriceHistory", idAttr(id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.PriceHistory(ctx, id)
+}
+
+func (t *traced) CreateService(ctx context.Context, svc *model.CatalogService) (err error) {
+	ctx, span := t.start(ctx, "CreateService", attribute.String("service.name", svc.Name))
+	defer func() { tracing.End(span, err) }()
+	return t.next.CreateService(ctx, svc)
+}
+
+func (t *traced) GetService(ctx context.Context, id string) (_ *model.CatalogService, err error) {
+	ctx, span := t.start(ctx, "GetService", attribute.String("service.id", id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.GetService(ctx, id)
+}
+
+func (t *traced) ListServices(ctx context.Context) (_ []*model.CatalogService, err error) {
+	ctx, span := t.start(ctx, "ListServices")
+	defer func() { tracing.End(span, err) }()
+	return t.next.ListServices(ctx)
+}
+
+func (t *traced) UpdateService(ctx context.Context, svc *model.CatalogService) (err error) {
+	ctx, span := t.start(ctx, "UpdateService", attribute.String("service.id", svc.ID))
+	defer func() { tracing.End(span, err) }()
+	return t.next.UpdateService(ctx, svc)
+}
+
+func (t *traced) DeleteService(ctx context.Context, id string) (err error) {
+	ctx, span := t.start(ctx, "DeleteService", attribute.String("service.id", id))
+	defer func() { tracing.End(span, err) }()
+	return t.next.DeleteService(ctx, id)
+}
+
+func (t *traced) BeginIdempotent(ctx context.Context, scope, key string, body []byte) (_ *model.IdempotencyRecord, err error) {
+	ctx, span := t.start(ctx, "BeginIdempotent", attribute.String("idempotency.scope", scope))
+	defer func() { tracing.End(span, err) }()
+	return t.next.BeginIdempotent(ctx, scope, key, body)
+}
+
+func (t *traced) FinishIdempotent(ctx context.Context, rec *model.IdempotencyRecord) (err error) {
+	ctx, span := t.start(ctx, "FinishIdempotent", attribute.String("idempotency.scope", rec.Scope))
+	defer func() { tracing.End(span, err) }()
+	return t.next.FinishIdempotent(ctx, rec)
+}
+
+func (t *traced) Batch(ctx context.Context, ops []model.BatchOperation, atomic bool) (_ []model.BatchResult, _ bool, err error) {
+	ctx, span := t.start(ctx, "Batch", attribute.Int("batch.size", len(ops)), attribute.Bool("batch.atomic", atomic))
+	defer func() { tracing.End(span, err) }()
+	return t.next.Batch(ctx, ops, atomic)
+}
+
+func (t *traced) Import(ctx context.Context, rows []model.ImportRow, dryRun bool) (_ int64, _ []model.ImportError, err error) {
+	ctx, span := t.start(ctx, "Import", attribute.Int("import.rows", len(rows)), attribute.Bool("import.dry_run", dryRun))
+	defer func() { tracing.End(span, err) }()
+	return t.next.Import(ctx, rows, dryRun)
+}
+
+func (t *traced) ExportSubscriptions(ctx context.Context, f model.ListFilter, fn func(*model.Subscription) error) (err error) {
+	ctx, span := t.start(ctx, "ExportSubscriptions", listAttrs(f)...)
+	defer func() { tracing.End(span, err) }()
+	return t.next.ExportSubscriptions(ctx, f, fn)
+}
+
+func (t *traced) ExportAggregate(ctx context.Context, f model.AggregateFilter, fn func(model.SubscriptionInfo) error) (_ int64, err error) {
+	ctx, span := t.start(ctx, "ExportAggregate", aggregateAttrs(f)...)
+	defer func() { tracing.End(span, err) }()
+	return t.next.ExportAggregate(ctx, f, fn)
+}
+
+func (t *traced) Search(ctx context.Context, query string, f model.ListFilter) (_ []*model.SearchHit, err error) {
+	ctx, span := t.start(ctx, "Search", append(listAttrs(f), attribute.String("search.query", query))...)
+	defer func() { tracing.End(span, err) }()
+	return t.next.Search(ctx, query, f)
+}
+
+func (t *traced) Stats(ctx context.Context) (_ *model.Stats, err error) {
+	ctx, span := t.start(ctx, "Stats")
+	defer func() { tracing.End(span, err) }()
+	return t.next.Stats(ctx)
+}
@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName — имя сервиса в трассах
+const ServiceName = "subscription-service"
+
+// Экспортёры трасс
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterNone   = "none"
+)
+
+// Config — куда и какую долю трасс отправлять
+type Config struct {
+	// Exporter — otlp, stdout, file или none; пустой — otlp, если задан OTLPEndpoint, иначе stdout
+	Exporter     string
+	OTLPEndpoint string  // URL коллектора, например http://otel-collector:4318
+	File         string  // файл для экспортёра file
+	SampleRatio  float64 // доля трасс, начинаемых сервисом; входящий traceparent решает сам
+}
+
+// Setup настраивает глобальные TracerProvider и W3C-пропагатор (traceparent, baggage).
+// Возвращённый shutdown досылает накопленные спаны и закрывает экспортёр.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	exporter := cfg.Exporter
+	if exporter == "" {
+		exporter = ExporterStdout
+		if cfg.OTLPEndpoint != "" {
+			exporter = ExporterOTLP
+		}
+	}
+
+	var (
+		exp       sdktrace.SpanExporter
+		closeFile func() error
+	)
+	switch exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+		}
+		exp, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		if cfg.File == "" {
+			return nil, errors.New("trace file is empty; set TRACE_FILE")
+		}
+		var f *os.File
+		f, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		closeFile = f.Close
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		if closeFile != nil {
+			_ = closeFile()
+		}
+		return nil, fmt.Errorf("create %s trace exporter: %w", exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL, semconv.ServiceName(ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closeFile != nil {
+			err = errors.Join(err, closeFile())
+		}
+		return err
+	}, nil
+}
+
+// QueryHook открывает спан на каждый метод репозитория. sql.ErrNoRows — ответ
+// «не найдено», а не сбой БД, и ошибкой спана не считается.
+func QueryHook(ctx context.Context, query string) (context.Context, func(err error)) {
+	ctx, span := otel.Tracer(ServiceName+"/db").Start(ctx, "db."+query,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			attribute.String("db.operation", query),
+		),
+	)
+	return ctx, func(err error) {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+		End(span, err)
+	}
+}
+
+// End завершает спан, отмечая ошибку, если она есть
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record подменяет глобальный TracerProvider на время теста и возвращает записанные спаны
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	prev := otel.GetTracerProvider()
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return sr
+}
+
+func TestQueryHook(t *testing.T) {
+	sr := record(t)
+	ctx, root := otel.Tracer("test").Start(context.Background(), "request")
+	for _, err := range []error{nil, sql.ErrNoRows, errors.New("connection reset")} {
+		_, done := QueryHook(ctx, "GetByID")
+		done(err)
+	}
+	root.End()
+
+	spans := sr.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("got %d spans, want 4", len(spans))
+	}
+	// «не найдено» — не ошибка спана
+	wantStatus := []codes.Code{codes.Unset, codes.Unset, codes.Error}
+	for i, s := range spans[:3] {
+		if s.Name() != "db.GetByID" || s.SpanKind() != trace.SpanKindClient {
+			t.Errorf("span %d: %s %v", i, s.Name(), s.SpanKind())
+		}
+		if s.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("span %d is not a child of the request span", i)
+		}
+		if s.Status().Code != wantStatus[i] {
+			t.Errorf("span %d: status %v, want %v", i, s.Status().Code, wantStatus[i])
+		}
+	}
+	if events := spans[2].Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("error is not recorded: %+v", events)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	ctx := context.Background()
+
+	for _, cfg := range []Config{
+		{Exporter: "jaeger"},
+		{Exporter: ExporterFile},
+	} {
+		if _, err := Setup(ctx, cfg); err == nil {
+			t.Errorf("Setup(%+v): want error", cfg)
+		}
+	}
+
+	shutdown, err := Setup(ctx, Config{Exporter: ExporterNone})
+	if err != nil || shutdown(ctx) != nil {
+		t.Errorf("Setup(none): %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err = Setup(ctx, Config{Exporter: ExporterFile, File: file, SampleRatio: 1})
+	if err != nil {
+		t.Fatalf("Setup(file): %v", err)
+	}
+	_, span := otel.Tracer("test").Start(ctx, "op")
+	if !span.SpanContext().IsSampled() {
+		t.Error("span is not sampled with SampleRatio 1")
+	}
+	span.End()
+	if err := shutdown(ctx); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	// shutdown досылает спаны из пакета
+	if data, err := os.ReadFile(file); err != nil || !strings.Contains(string(data), `"Name":"op"`) {
+		t.Errorf("trace file has no span op: %v\n%s", err, data)
+	}
+}